@@ -3,9 +3,12 @@ package main
 import (
 	"errors"
 
+	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
 
 	"github.com/kubeedge/coap/device"
+	"github.com/kubeedge/mapper-common/pkg/logger"
+	"github.com/kubeedge/mapper-common/pkg/report"
 	"github.com/kubeedge/mapper-framework/pkg/common"
 	"github.com/kubeedge/mapper-framework/pkg/config"
 	"github.com/kubeedge/mapper-framework/pkg/grpcclient"
@@ -20,10 +23,25 @@ func main() {
 	klog.InitFlags(nil)
 	defer klog.Flush()
 
+	var logFormat string
+	pflag.StringVar(&logFormat, "log-format", logger.FormatText, "log output format: text or json")
+	var reportFlags report.Flags
+	reportFlags.AddFlags(pflag.CommandLine)
+	var maxDials int
+	pflag.IntVar(&maxDials, "max-dials", 0, "max device connections dialed at the same time across all devices, 0 for unlimited")
 	if c, err = config.Parse(); err != nil {
 		klog.Fatal(err)
 	}
+	if err = logger.Setup(logFormat); err != nil {
+		klog.Fatal(err)
+	}
 	klog.Infof("config: %+v", c)
+	if err = reportFlags.Apply(); err != nil {
+		klog.Fatal(err)
+	}
+	if err = device.SetMaxDials(maxDials); err != nil {
+		klog.Fatal(err)
+	}
 
 	klog.Infoln("Mapper will register to edgecore")
 	deviceList, deviceModelList, err := grpcclient.RegisterMapper(true)
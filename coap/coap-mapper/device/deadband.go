@@ -0,0 +1,29 @@
+package device
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/kubeedge/coap/driver"
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// withinDeadband reports whether current differs from the last reported value by no
+// more than the visitor's Deadband, in which case the report is skipped. Otherwise
+// the value becomes the new reference. Values that are not numeric always report.
+func (td *TwinData) withinDeadband(current interface{}) bool {
+	deadband := td.VisitorConfig.VisitorConfigData.Deadband
+	if deadband <= 0 {
+		return false
+	}
+	v, err := strconv.ParseFloat(value.Stringify(current), 64)
+	if err != nil {
+		return false
+	}
+	if td.lastReported != nil && math.Abs(v-*td.lastReported) <= deadband {
+		td.Client.V(driver.LogReport, 3).Infof("twindata %s value %v within deadband %v of %v, not reporting", td.Name, v, deadband, *td.lastReported)
+		return true
+	}
+	td.lastReported = &v
+	return false
+}
@@ -1,12 +1,14 @@
 package device
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +24,7 @@ import (
 	otelMethod "github.com/kubeedge/coap/data/publish/otel"
 	"github.com/kubeedge/coap/data/stream"
 	"github.com/kubeedge/coap/driver"
+	"github.com/kubeedge/mapper-common/pkg/value"
 	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
 	"github.com/kubeedge/mapper-framework/pkg/common"
 	"github.com/kubeedge/mapper-framework/pkg/global"
@@ -35,6 +38,8 @@ type DevPanel struct {
 	wg           sync.WaitGroup
 	serviceMutex sync.Mutex
 	quitChan     chan os.Signal
+	// manager schedules the health checks and dials of the clients of all devices
+	manager *driver.Manager
 }
 
 var (
@@ -44,9 +49,27 @@ var (
 
 var ErrEmptyData = errors.New("device or device model list is empty")
 
+// maxDials bounds the concurrent dials of all devices, see SetMaxDials.
+var maxDials int
+
+// SetMaxDials bounds the dials in flight across all devices to n, 0 for
+// unbounded. It must be called before NewDevPanel.
+func SetMaxDials(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid max dials %d", n)
+	}
+	maxDials = n
+	if n > 0 {
+		klog.Infof("Device dials limited to %d at a time", n)
+	}
+	return nil
+}
+
 // NewDevPanel init and return devPanel
 func NewDevPanel() *DevPanel {
 	once.Do(func() {
+		// maxDials is not negative, SetMaxDials checked it
+		manager, _ := driver.NewManager(context.Background(), maxDials)
 		devPanel = &DevPanel{
 			deviceMuxs:   make(map[string]context.CancelFunc),
 			devices:      make(map[string]*driver.CustomizedDev),
@@ -54,6 +77,7 @@ func NewDevPanel() *DevPanel {
 			wg:           sync.WaitGroup{},
 			serviceMutex: sync.Mutex{},
 			quitChan:     make(chan os.Signal),
+			manager:      manager,
 		}
 	})
 	return devPanel
@@ -97,6 +121,8 @@ func (d *DevPanel) start(ctx context.Context, dev *driver.CustomizedDev) {
 		klog.Errorf("Init dev %s error: %v", dev.Instance.Name, err)
 		return
 	}
+	client.DisableProperties(disabledProperties(&dev.Instance))
+	client.Manager = d.manager
 	dev.CustomizedClient = client
 	err = dev.CustomizedClient.InitDevice()
 	if err != nil {
@@ -129,6 +155,15 @@ func dataHandler(ctx context.Context, dev *driver.CustomizedDev) {
 			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
 			continue
 		}
+		if !visitorConfig.VisitorConfigData.IsEnabled() {
+			klog.Infof("Property %s is disabled, skipping", twin.PropertyName)
+			continue
+		}
+		if err = dev.CustomizedClient.RegisterVisitor(&visitorConfig); err != nil {
+			klog.Error(err)
+			continue
+		}
+		dev.CustomizedClient.RegisterModelProperty(visitorConfig.VisitorConfigData.PropertyName, twin.Property.PProperty)
 		err = setVisitor(&visitorConfig, &twin, dev)
 		if err != nil {
 			klog.Error(err)
@@ -147,6 +182,11 @@ func dataHandler(ctx context.Context, dev *driver.CustomizedDev) {
 		}
 
 		// handle twin
+		cycle, err := collectCycle(&twin, &visitorConfig)
+		if err != nil {
+			klog.Errorf("Property %s: %v", twin.PropertyName, err)
+			continue
+		}
 		twinData := &TwinData{
 			DeviceName:      dev.Instance.Name,
 			DeviceNamespace: dev.Instance.Namespace,
@@ -156,8 +196,12 @@ func dataHandler(ctx context.Context, dev *driver.CustomizedDev) {
 			ObservedDesired: twin.ObservedDesired,
 			VisitorConfig:   &visitorConfig,
 			Topic:           fmt.Sprintf(common.TopicTwinUpdate, dev.Instance.ID),
-			CollectCycle:    time.Millisecond * time.Duration(twin.Property.CollectCycle),
+			CollectCycle:    cycle,
 			ReportToCloud:   twin.Property.ReportToCloud,
+
+			DeviceNameTemplate: visitorConfig.VisitorConfigData.DeviceNameTemplate,
+			NamespaceOverride:  visitorConfig.VisitorConfigData.NamespaceOverride,
+			ReportOnStart:      visitorConfig.VisitorConfigData.ReportOnStart,
 		}
 		go twinData.Run(ctx)
 
@@ -259,6 +303,49 @@ func dbHandler(ctx context.Context, twin *common.Twin, client *driver.Customized
 	}
 }
 
+// collectCycle returns how often the twin of a property is collected: the visitor's
+// collectInterval if set, else the model's collectCycle. Zero means the default,
+// common.DefaultCollectCycle, which TwinData.Run applies.
+func collectCycle(twin *common.Twin, visitorConfig *driver.VisitorConfig) (time.Duration, error) {
+	if s := visitorConfig.VisitorConfigData.CollectInterval; s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid collectInterval %q", s)
+		}
+		return d, nil
+	}
+	return time.Millisecond * time.Duration(twin.Property.CollectCycle), nil
+}
+
+// disabledProperties lists the properties whose visitor config sets "enabled": false.
+func disabledProperties(inst *common.DeviceInstance) []string {
+	var names []string
+	for _, property := range inst.Properties {
+		var visitorConfig driver.VisitorConfig
+		if err := json.Unmarshal(property.Visitors, &visitorConfig); err != nil {
+			continue
+		}
+		if !visitorConfig.VisitorConfigData.IsEnabled() {
+			names = append(names, property.PropertyName)
+		}
+	}
+	return names
+}
+
+// convertValue converts s to dataType, or to the type value.Infer finds when
+// dataType is empty and the visitor sets InferType.
+func convertValue(dataType string, visitorConfig *driver.VisitorConfig, s string) (interface{}, error) {
+	if dataType == "" && visitorConfig.VisitorConfigData.InferType {
+		v, _ := value.Infer(s)
+		return v, nil
+	}
+	v, err := value.ToType(dataType, s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", driver.ErrConversion, err)
+	}
+	return v, nil
+}
+
 // setVisitor check if visitor property is readonly, if not then set it.
 func setVisitor(visitorConfig *driver.VisitorConfig, twin *common.Twin, dev *driver.CustomizedDev) error {
 	if twin.Property.PProperty.AccessMode == "ReadOnly" {
@@ -266,18 +353,18 @@ func setVisitor(visitorConfig *driver.VisitorConfig, twin *common.Twin, dev *dri
 		return nil
 	}
 	klog.V(2).Infof("Convert type: %s, value: %s ", twin.Property.PProperty.DataType, twin.ObservedDesired.Value)
-	var value interface{}
+	var data interface{}
 	if twin.ObservedDesired.Value != "" {
-		convertedValue, err := common.Convert(twin.Property.PProperty.DataType, twin.ObservedDesired.Value)
+		convertedValue, err := convertValue(twin.Property.PProperty.DataType, visitorConfig, twin.ObservedDesired.Value)
 		if err != nil {
 			klog.Errorf("Failed to convert value as %s : %v", twin.Property.PProperty.DataType, err)
 			return err
 		}
-		value = convertedValue
+		data = convertedValue
 	} else {
-		value = twin.ObservedDesired.Value
+		data = twin.ObservedDesired.Value
 	}
-	err := dev.CustomizedClient.SetDeviceData(value, visitorConfig)
+	err := dev.CustomizedClient.SetDeviceData(data, visitorConfig)
 	if err != nil {
 		return fmt.Errorf("%s set device data error: %v", twin.PropertyName, err)
 	}
@@ -319,12 +406,20 @@ func (d *DevPanel) DevInit(deviceList []*dmiapi.Device, deviceModelList []*dmiap
 	return nil
 }
 
-// UpdateDev stop old device, then update and start new device
+// UpdateDev stop old device, then update and start new device. A change to the
+// protocol config alone is applied to the running client when it allows, see
+// driver.UpdateConfig.
 func (d *DevPanel) UpdateDev(model *common.DeviceModel, device *common.DeviceInstance) {
 	d.serviceMutex.Lock()
 	defer d.serviceMutex.Unlock()
 
 	if oldDevice, ok := d.devices[device.ID]; ok {
+		if reflect.DeepEqual(d.models[model.ID], *model) && onlyProtocolChanged(&oldDevice.Instance, device) &&
+			applyProtocolConfig(oldDevice, device) {
+			klog.Infof("Protocol config of %s applied without restart", device.ID)
+			oldDevice.Instance = *device
+			return
+		}
 		err := d.stopDev(oldDevice, device.ID)
 		if err != nil {
 			klog.Error(err)
@@ -341,6 +436,34 @@ func (d *DevPanel) UpdateDev(model *common.DeviceModel, device *common.DeviceIns
 	go d.start(ctx, d.devices[device.ID])
 }
 
+// onlyProtocolChanged reports whether newInst differs from oldInst in the protocol
+// config data and nothing else.
+func onlyProtocolChanged(oldInst, newInst *common.DeviceInstance) bool {
+	if bytes.Equal(oldInst.PProtocol.ConfigData, newInst.PProtocol.ConfigData) {
+		return false
+	}
+	next := *newInst
+	next.PProtocol.ConfigData = oldInst.PProtocol.ConfigData
+	return reflect.DeepEqual(*oldInst, next)
+}
+
+// applyProtocolConfig hands a changed protocol config to the running client of dev
+// with UpdateConfig. It returns false when the device has to be restarted instead.
+func applyProtocolConfig(dev *driver.CustomizedDev, newInst *common.DeviceInstance) bool {
+	if dev.CustomizedClient == nil {
+		return false
+	}
+	var cfg driver.ProtocolConfig
+	if err := json.Unmarshal(newInst.PProtocol.ConfigData, &cfg); err != nil {
+		return false
+	}
+	if err := dev.CustomizedClient.UpdateConfig(cfg); err != nil {
+		klog.Infof("Protocol config of %s not applied in place: %v", dev.Instance.ID, err)
+		return false
+	}
+	return true
+}
+
 // UpdateDevTwins update device's twins
 func (d *DevPanel) UpdateDevTwins(deviceID string, twins []common.Twin) error {
 	d.serviceMutex.Lock()
@@ -485,15 +608,15 @@ func (d *DevPanel) WriteDevice(deviceMethodName, deviceID, propertyName, data st
 		return fmt.Errorf("can't find device propertyName %s in device instance", propertyName)
 	}
 	klog.V(2).Infof("start writing values %v to device %s property %s", data, deviceID, propertyName)
-	writeData, err := common.Convert(strings.ToLower(dataType), data)
-	if err != nil {
-		return fmt.Errorf("conversion data format failed, datatype is %s, data is %s", strings.ToLower(dataType), data)
-	}
 	var visitorConfig driver.VisitorConfig
-	err = json.Unmarshal(deviceproperty.Visitors, &visitorConfig)
+	err := json.Unmarshal(deviceproperty.Visitors, &visitorConfig)
 	if err != nil {
 		return err
 	}
+	writeData, err := convertValue(strings.ToLower(dataType), &visitorConfig, data)
+	if err != nil {
+		return fmt.Errorf("conversion data format failed, datatype is %s, data is %s", strings.ToLower(dataType), data)
+	}
 
 	err = dev.CustomizedClient.DeviceDataWrite(&visitorConfig, deviceMethodName, propertyName, writeData)
 	if err != nil {
@@ -566,10 +689,7 @@ func (d *DevPanel) GetTwinResult(deviceID string, twinName string) (string, stri
 		if err != nil {
 			return "", "", fmt.Errorf("get device data failed: %v", err)
 		}
-		res, err = common.ConvertToString(data)
-		if err != nil {
-			return "", "", err
-		}
+		res = value.Stringify(data)
 		dataType = twin.Property.PProperty.DataType
 	}
 	return res, dataType, nil
@@ -39,11 +39,7 @@ type DeviceStates struct {
 
 // Run timer function.
 func (deviceStates *DeviceStates) PushStatesToEdgeCore() {
-	states, err := deviceStates.Client.GetDeviceStates()
-	if err != nil {
-		klog.Errorf("GetDeviceStates failed: %v", err)
-		return
-	}
+	states := deviceStates.Client.GetDMIState()
 
 	statesRequest := &dmiapi.ReportDeviceStatesRequest{
 		DeviceName:      deviceStates.DeviceName,
@@ -52,7 +48,7 @@ func (deviceStates *DeviceStates) PushStatesToEdgeCore() {
 	}
 
 	klog.V(4).Infof("send device %s status %s request to cloud", statesRequest.DeviceName, statesRequest.State)
-	if err = grpcclient.ReportDeviceStates(statesRequest); err != nil {
+	if err := grpcclient.ReportDeviceStates(statesRequest); err != nil {
 		klog.Errorf("fail to report device states of %s with err: %+v", deviceStates.DeviceName, err)
 	}
 }
@@ -7,9 +7,12 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/klog/v2"
 
 	"github.com/kubeedge/coap/driver"
+	"github.com/kubeedge/mapper-common/pkg/report"
+	"github.com/kubeedge/mapper-common/pkg/value"
 	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
 	"github.com/kubeedge/mapper-framework/pkg/common"
 	"github.com/kubeedge/mapper-framework/pkg/grpcclient"
@@ -28,24 +31,55 @@ type TwinData struct {
 	Results         interface{}
 	CollectCycle    time.Duration
 	ReportToCloud   bool
+	// DeviceNameTemplate and NamespaceOverride file reports under another device,
+	// see report.Target. Empty values report under DeviceName/DeviceNamespace.
+	DeviceNameTemplate string
+	NamespaceOverride  string
+	// Reporter receives the twin reports instead of EdgeCore when set.
+	Reporter report.Reporter
+	// ReportOnStart pushes once as soon as Run starts instead of waiting a full
+	// CollectCycle, so the cloud twin fills in right after a mapper restart.
+	ReportOnStart bool
+	// last numeric value reported, the reference for Deadband
+	lastReported *float64
+}
+
+// edgeCoreReporter reports to EdgeCore, the default Reporter.
+var edgeCoreReporter report.Reporter = report.ReporterFunc(grpcclient.ReportDeviceStatus)
+
+// reporter returns the Reporter of the twin, EdgeCore by default.
+func (td *TwinData) reporter() report.Reporter {
+	if td.Reporter != nil {
+		return td.Reporter
+	}
+	return edgeCoreReporter
 }
 
 func (td *TwinData) GetPayLoad() ([]byte, error) {
+	return td.getPayload(context.Background())
+}
+
+// getPayload is GetPayLoad with the read traced as a child of the span in ctx.
+func (td *TwinData) getPayload(ctx context.Context) ([]byte, error) {
 	var err error
 	td.VisitorConfig.VisitorConfigData.DataType = strings.ToLower(td.VisitorConfig.VisitorConfigData.DataType)
-	td.Results, err = td.Client.GetDeviceData(td.VisitorConfig)
+	td.Results, err = td.readValue(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("get device data failed: %v", err)
 	}
-	sData, err := common.ConvertToString(td.Results)
-	if err != nil {
-		klog.Errorf("Failed to convert %s %s value as string : %v", td.DeviceName, td.Name, err)
-		return nil, err
-	}
+	return td.payloadFor(ctx, td.Results)
+}
+
+// payloadFor builds the twin message carrying v, traced as a child of the span in ctx.
+func (td *TwinData) payloadFor(ctx context.Context, v interface{}) ([]byte, error) {
+	var err error
+	_, span := td.startSpan(ctx, "convert")
+	defer span.End()
+	sData := value.Stringify(v)
 	if len(sData) > 30 {
-		klog.V(4).Infof("Get %s : %s ,value is %s......", td.DeviceName, td.Name, sData[:30])
+		td.Client.V(driver.LogReport, 4).Infof("Get %s : %s ,value is %s......", td.DeviceName, td.Name, sData[:30])
 	} else {
-		klog.V(4).Infof("Get %s : %s ,value is %s", td.DeviceName, td.Name, sData)
+		td.Client.V(driver.LogReport, 4).Infof("Get %s : %s ,value is %s", td.DeviceName, td.Name, sData)
 	}
 	var payload []byte
 	if strings.Contains(td.Topic, "$hw") {
@@ -60,12 +94,55 @@ func (td *TwinData) GetPayLoad() ([]byte, error) {
 	return payload, nil
 }
 
-func (td *TwinData) PushToEdgeCore() {
-	payload, err := td.GetPayLoad()
+// readValue reads the value of the twin: its own property, or with Aggregate the
+// listed properties assembled into one JSON object keyed by property name. The
+// driver reads are traced as children of the span in ctx.
+func (td *TwinData) readValue(ctx context.Context) (interface{}, error) {
+	props := td.VisitorConfig.VisitorConfigData.Aggregate
+	if len(props) == 0 {
+		return td.Client.GetDeviceDataContext(ctx, td.VisitorConfig)
+	}
+	obj := make(map[string]interface{}, len(props))
+	for _, prop := range props {
+		v, err := td.Client.GetPropertyContext(ctx, prop)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate %s: %v", prop, err)
+		}
+		obj[prop] = v
+	}
+	return obj, nil
+}
+
+// PushToEdgeCore reads the twin and reports it. The collect, convert and report
+// steps are traced under one span, a child of the span in ctx.
+func (td *TwinData) PushToEdgeCore(ctx context.Context) {
+	td.push(ctx, td.getPayload)
+}
+
+// pushValue reports v, a value of the twin already read, like PushToEdgeCore.
+func (td *TwinData) pushValue(ctx context.Context, v interface{}) {
+	td.push(ctx, func(ctx context.Context) ([]byte, error) {
+		td.Results = v
+		return td.payloadFor(ctx, v)
+	})
+}
+
+// push reports the twin payload built by getPayload, traced under one span, a
+// child of the span in ctx.
+func (td *TwinData) push(ctx context.Context, getPayload func(context.Context) ([]byte, error)) {
+	var err error
+	ctx, span := td.startSpan(ctx, "PushToEdgeCore")
+	defer func() { endSpan(span, err) }()
+
+	payload, err := getPayload(ctx)
 	if err != nil {
 		klog.Errorf("twindata %s unmarshal failed, err: %s", td.Name, err)
 		return
 	}
+	if td.withinDeadband(td.Results) {
+		span.SetAttributes(attribute.Bool("deadband", true))
+		return
+	}
 
 	var msg common.DeviceTwinUpdate
 	if err = json.Unmarshal(payload, &msg); err != nil {
@@ -75,17 +152,23 @@ func (td *TwinData) PushToEdgeCore() {
 
 	twins := parse.ConvMsgTwinToGrpc(msg.Twin)
 
+	deviceName, namespace := report.Target(td.DeviceName, td.DeviceNamespace, td.Name, td.DeviceNameTemplate, td.NamespaceOverride)
 	var rdsr = &dmiapi.ReportDeviceStatusRequest{
-		DeviceName:      td.DeviceName,
-		DeviceNamespace: td.DeviceNamespace,
+		DeviceName:      deviceName,
+		DeviceNamespace: namespace,
 		ReportedDevice: &dmiapi.DeviceStatus{
 			Twins: twins,
 			//State: "OK",
 		},
 	}
 
-	if err := grpcclient.ReportDeviceStatus(rdsr); err != nil {
-		klog.Errorf("fail to report device status of %s with err: %+v", rdsr.DeviceName, err)
+	td.Client.V(driver.LogReport, 2).InfoS("Reporting twin", "device", deviceName, "namespace", namespace, "property", td.Name)
+	key := namespace + "/" + deviceName + "/" + td.Name
+	_, reportSpan := td.startSpan(ctx, "ReportDeviceStatus")
+	_, err = report.Submit(key, rdsr, td.reporter())
+	endSpan(reportSpan, err)
+	if err != nil {
+		klog.ErrorS(err, "Failed to report twin", "device", deviceName, "namespace", namespace, "property", td.Name)
 	}
 }
 
@@ -97,10 +180,37 @@ func (td *TwinData) Run(ctx context.Context) {
 		td.CollectCycle = common.DefaultCollectCycle
 	}
 	ticker := time.NewTicker(td.CollectCycle)
+	if td.ReportOnStart {
+		td.pushOnStart(ctx)
+	}
 	for {
 		select {
 		case <-ticker.C:
-			td.PushToEdgeCore()
+			td.PushToEdgeCore(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reportOnStartRetry is how often Run retries the first read for ReportOnStart
+// while the property cannot be read yet, e.g. before the connection is up.
+const reportOnStartRetry = time.Second
+
+// pushOnStart makes the first report of ReportOnStart as soon as a quick read of
+// the property succeeds, retrying every reportOnStartRetry until the first
+// collect cycle is due.
+func (td *TwinData) pushOnStart(ctx context.Context) {
+	due := time.After(td.CollectCycle)
+	for {
+		if v, err := td.readValue(ctx); err == nil {
+			td.pushValue(ctx, v)
+			return
+		}
+		select {
+		case <-time.After(reportOnStartRetry):
+		case <-due:
+			return
 		case <-ctx.Done():
 			return
 		}
@@ -0,0 +1,36 @@
+package device
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the twin reports, the parents of the driver read
+// spans. Like the driver it uses the global TracerProvider, a no-op by default.
+var tracer = otel.Tracer("github.com/kubeedge/coap/device")
+
+// startSpan starts a span of the twin as a child of the span in ctx.
+func (td *TwinData) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("device", td.DeviceName),
+		attribute.String("namespace", td.DeviceNamespace),
+		attribute.String("property", td.Name),
+		attribute.String("protocol", "coap"),
+	))
+}
+
+// endSpan records the result of the traced step and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String("result", "error"))
+	} else {
+		span.SetAttributes(attribute.String("result", "ok"))
+	}
+	span.End()
+}
@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"k8s.io/klog/v2"
+)
+
+// validateAcceptFormats checks that every AcceptFormats entry names a built-in
+// codec with a Content-Format, at most once.
+func validateAcceptFormats(cfg VisitorConfigData) error {
+	if len(cfg.AcceptFormats) == 0 {
+		return nil
+	}
+	if cfg.Codec != "" || cfg.BinaryType != "" {
+		return fmt.Errorf("acceptFormats is exclusive with codec and binaryType")
+	}
+	seen := make(map[string]bool, len(cfg.AcceptFormats))
+	for _, f := range cfg.AcceptFormats {
+		if _, ok := codecFormats[f]; !ok {
+			return fmt.Errorf("acceptFormats: unsupported format %q, want text, json or cbor", f)
+		}
+		if seen[f] {
+			return fmt.Errorf("acceptFormats: %q listed twice", f)
+		}
+		seen[f] = true
+	}
+	return nil
+}
+
+// acceptedFormat returns the codec prop is decoded and encoded with under
+// AcceptFormats: the format the server last answered with, the preferred one
+// before any answer. Without AcceptFormats it is the format the device
+// advertises for the resource when the visitor leaves the payload handling to
+// the driver, "" otherwise. Caller must hold deviceMutex.
+func (c *CustomizedClient) acceptedFormat(prop string) string {
+	if f, ok := c.formats[prop]; ok {
+		return f
+	}
+	cfg := c.visitors[prop].VisitorConfigData
+	if len(cfg.AcceptFormats) > 0 {
+		return cfg.AcceptFormats[0]
+	}
+	if cfg.Codec == "" && cfg.BinaryType == "" && cfg.JSONPath == "" && cfg.ArrayMode == "" {
+		return c.advertisedFormat(prop)
+	}
+	return ""
+}
+
+// noteFormat records the format of a response or notification for prop from its
+// Content-Format, when that is one of prop's AcceptFormats. Caller must hold
+// deviceMutex.
+func (c *CustomizedClient) noteFormat(prop string, m *pool.Message) {
+	cf, err := m.ContentFormat()
+	if err != nil {
+		return
+	}
+	for _, f := range c.visitors[prop].VisitorConfigData.AcceptFormats {
+		if codecFormats[f] == cf {
+			if c.formats == nil {
+				c.formats = make(map[string]string)
+			}
+			c.formats[prop] = f
+			return
+		}
+	}
+}
+
+// getAccepting issues the GET of a poll. With AcceptFormats it asks for the
+// formats in order, moving on to the next while the server answers 4.06 Not
+// Acceptable, and returns the format the answer was given for; the answer to
+// the last one is returned as is, with no format. It runs without deviceMutex.
+func (c *CustomizedClient) getAccepting(ctx context.Context, p *pollRequest) (*pool.Message, string, error) {
+	if len(p.formats) == 0 {
+		resp, err := c.get(ctx, p.conn, p.path, p.opts...)
+		return resp, "", err
+	}
+	var resp *pool.Message
+	for _, f := range p.formats {
+		var err error
+		resp, err = c.get(ctx, p.conn, p.path, append(p.opts[:len(p.opts):len(p.opts)], acceptOption(codecFormats[f]))...)
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.Code() != codes.NotAcceptable {
+			return resp, f, nil
+		}
+		klog.V(4).Infof("CoAP GET %s: 4.06 Not Acceptable for %s", p.path, f)
+	}
+	return resp, "", nil
+}
+
+// acceptOption is the Accept option asking for cf.
+func acceptOption(cf message.MediaType) message.Option {
+	buf := make([]byte, 4)
+	n, _ := message.EncodeUint32(buf, uint32(cf))
+	return message.Option{ID: message.Accept, Value: buf[:n]}
+}
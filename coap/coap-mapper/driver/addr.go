@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const defaultCoAPPort = "5683"
+
+// parseAddr accepts either a bare "host:port" or a URL such as "coap://host:port/base".
+// It returns the address to dial and the URL path, which is empty for bare addresses.
+// Only the UDP "coap" scheme is supported.
+func parseAddr(raw string) (hostport, prefix string, err error) {
+	if !strings.Contains(raw, "://") {
+		return raw, "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid addr %q: %v", raw, err)
+	}
+	if u.Scheme != "coap" {
+		return "", "", fmt.Errorf("invalid addr %q: unsupported scheme %q, only coap is supported", raw, u.Scheme)
+	}
+	if u.Host == "" {
+		return "", "", fmt.Errorf("invalid addr %q: missing host", raw)
+	}
+	hostport = u.Host
+	if u.Port() == "" {
+		hostport = net.JoinHostPort(u.Hostname(), defaultCoAPPort)
+	}
+	return hostport, strings.TrimSuffix(u.EscapedPath(), "/"), nil
+}
+
+// prefixPaths prepends prefix to every configured resource path.
+func (c *CustomizedClient) prefixPaths(prefix string) {
+	for _, p := range []*string{&c.ProtocolConfig.MotionPath, &c.ProtocolConfig.LastPath, &c.ProtocolConfig.ClassPath} {
+		*p = path.Join(prefix, *p)
+	}
+	for i := range c.ProtocolConfig.Properties {
+		if p := &c.ProtocolConfig.Properties[i]; !legacyProperties[p.Name] {
+			p.Path = path.Join(prefix, p.Path)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// Reductions of an array payload for ArrayMode.
+const (
+	// ArrayLatest reports the last element.
+	ArrayLatest = "latest"
+	// ArrayJoined reports the elements joined by ArraySeparator.
+	ArrayJoined = "joined"
+	// ArrayCount reports the number of elements.
+	ArrayCount = "count"
+)
+
+const defaultArraySeparator = ","
+
+// reduceArray applies the visitor's ArrayMode to v, the JSONPath result, or to the
+// raw payload of prop when v is nil. Caller must hold deviceMutex.
+func (c *CustomizedClient) reduceArray(prop string, cfg VisitorConfigData, v interface{}) (interface{}, error) {
+	if v == nil {
+		if err := json.Unmarshal(c.rawPayloads[prop], &v); err != nil {
+			return nil, fmt.Errorf("property %s: arrayMode %s needs a JSON array payload: %w", prop, cfg.ArrayMode, err)
+		}
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("property %s: arrayMode %s: value is %T, not an array", prop, cfg.ArrayMode, v)
+	}
+	switch cfg.ArrayMode {
+	case ArrayLatest:
+		if len(arr) == 0 {
+			return nil, fmt.Errorf("property %s: arrayMode latest: array is empty", prop)
+		}
+		return arr[len(arr)-1], nil
+	case ArrayJoined:
+		sep := cfg.ArraySeparator
+		if sep == "" {
+			sep = defaultArraySeparator
+		}
+		parts := make([]string, len(arr))
+		for i, e := range arr {
+			parts[i] = value.Stringify(e)
+		}
+		return strings.Join(parts, sep), nil
+	case ArrayCount:
+		// a JSON number, like every other extracted value
+		return float64(len(arr)), nil
+	}
+	return nil, fmt.Errorf("property %s: invalid arrayMode %q", prop, cfg.ArrayMode)
+}
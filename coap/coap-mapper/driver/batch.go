@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"k8s.io/klog/v2"
+)
+
+// WriteBatch sets several properties in one JSON PUT to BatchPath, e.g.
+// {"class": "person", "motion": true}. The returned map holds an error for every
+// property that was not applied and is nil when all were. Unknown or disabled
+// properties are rejected individually; a failed request fails all the others.
+func (c *CustomizedClient) WriteBatch(values map[string]interface{}) map[string]error {
+	errs := make(map[string]error)
+	failAll := func(err error) map[string]error {
+		for prop := range values {
+			if _, ok := errs[prop]; !ok {
+				errs[prop] = err
+			}
+		}
+		return errs
+	}
+	c.deviceMutex.Lock()
+	path, opts := c.ProtocolConfig.BatchPath, c.requestOpts("")
+	known := make(map[string]bool, len(values))
+	for prop := range values {
+		_, known[prop] = c.resourceFor(prop)
+	}
+	conn := c.conn
+	c.deviceMutex.Unlock()
+	if path == "" {
+		return failAll(fmt.Errorf("no batchPath configured"))
+	}
+
+	doc := make(map[string]interface{}, len(values))
+	for prop, v := range values {
+		switch {
+		case !known[prop]:
+			errs[prop] = c.propertyError(ErrUnknownProperty, prop, nil)
+		case !c.propertyEnabled(prop):
+			errs[prop] = fmt.Errorf("property %s is disabled", prop)
+		default:
+			doc[prop] = v
+		}
+	}
+	if len(doc) == 0 {
+		return errs
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return failAll(fmt.Errorf("marshal batch: %v", err))
+	}
+	if conn == nil {
+		return failAll(ErrNotConnected)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), getTimeout)
+	defer cancel()
+	resp, err := c.put(ctx, conn, path, message.AppJSON, bytes.NewReader(body), opts...)
+	if err != nil {
+		return failAll(fmt.Errorf("PUT %s: %v", path, err))
+	}
+	if code := resp.Code(); code != codes.Changed && code != codes.Created && code != codes.Content {
+		return failAll(fmt.Errorf("PUT %s: device rejected batch with %v", path, code))
+	}
+	klog.V(2).Infof("CoAP batch write %s to %s acknowledged", body, path)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
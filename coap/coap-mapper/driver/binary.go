@@ -0,0 +1,34 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// decodePayload turns a raw payload into the value of prop: the EmptyPayloadValue
+// of the registered visitor for an empty body when it sets one, the integer it
+// encodes when it sets a BinaryType, the value its Codec (or the accepted one of
+// its AcceptFormats) decodes when it sets one, the normalized text otherwise.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) decodePayload(prop string, payload []byte) (string, error) {
+	cfg := c.visitors[prop].VisitorConfigData
+	if cfg.EmptyPayloadValue != "" && len(bytes.TrimSpace(payload)) == 0 {
+		return cfg.EmptyPayloadValue, nil
+	}
+	if f := c.acceptedFormat(prop); f != "" {
+		cfg.Codec = f
+	}
+	if cfg.Codec != "" {
+		return c.decodeCodec(cfg, payload)
+	}
+	if cfg.BinaryType == "" {
+		return c.normalizers.Apply(prop, string(payload)), nil
+	}
+	n, err := value.DecodeBinary(cfg.BinaryType, cfg.Endianness, payload)
+	if err != nil {
+		return "", fmt.Errorf("decode binary payload: %v", err)
+	}
+	return value.Stringify(n), nil
+}
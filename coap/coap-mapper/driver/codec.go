@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+
+	"github.com/kubeedge/mapper-common/pkg/codec"
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// codecFormats is the Content-Format sent with the payloads of the built-in
+// codecs. Registered codecs send application/octet-stream.
+var codecFormats = map[string]message.MediaType{
+	"text": message.TextPlain,
+	"json": message.AppJSON,
+	"cbor": message.AppCBOR,
+}
+
+// decodeCodec decodes payload with the Codec of cfg.
+func (c *CustomizedClient) decodeCodec(cfg VisitorConfigData, payload []byte) (string, error) {
+	cd, err := codec.Lookup(cfg.Codec)
+	if err != nil {
+		return "", err
+	}
+	v, err := cd.Decode(payload, cfg.DataType)
+	if err != nil {
+		return "", err
+	}
+	return value.Stringify(v), nil
+}
+
+// encodePayload turns data into the body written to prop and its Content-Format:
+// encoded by the Codec of the registered visitor or the accepted one of its
+// AcceptFormats, or as plain text without either. Caller must hold deviceMutex.
+func (c *CustomizedClient) encodePayload(prop string, data interface{}) ([]byte, message.MediaType, error) {
+	cfg := c.visitors[prop].VisitorConfigData
+	if f := c.acceptedFormat(prop); f != "" {
+		cfg.Codec = f
+	}
+	if cfg.Codec == "" {
+		return []byte(value.Stringify(data)), message.TextPlain, nil
+	}
+	cd, err := codec.Lookup(cfg.Codec)
+	if err != nil {
+		return nil, 0, err
+	}
+	body, err := cd.Encode(data, cfg.DataType)
+	if err != nil {
+		return nil, 0, fmt.Errorf("encode with codec %s: %v", cfg.Codec, err)
+	}
+	cf, ok := codecFormats[cfg.Codec]
+	if !ok {
+		cf = message.AppOctets
+	}
+	return body, cf, nil
+}
@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// parseResponseCode accepts a CoAP response code in dotted form ("2.03") or by
+// name as printed by go-coap ("Valid", "Content").
+func parseResponseCode(s string) (codes.Code, error) {
+	s = strings.TrimSpace(s)
+	if class, detail, ok := strings.Cut(s, "."); ok {
+		c, err1 := strconv.ParseUint(class, 10, 3)
+		d, err2 := strconv.ParseUint(detail, 10, 5)
+		if err1 != nil || err2 != nil || len(detail) != 2 {
+			return 0, fmt.Errorf("invalid response code %q", s)
+		}
+		return codes.Code(c<<5 | d), nil
+	}
+	code, err := codes.ToCode(s)
+	if err != nil {
+		return 0, fmt.Errorf("unknown response code %q", s)
+	}
+	return code, nil
+}
+
+// parseAcceptableCodes validates AcceptableCodes and caches them per property.
+func (c *CustomizedClient) parseAcceptableCodes() error {
+	c.acceptCodes = make(map[string][]codes.Code, len(c.ProtocolConfig.AcceptableCodes))
+	for key, list := range c.ProtocolConfig.AcceptableCodes {
+		for _, s := range list {
+			code, err := parseResponseCode(s)
+			if err != nil {
+				return fmt.Errorf("acceptableCodes[%s]: %w", key, err)
+			}
+			c.acceptCodes[key] = append(c.acceptCodes[key], code)
+		}
+	}
+	return nil
+}
+
+// acceptable reports whether code counts as a successful read of key.
+// Without a configured list only 2.05 Content is accepted.
+func (c *CustomizedClient) acceptable(key string, code codes.Code) bool {
+	list, ok := c.acceptCodes[key]
+	if !ok {
+		return code == codes.Content
+	}
+	for _, a := range list {
+		if a == code {
+			return true
+		}
+	}
+	return false
+}
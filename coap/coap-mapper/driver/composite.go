@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+)
+
+// validateComposites checks ProtocolConfig.Composites.
+func (c *CustomizedClient) validateComposites() error {
+	for name, parts := range c.ProtocolConfig.Composites {
+		if _, ok := c.resourceFor(name); ok {
+			return fmt.Errorf("composite %s clashes with a built-in property", name)
+		}
+		if len(parts) == 0 {
+			return fmt.Errorf("composite %s has no parts", name)
+		}
+		for field, path := range parts {
+			if path == "" {
+				return fmt.Errorf("composite %s: part %s has no path", name, field)
+			}
+		}
+	}
+	c.compositeParts = make(map[string]map[string]interface{}, len(c.ProtocolConfig.Composites))
+	return nil
+}
+
+// compositeKey identifies one part of a composite in the observe bookkeeping.
+func compositeKey(name, field string) string {
+	return name + "/" + field
+}
+
+// decodePart turns a part payload into a JSON value: numbers, booleans and JSON
+// documents keep their type, anything else is kept as a string.
+func decodePart(body []byte) interface{} {
+	s := strings.TrimSpace(string(body))
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		return v
+	}
+	return s
+}
+
+// compositeHandler returns the observe handler updating one part of a composite.
+func (c *CustomizedClient) compositeHandler(name, field string) func(*pool.Message) {
+	return func(m *pool.Message) {
+		body, _ := m.ReadBody()
+		v := decodePart(body)
+		c.deviceMutex.Lock()
+		parts, ok := c.compositeParts[name]
+		if !ok {
+			parts = make(map[string]interface{})
+			c.compositeParts[name] = parts
+		}
+		old, seen := parts[field]
+		parts[field] = v
+		changed := !seen || fmt.Sprint(old) != fmt.Sprint(v)
+		c.deviceMutex.Unlock()
+		if changed {
+			c.V(LogObserve, 0).InfoS("CoAP composite part changed", "addr", c.ProtocolConfig.Addr, "property", name, "part", field, "old", old, "new", v)
+		}
+	}
+}
+
+// compositeValue returns the JSON document of the composite name with the parts
+// received so far. ok is false if name is not a composite. Caller must hold deviceMutex.
+func (c *CustomizedClient) compositeValue(name string) (value string, ok bool, err error) {
+	if _, ok := c.ProtocolConfig.Composites[name]; !ok {
+		return "", false, nil
+	}
+	b, err := json.Marshal(c.compositeParts[name])
+	if err != nil {
+		return "", true, fmt.Errorf("composite %s: %v", name, err)
+	}
+	if c.compositeParts[name] == nil {
+		b = []byte("{}")
+	}
+	return string(b), true, nil
+}
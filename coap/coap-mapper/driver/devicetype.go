@@ -2,9 +2,18 @@ package driver
 
 import (
 	"context"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/kubeedge/mapper-common/pkg/events"
+	"github.com/kubeedge/mapper-common/pkg/value"
+	"github.com/kubeedge/mapper-common/pkg/visitor"
 	"github.com/kubeedge/mapper-framework/pkg/common"
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/udp"
 	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
 )
 
@@ -22,10 +31,123 @@ type CustomizedClient struct {
 	lastDetected string
 	class string
 	isConnected  bool
+	// last raw payload per property, used for JSON path extraction
+	rawPayloads map[string][]byte
+	// ETag of the last polled response per property, sent on the next GET
+	etags map[string][]byte
+	// recent changes per property, see GetHistory
+	history events.History
+	// cached values of the additional Properties entries
+	values map[string]string
+	// time of the last successful read (GET or notification) per property
+	lastRead map[string]time.Time
+	// observed properties that received a notification since they were
+	// registered. go-coap drops notifications older than the last one
+	// (RFC 7641 §3.4) before the handlers run.
+	notified map[string]bool
+	// notifications received per message ID and token and the retransmissions
+	// among them, see countDuplicate; receivedMutex is taken after deviceMutex
+	receivedMutex          sync.Mutex
+	receivedNotifications  map[receivedKey]time.Time
+	duplicateNotifications int
+	// observed properties whose observation the server ended; read with a GET until
+	// a notification arrives again
+	unobserved map[string]bool
+	// observed properties whose last notification carried Max-Age 0: the value
+	// must not be cached, so reads GET it until a cacheable notification arrives
+	uncacheable map[string]bool
+	// properties not observed or read (see DisableProperties)
+	disabled map[string]bool
+	// visitor configs registered by the twins, used by GetProperty/SetProperty
+	visitors map[string]VisitorConfig
 
 	// CoAP specific fields
 	conn   *udpClient.Conn
 	cancel context.CancelFunc
+	// closed when the connection (or simulation) loop has returned
+	loopDone        chan struct{}
+	shutdownTimeout time.Duration
+	// closed by StopDevice, releases requests waiting for a slot
+	stopped <-chan struct{}
+	// one element per request in flight, see MaxConcurrentRequests
+	requestSlots chan struct{}
+	// observe handler invocations in flight; stopping rejects new ones
+	handlers sync.WaitGroup
+	stopping bool
+	observeRefresh time.Duration
+	// randomizes backoff and health check intervals, see jitter
+	rng           *rand.Rand
+	jitterPercent float64
+	// transmission parameters passed to udp.Dial
+	dialOpts []udp.Option
+	// address currently dialed (Addr or FallbackAddr) and consecutive failures on it
+	activeAddr   string
+	addrFailures int
+	// connection history for Diagnostics
+	everConnected  bool
+	reconnectCount int
+	connectedSince time.Time
+	// address of the current connection, passed to OnDisconnect
+	connAddr string
+	// OnConnect and OnDisconnect, when set before InitDevice, are called with the
+	// address on every connection made and lost. They run without deviceMutex held;
+	// OnDisconnect runs exactly once per connection, including the one StopDevice closes.
+	OnConnect    func(addr string)
+	OnDisconnect func(addr string)
+	// Manager, when set before InitDevice, schedules the health checks and bounds
+	// the dials of this client together with the other clients it manages.
+	Manager *Manager
+	// MessageTap, when set before InitDevice, sees every request sent and every
+	// response and notification received (polls, observes, writes, health checks):
+	// direction is TapRequest or TapResponse, code e.g. "GET" or "Content". Under
+	// OSCORE it sees the inner, decrypted message. It is meant for debugging
+	// interop problems and can be verbose. It runs on its own goroutine without
+	// locks held; messages are dropped if it falls far behind.
+	MessageTap func(direction, code, path string, payloadLen int)
+	tapEvents  chan tapEvent
+	// blockwise GETs in flight by token and the last transfer per path, see trackBlocks
+	blockMutex     sync.Mutex
+	blocksInFlight map[string]*BlockTransfer
+	blockTransfers map[string]BlockTransfer
+	// Subscribe channels per property, see publishEvent
+	events events.Broker
+	// signals runConnectionLoop to drop the connection and redial (see ForceReconnect)
+	reconnect chan struct{}
+	// parsed Uri-Query options per property (and healthQueryKey)
+	queryOpts map[string][]message.Option
+	// Uri-Host/Uri-Port options sent with every request, see parseVirtualHost
+	hostOpts []message.Option
+	// security context of ProtocolConfig.OSCORE, nil without it
+	oscore *oscoreContext
+	// parsed AcceptableCodes
+	acceptCodes     map[string][]codes.Code
+	checkHealthCode bool
+	// latest part values per composite property
+	compositeParts map[string]map[string]interface{}
+	// compiled Normalize pipelines per property
+	normalizers value.Normalizers
+	// tokens read as motion true/false, see parseBoolTokens
+	boolTokens value.BoolTokens
+	// LogLevels as used by V, which runs with and without deviceMutex held
+	logLevels atomic.Pointer[map[string]int]
+	// last parse failure per property under onParseError=reportError
+	parseErrs map[string]error
+	// type inferred per property with InferType, see inferValue
+	inferredTypes value.InferredTypes
+	// model per property, see RegisterModelProperty
+	models map[string]common.ModelProperty
+	// exponential moving average per property with SmoothingAlpha, see smooth
+	averages map[string]float64
+	// format last answered with per property with AcceptFormats, see getAccepting
+	formats map[string]string
+	// link attributes per resource path from /.well-known/core, see ResourceDiscovery
+	links map[string]ResourceInfo
+	// config as given to InitDevice or UpdateConfig, before defaults; see UpdateConfig
+	spec ProtocolConfig
+	// signals runConnectionLoop to bring the observations in line with the resources
+	resync chan struct{}
+	// parsed HealthFailureThreshold
+	healthThreshold int
 }
 
 // ProtocolConfig is the CoAP protocol configuration used by the driver.
@@ -35,16 +157,124 @@ type ProtocolConfig struct {
 }
 // Adding configdata
 type ConfigData struct {
-	Addr    string `json:"addr"`    // e.g. "192.168.8.50:5683"
+	Addr    string `json:"addr"`    // e.g. "192.168.8.50:5683" or "coap://192.168.8.50:5683/sensor"
+	// prepend the path of a URL addr to the resource paths, e.g. "/sensor/motion"
+	PrefixPaths bool `json:"prefixPaths"`
+	// order in which the addresses of a hostname are tried: ipv6first (default), ipv4first, ipv6 or ipv4
+	AddressFamily string `json:"addressFamily"`
+	// Uri-Host/Uri-Port options for a virtual resource when several devices share Addr
+	// behind a proxy; sent with every request, including observes and health checks
+	UriHost string `json:"uriHost"`
+	UriPort int    `json:"uriPort"`
+	// secondary address tried after FallbackAfter (default 3) failed connection attempts;
+	// the primary is probed on every health check and used again once it answers
+	FallbackAddr  string `json:"fallbackAddr"`
+	FallbackAfter int    `json:"fallbackAfter"`
 	// resource paths
 	MotionPath    string `json:"motionPath"`    // "/motion"
 	LastPath      string `json:"lastPath"`      // "/last_detection"
 	ClassPath     string `json:"classPath"`     // "/class"
+	// additional resources; each entry needs a path. See applyProperties for how
+	// entries named motion, last_detection or class relate to the fields above.
+	Properties []PropertyConfig `json:"properties"`
+	BatchPath     string `json:"batchPath"`     // config resource taking a JSON object of property values, see WriteBatch
+	StatusPath    string `json:"statusPath"`    // optional self-reported health, e.g. {"status":"ok"}; used by GetDeviceStates
+	// optional query strings sent as Uri-Query options, e.g. "type=motion&unit=raw"
+	MotionQuery string `json:"motionQuery"`
+	LastQuery   string `json:"lastQuery"`
+	ClassQuery  string `json:"classQuery"`
+	HealthQuery string `json:"healthQuery"` // health check GETs motionPath; defaults to motionQuery
+	// liveness check: "get" (GET motionPath, default), "ping" (empty CoAP ping) or
+	// "wellknown" (GET /.well-known/core); the last two leave sensor resources untouched
+	HealthMode string `json:"healthMode"`
+	// consecutive failed health checks before reconnecting (default 3); after a failure
+	// the next check follows in 2s. A success resets the count.
+	HealthFailureThreshold int `json:"healthFailureThreshold"`
+	// GET /.well-known/core after every connect and keep the rt, if and ct link attributes
+	// per resource (see ResourceInfo): properties without codec then decode with the
+	// advertised json or cbor format, and writes to read-only (core.s, core.rp) resources are refused
+	ResourceDiscovery bool `json:"resourceDiscovery"`
+	// response codes treated as a successful read, keyed by property name or "health",
+	// e.g. {"motion": ["2.05", "2.03"]}. Defaults to 2.05 Content; health accepts any response.
+	AcceptableCodes map[string][]string `json:"acceptableCodes"`
+	// payload clean-up per property applied before conversion, e.g.
+	// {"class": {"stripQuotes": true}}. Properties not listed are only trimmed.
+	Normalize map[string]NormalizeConfig `json:"normalize"`
+	// reaction per property to an empty, truncated or unparsable payload:
+	// keepLast (default), setDefault or reportError, e.g. {"class": "reportError"}
+	OnParseError map[string]string `json:"onParseError"`
+	// payloads read as motion true/false, e.g. {"true": ["1", "open"], "false": ["0", "closed"]};
+	// replaces the defaults in pkg/value. Unlisted payloads still accept true/false.
+	BoolTokens *value.BoolTokens `json:"boolTokens"`
 
 	ObserveMotion bool   `json:"observeMotion"` // true to use CoAP Observe on motion
 	ObserveLast bool   `json:"observeLast"` // true to use CoAP Observe on last_detection
 	ObserveClass bool   `json:"observeClass"` // true to use CoAP Observe on class
+	// GET each observed resource once after registering, so the cache does not wait for the first notification (default: true)
+	SeedObserveWithGet *bool `json:"seedObserveWithGet"`
+	// e.g. "60s"; periodically re-registers observations, a failed re-registration triggers a reconnect
+	ObserveRefreshInterval string `json:"observeRefreshInterval"`
+	// number of recent observed changes kept per property for GetHistory (0 disables)
+	HistorySize int `json:"historySize"`
+	// changes queued per Subscribe channel (default 16) and what happens when a
+	// subscriber falls behind: dropOldest (default) or block
+	EventBuffer   int    `json:"eventBuffer"`
+	EventOverflow string `json:"eventOverflow"`
+	// verbosity per log subsystem (connection, observe, report) overriding -v for
+	// that subsystem, e.g. {"observe": 0} silences notification logs; -1 mutes it
+	LogLevels map[string]int `json:"logLevels"`
+	// state reported to the cloud per device state (ok, disconnected, unhealthy,
+	// unknown), e.g. {"ok": "online", "disconnected": "offline"}; unmapped states
+	// are reported as they are
+	StateMapping map[string]string `json:"stateMapping"`
+	// properties assembled from several observed resources, reported as one JSON object, e.g.
+	// {"detection": {"motion": "/motion", "confidence": "/confidence"}} -> {"confidence":0.8,"motion":true}
+	Composites map[string]map[string]string `json:"composites"`
+	// reconnect when any observe fails to register instead of running partially observed
+	RequireAllObserves bool `json:"requireAllObserves"`
+	// requests sent to the device at the same time: polls, writes, health checks and
+	// observe registrations wait for a free slot (default 1, one at a time). It is
+	// also the CoAP NSTART, the confirmable requests outstanding at once.
+	MaxConcurrentRequests int `json:"maxConcurrentRequests"`
 	Timeout string `json:"timeout"` // e.g. "5s"
+	ShutdownTimeout string `json:"shutdownTimeout"` // max time StopDevice waits for handlers and the connection loop, default "2s"
+	// CoAP retransmission tuning, RFC 7252 defaults "2s" and 4; see parseTransmission
+	AckTimeout    string  `json:"ackTimeout"`    // 100ms..60s
+	MaxRetransmit *uint32 `json:"maxRetransmit"` // 0..10
+	// largest datagram accepted; larger resources are fetched blockwise in blocks that
+	// fit, avoiding IP fragmentation on small-MTU links. See parseMessageSize.
+	MaxMessageSize uint32 `json:"maxMessageSize"`
+	// UDP socket buffer sizes in bytes (default: OS setting)
+	ReadBufferSize  int `json:"readBufferSize"`
+	WriteBufferSize int `json:"writeBufferSize"`
+	// end-to-end OSCORE protection of requests and responses over plain UDP, for
+	// deployments through proxies. The driver has no DTLS transport, so the two
+	// cannot be combined. See OSCOREConfig.
+	OSCORE *OSCOREConfig `json:"oscore"`
+	// spread reconnect backoff and health checks by ±JitterPercent (default 10) to avoid
+	// a fleet reconnecting in lockstep; a non-zero JitterSeed makes the delays reproducible
+	JitterPercent *float64 `json:"jitterPercent"`
+	JitterSeed    int64    `json:"jitterSeed"`
+	// block InitDevice until every property was read once (or InitTimeout, default "10s")
+	WaitForFirstRead      bool   `json:"waitForFirstRead"`
+	InitTimeout           string `json:"initTimeout"`
+	ContinueOnInitTimeout bool   `json:"continueOnInitTimeout"` // on timeout keep connecting in background instead of failing
+
+	// Simulation mode: no dial, values come from a generator (see runSimulation)
+	Simulate         bool     `json:"simulate"`
+	SimulateInterval string   `json:"simulateInterval"` // e.g. "5s", motion toggles at this rate
+	SimulateClasses  []string `json:"simulateClasses"`  // class labels to cycle through
+}
+
+// NormalizeConfig describes how a raw payload is cleaned up before it is converted.
+type NormalizeConfig = value.NormalizeConfig
+
+// PropertyConfig maps a property to a CoAP resource.
+type PropertyConfig struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`    // required, e.g. "/temperature"
+	Observe bool   `json:"observe"` // true to use CoAP Observe
+	Query   string `json:"query"`   // optional Uri-Query string
 }
 
 // VisitorConfig holds property visitor configuration.
@@ -57,4 +287,89 @@ type VisitorConfig struct {
 type VisitorConfigData struct {
 	DataType     string `json:"dataType"`
 	PropertyName string `json:"propertyName"`
+	// JSONPath selects a nested value when the resource returns JSON,
+	// e.g. "sensors.0.motion". See extractJSONPath for the supported subset.
+	JSONPath string `json:"jsonPath"`
+	// ArrayMode reduces a JSON array (the payload, or the JSONPath result) to one
+	// value: latest (last element), joined (elements joined by ArraySeparator,
+	// default ",") or count. Anything but an array is an error.
+	ArrayMode      string `json:"arrayMode"`
+	ArraySeparator string `json:"arraySeparator"`
+	// BinaryType reads the payload as one raw integer (uint8, int8, ... int64) in
+	// Endianness byte order, big (default) or little, instead of as text. The
+	// payload must be exactly the size of the type.
+	BinaryType string `json:"binaryType"`
+	Endianness string `json:"endianness"`
+	// Codec reads and writes the payload with a codec from pkg/codec (json,
+	// text, cbor or one registered by the application) instead of as normalized
+	// text, converting scalars to DataType. Exclusive with BinaryType.
+	Codec string `json:"codec"`
+	// AcceptFormats asks for the payload in these formats (text, json or cbor)
+	// in order of preference with the Accept option, falling back to the next
+	// while the server answers 4.06 Not Acceptable, and decodes it with the
+	// matching codec. Writes use the format last answered with. Observed
+	// resources are decoded by the Content-Format of each notification.
+	// Exclusive with Codec and BinaryType.
+	AcceptFormats []string `json:"acceptFormats"`
+	// Timeout bounds each GET of the property, e.g. "10s" for a slow classifier,
+	// instead of the 3s default. Retries re-issues a GET that failed, timed out or
+	// got a 5.xx answer up to this many times (at most 10) before the read fails.
+	Timeout string `json:"timeout"`
+	Retries int    `json:"retries"`
+	// TimestampFormat parses the value as a timestamp (Unix epoch seconds to
+	// nanoseconds, or RFC 3339) and reports it as rfc3339 (UTC) or epochMillis,
+	// e.g. for last_detection. A value that is no timestamp is a parse error.
+	TimestampFormat string `json:"timestampFormat"`
+	// Aggregate reports the listed properties as one JSON object keyed by property
+	// name, e.g. {"class":"person","motion":true}, as the value of this twin instead
+	// of reading the twin's own resource. Declare the twin as a string in the model.
+	Aggregate []string `json:"aggregate"`
+	// DeviceNameTemplate reports the property under another device name, with
+	// {deviceName}, {namespace} and {property} substituted; NamespaceOverride
+	// replaces the namespace. Both default to the device's own.
+	DeviceNameTemplate string `json:"deviceNameTemplate"`
+	NamespaceOverride  string `json:"namespaceOverride"`
+	// CollectInterval overrides the model's collectCycle for this property, as a
+	// duration such as "500ms" or "1m".
+	CollectInterval string `json:"collectInterval"`
+	// ReportOnStart reports the property once as soon as the device starts,
+	// instead of after the first collect cycle.
+	ReportOnStart bool `json:"reportOnStart"`
+	// ForceRefresh bypasses the observe cache and always issues a GET.
+	ForceRefresh bool `json:"forceRefresh"`
+	// DefaultValue is returned until the first successful read.
+	DefaultValue string `json:"defaultValue"`
+	// EmptyPayloadValue is the value of an empty (or blank) body, for sensors that
+	// answer 2.05 without content to mean "no current detection", e.g. "none",
+	// "false" or "0". It is checked and converted like a received payload. Unset,
+	// an empty body is unusable and handled per onParseError (keepLast by default).
+	EmptyPayloadValue string `json:"emptyPayloadValue"`
+	// ReturnErrorOnStale returns an error instead of the last-known-good value
+	// when a read fails. See fallback for the full precedence.
+	ReturnErrorOnStale bool `json:"returnErrorOnStale"`
+	// NoResponse sends writes fire-and-forget: non-confirmable with the No-Response
+	// option, so DeviceDataWrite does not wait and delivery is not confirmed.
+	NoResponse bool `json:"noResponse"`
+	// WriteMethod is the request method of writes: PUT (default), POST, PATCH or
+	// iPATCH (RFC 8132) to update part of a structured resource. A JSON body is
+	// sent as application/merge-patch+json with PATCH and iPATCH.
+	WriteMethod string `json:"writeMethod"`
+	// Enabled, ValueMap and ValueMapDefault. Values are mapped after extraction.
+	visitor.Options
+	// Deadband skips reports of a numeric value that moved by no more than this
+	// from the last reported value (0 disables; non-numeric values always report).
+	// Every collect cycle reports otherwise, so a skipped cycle leaves the twin at
+	// the last reported value rather than sending a repeat.
+	Deadband float64 `json:"deadband"`
+	// InferType converts the value of a property without DataType to the most
+	// specific of boolean, int, double and string it parses as, instead of
+	// leaving it a string. The same applies to values written to it.
+	InferType bool `json:"inferType"`
+	// SmoothingAlpha (0 to 1) reports the exponential moving average of a numeric
+	// property instead of each sample: avg = alpha*sample + (1-alpha)*avg, updated
+	// on every GET and notification. Smoothing trades responsiveness for calm: a
+	// smaller alpha smooths more and makes the value lag further behind a change,
+	// by about (1-alpha)/alpha samples. The sample stays available as last_raw_<property>.
+	// 0 (default) disables smoothing; it needs the payload itself to be the number.
+	SmoothingAlpha float64 `json:"smoothingAlpha"`
 }
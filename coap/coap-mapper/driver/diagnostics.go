@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"time"
+)
+
+// Diagnostics is a snapshot of the client's connection state.
+type Diagnostics struct {
+	Connected bool
+	// Addr is the address in use, Addr or FallbackAddr.
+	Addr string
+	// ReconnectCount counts the connections made after the first one. A device that
+	// keeps reporting OK while this grows is flapping.
+	ReconnectCount int
+	// ConnectedSince is when the current connection was made, zero while disconnected.
+	ConnectedSince time.Time
+	Uptime         time.Duration
+	// DuplicateNotifications counts the observe notifications received again
+	// with the message ID and token of an earlier one, see countDuplicate.
+	DuplicateNotifications int
+	// Blockwise holds the expected (Size2) and received size of the last blockwise
+	// response per path.
+	Blockwise map[string]BlockTransfer
+}
+
+// markConnected records a new connection. Caller must hold deviceMutex.
+func (c *CustomizedClient) markConnected() {
+	if c.everConnected {
+		c.reconnectCount++
+	}
+	c.everConnected = true
+	c.connectedSince = time.Now()
+}
+
+// Diagnostics returns the current connection state.
+func (c *CustomizedClient) Diagnostics() Diagnostics {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	d := Diagnostics{
+		Connected:      c.isConnected,
+		Addr:           c.activeAddr,
+		ReconnectCount: c.reconnectCount,
+		ConnectedSince: c.connectedSince,
+	}
+	c.receivedMutex.Lock()
+	d.DuplicateNotifications = c.duplicateNotifications
+	c.receivedMutex.Unlock()
+	if !c.connectedSince.IsZero() {
+		d.Uptime = time.Since(c.connectedSince)
+	}
+	c.blockMutex.Lock()
+	defer c.blockMutex.Unlock()
+	if len(c.blockTransfers) > 0 {
+		d.Blockwise = make(map[string]BlockTransfer, len(c.blockTransfers))
+		for path, t := range c.blockTransfers {
+			d.Blockwise[path] = t
+		}
+	}
+	return d
+}
@@ -2,18 +2,24 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
-	"github.com/plgd-dev/go-coap/v3/udp"
+	coapClient "github.com/plgd-dev/go-coap/v3/net/client"
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/klog/v2"
 
+	"github.com/kubeedge/mapper-common/pkg/events"
+	"github.com/kubeedge/mapper-common/pkg/state"
+	"github.com/kubeedge/mapper-common/pkg/value"
 	"github.com/kubeedge/api/apis/devices/v1beta1"
 	"github.com/kubeedge/mapper-framework/pkg/common"
 )
@@ -24,6 +30,8 @@ const (
 	healthInterval = 10 * time.Second
 	healthTimeout  = 1 * time.Second
 	getTimeout     = 3 * time.Second
+	// how long StopDevice waits for observe handlers and the connection loop
+	defaultShutdownTimeout = 2 * time.Second
 )
 
 func NewClient(protocolConfig ProtocolConfig) (*CustomizedClient, error) {
@@ -34,6 +42,17 @@ func NewClient(protocolConfig ProtocolConfig) (*CustomizedClient, error) {
 		lastDetected:   "",
 		class:          "",
 		isConnected:    false,
+		rawPayloads:    make(map[string][]byte),
+		lastRead:       make(map[string]time.Time),
+		etags:          make(map[string][]byte),
+		boolTokens:     value.DefaultBoolTokens,
+		notified:       make(map[string]bool),
+		unobserved:     make(map[string]bool),
+		uncacheable:    make(map[string]bool),
+		blocksInFlight: make(map[string]*BlockTransfer),
+		blockTransfers: make(map[string]BlockTransfer),
+		reconnect:      make(chan struct{}, 1),
+		resync:         make(chan struct{}, 1),
 	}
 	return client, nil
 }
@@ -45,35 +64,157 @@ func (c *CustomizedClient) InitDevice() error {
 	if c.ProtocolConfig.Addr == "" {
 		return fmt.Errorf("addr is required in protocol config")
 	}
-	if c.ProtocolConfig.MotionPath == "" {
-		c.ProtocolConfig.MotionPath = "/motion"
+	c.spec = cloneConfig(c.ProtocolConfig)
+	addr, prefix, err := parseAddr(c.ProtocolConfig.Addr)
+	if err != nil {
+		return err
 	}
-
-	if c.ProtocolConfig.LastPath == "" {
-		c.ProtocolConfig.LastPath = "/last_detection"
+	c.ProtocolConfig.Addr = addr
+	if err := c.parseFallback(); err != nil {
+		return err
+	}
+	if err := c.parseResources(prefix); err != nil {
+		return err
+	}
+	if err := c.validateAddressFamily(); err != nil {
+		return err
+	}
+	if err := c.parseJitter(); err != nil {
+		return err
+	}
+	if c.ProtocolConfig.ShutdownTimeout != "" {
+		d, err := time.ParseDuration(c.ProtocolConfig.ShutdownTimeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid shutdownTimeout %q", c.ProtocolConfig.ShutdownTimeout)
+		}
+		c.shutdownTimeout = d
+	}
+	dialOpts, err := c.parseTransmission()
+	if err != nil {
+		return err
+	}
+	if err := c.parseOSCORE(); err != nil {
+		return err
+	}
+	sizeOpts, err := c.parseMessageSize()
+	if err != nil {
+		return err
+	}
+	c.dialOpts = append(dialOpts, sizeOpts...)
+	c.dialOpts = append(c.dialOpts, receiveMonitor{c})
+	// any response proves liveness unless health codes are configured explicitly
+	_, c.checkHealthCode = c.acceptCodes[healthQueryKey]
+	if err := c.validateHealthMode(); err != nil {
+		return err
+	}
+	if err := c.parseHealthThreshold(); err != nil {
+		return err
+	}
+	if err := c.validateParseErrorModes(); err != nil {
+		return err
+	}
+	if err := events.Validate(c.ProtocolConfig.EventBuffer, c.ProtocolConfig.EventOverflow); err != nil {
+		return err
+	}
+	if err := c.validateLogLevels(); err != nil {
+		return err
+	}
+	c.setLogLevels(c.ProtocolConfig.LogLevels)
+	if err := state.ValidateMapping(c.ProtocolConfig.StateMapping); err != nil {
+		return err
+	}
+	if err := c.parseRequestLimit(); err != nil {
+		return err
+	}
+	if err := c.parseBoolTokens(); err != nil {
+		return err
+	}
+	if c.ProtocolConfig.ObserveRefreshInterval != "" {
+		d, err := time.ParseDuration(c.ProtocolConfig.ObserveRefreshInterval)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid observeRefreshInterval %q", c.ProtocolConfig.ObserveRefreshInterval)
+		}
+		c.observeRefresh = d
 	}
-	if c.ProtocolConfig.ClassPath == "" {
-                c.ProtocolConfig.ClassPath = "/class"
-        }
 
 
 	// parent context for the client lifecycle
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancel = cancel
+	c.stopped = ctx.Done()
+	c.startTap(ctx)
+
+	if c.ProtocolConfig.Simulate {
+		interval, err := simulateInterval(c.ProtocolConfig.SimulateInterval)
+		if err != nil {
+			cancel()
+			return err
+		}
+		klog.Infof("CoAP device running in simulation mode, interval=%v", interval)
+		c.loopDone = make(chan struct{})
+		go func() {
+			defer close(c.loopDone)
+			c.runSimulation(ctx, interval)
+		}()
+		return nil
+	}
 
 	// launch the self-healing loop (will dial, observe, health-check, and reconnect)
-	go c.runConnectionLoop(ctx)
+	c.loopDone = make(chan struct{})
+	go func() {
+		defer close(c.loopDone)
+		c.runConnectionLoop(ctx)
+	}()
+
+	if c.ProtocolConfig.WaitForFirstRead {
+		timeout, err := initTimeout(c.ProtocolConfig.InitTimeout)
+		if err != nil {
+			cancel()
+			return err
+		}
+		if err := c.waitForFirstRead(ctx, timeout); err != nil {
+			if c.ProtocolConfig.ContinueOnInitTimeout {
+				klog.Warningf("CoAP device %s: %v, continuing in background", c.ProtocolConfig.Addr, err)
+				return nil
+			}
+			cancel()
+			c.closeConn()
+			return err
+		}
+		klog.InfoS("CoAP initial read complete", "addr", c.ProtocolConfig.Addr)
+	}
 
 	return nil
 }
 
 func (c *CustomizedClient) StopDevice() error {
 	klog.Infof("Stopping CoAP device")
+	timeout := c.shutdownTimeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
 	if c.cancel != nil {
 		c.cancel()
 	}
+	// let late notifications finish before the connection goes away
+	if !c.drainHandlers(timeout) {
+		klog.Warningf("CoAP observe handlers still running after %v, closing anyway", timeout)
+	}
 	c.closeConn()
-	klog.Infof("CoAP client disconnected")
+	c.events.Close()
+	c.V(LogConnection, 0).InfoS("CoAP client disconnected", "addr", c.ProtocolConfig.Addr)
+
+	// the loop may be blocked in a dial or request until its own timeout
+	if c.loopDone != nil {
+		select {
+		case <-c.loopDone:
+		case <-deadline.C:
+			return fmt.Errorf("CoAP connection loop for %s did not exit within %v", c.ProtocolConfig.Addr, timeout)
+		}
+	}
 	return nil
 }
 
@@ -87,107 +228,231 @@ func (c *CustomizedClient) runConnectionLoop(ctx context.Context) {
 		}
 
 		// Dial
-		conn, err := udp.Dial(c.ProtocolConfig.Addr)
+		addr := c.currentAddr()
+		conn, err := c.dial(ctx, addr)
 		if err != nil {
-			klog.Warningf("CoAP dial %s failed: %v", c.ProtocolConfig.Addr, err)
-			if !c.sleepOrExit(ctx, backoff) {
+			klog.ErrorS(err, "CoAP dial failed", "addr", addr, "backoff", backoff)
+			c.connectFailed()
+			if !c.sleepOrExit(ctx, c.jitter(backoff)) {
 				return
 			}
 			backoff = nextBackoff(backoff)
 			continue
 		}
 
+		// a request left over from the previous connection is already satisfied
+		select {
+		case <-c.reconnect:
+		default:
+		}
 		c.deviceMutex.Lock()
 		c.conn = conn
 		c.isConnected = true
+		c.markConnected()
+		c.connAddr = addr
 		c.deviceMutex.Unlock()
-		klog.Infof("CoAP connected successfully to %s", c.ProtocolConfig.Addr)
-		backoff = minBackoff
+		c.V(LogConnection, 0).InfoS("CoAP connected", "addr", addr)
+		if c.OnConnect != nil {
+			c.OnConnect(addr)
+		}
+		if c.ProtocolConfig.ResourceDiscovery {
+			c.discoverResources(ctx, conn)
+		}
 
 		// Set up Observe if enabled
-		obsCancels := []context.CancelFunc{}
-		setupObs := func(path string, handler func(*pool.Message)) error {
+		observations := map[string]coapClient.Observation{}
+		obsTargets := map[string]observeTarget{}
+		// one per property: cancelling it ends the handlers of its registration
+		obsCancel := map[string]context.CancelFunc{}
+		cancelObs := func() {
+			for _, cancel := range obsCancel {
+				cancel()
+			}
+		}
+		obsEnded := make(chan string)
+		setupObs := func(t observeTarget) error {
+			if cancel, ok := obsCancel[t.prop]; ok {
+				cancel()
+			}
 			obsCtx, cancel := context.WithCancel(ctx)
-			obsCancels = append(obsCancels, cancel)
-			_, err := conn.Observe(obsCtx, path, handler)
-			return err
+			obsCancel[t.prop] = cancel
+			c.resetNotified(t.prop)
+			handler := c.watchObserveEnd(obsCtx, t.prop, obsEnded, t.handler)
+			obs, err := c.observe(obsCtx, conn, t.path, c.trackHandler(handler), t.opts...)
+			if err != nil {
+				return err
+			}
+			observations[t.prop] = obs
+			obsTargets[t.prop] = t
+			return nil
 		}
-
-		if c.ProtocolConfig.ObserveMotion {
-			if err := setupObs(c.ProtocolConfig.MotionPath, func(m *pool.Message) {
-				val := parseBoolPayload(m)
-				c.deviceMutex.Lock()
-				old := c.motion
-				c.motion = val
-				c.deviceMutex.Unlock()
-				if old != val {
-					klog.Infof("CoAP observe motion: %v", val)
+		// syncObs brings the observations in line with the resources after
+		// UpdateConfig: it cancels the ones whose resource changed or is no
+		// longer observed and registers the missing ones.
+		syncObs := func() error {
+			want := map[string]observeTarget{}
+			for _, t := range c.observeTargets() {
+				want[t.prop] = t
+			}
+			for prop, obs := range observations {
+				if t, ok := want[prop]; ok && t.same(obsTargets[prop]) {
+					continue
+				}
+				cctx, cancel := context.WithTimeout(ctx, healthTimeout)
+				_ = obs.Cancel(cctx)
+				cancel()
+				obsCancel[prop]()
+				delete(obsCancel, prop)
+				c.V(LogObserve, 0).Infof("Stopped observing %s for %s", obsTargets[prop].path, prop)
+				delete(observations, prop)
+				delete(obsTargets, prop)
+			}
+			var errs []error
+			for prop, t := range want {
+				if _, ok := observations[prop]; ok {
+					continue
+				}
+				if err := setupObs(t); err != nil {
+					errs = append(errs, fmt.Errorf("observe %s: %w", t.path, err))
+					continue
+				}
+				c.V(LogObserve, 0).Infof("Observing %s", t.path)
+				if t.seed {
+					c.seedObserved(t.res)
 				}
-			}); err != nil {
-				klog.Warningf("Observe %s failed: %v", c.ProtocolConfig.MotionPath, err)
-			} else {
-				klog.Infof("Observing %s", c.ProtocolConfig.MotionPath)
 			}
+			return errors.Join(errs...)
 		}
-
-		if c.ProtocolConfig.ObserveLast {
-			if err := setupObs(c.ProtocolConfig.LastPath, func(m *pool.Message) {
-				body, _ := m.ReadBody()
-				val := strings.TrimSpace(string(body))
-				c.deviceMutex.Lock()
-				c.lastDetected = val
-				c.deviceMutex.Unlock()
-				klog.Infof("CoAP observe last_detected: %s", val)
-			}); err != nil {
-				klog.Warningf("Observe %s failed: %v", c.ProtocolConfig.LastPath, err)
-			} else {
-				klog.Infof("Observing %s", c.ProtocolConfig.LastPath)
+		// refreshObs cancels and re-registers every observation; a failed
+		// registration means the server is gone and the caller should reconnect.
+		refreshObs := func() error {
+			for prop, obs := range observations {
+				cctx, cancel := context.WithTimeout(ctx, healthTimeout)
+				_ = obs.Cancel(cctx)
+				cancel()
+				if err := setupObs(obsTargets[prop]); err != nil {
+					return fmt.Errorf("re-register observe %s: %w", obsTargets[prop].path, err)
+				}
 			}
+			return nil
 		}
 
-		if c.ProtocolConfig.ObserveClass {
-			if err := setupObs(c.ProtocolConfig.ClassPath, func(m *pool.Message) {
-				body, _ := m.ReadBody()
-				val := strings.TrimSpace(string(body))
-				c.deviceMutex.Lock()
-				c.class = val
-				c.deviceMutex.Unlock()
-				klog.Infof("CoAP observe class: %s", val)
-			}); err != nil {
-				klog.Warningf("Observe %s failed: %v", c.ProtocolConfig.ClassPath, err)
-			} else {
-				klog.Infof("Observing %s", c.ProtocolConfig.ClassPath)
+		var obsErr error
+		for _, t := range c.observeTargets() {
+			if err := setupObs(t); err != nil {
+				klog.Warningf("Observe %s failed: %v", t.path, err)
+				if obsErr == nil {
+					obsErr = fmt.Errorf("observe %s: %w", t.path, err)
+				}
+				continue
+			}
+			if t.composite != "" {
+				c.V(LogObserve, 0).Infof("Observing %s for composite %s", t.path, t.composite)
+				continue
+			}
+			c.V(LogObserve, 0).Infof("Observing %s", t.path)
+			if t.seed {
+				c.seedObserved(t.res)
 			}
 		}
+		// don't run partially observed: tear down and retry the whole setup
+		if obsErr != nil && c.ProtocolConfig.RequireAllObserves {
+			klog.ErrorS(obsErr, "CoAP observe setup incomplete, reconnecting", "addr", addr)
+			c.connectFailed()
+			cancelObs()
+			c.closeConn()
+			if !c.sleepOrExit(ctx, c.jitter(backoff)) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+		// only a complete setup resets the backoff, so repeated observe
+		// failures back off like failed dials
+		backoff = minBackoff
 
 		// Health-check loop
-		healthTicker := time.NewTicker(healthInterval)
-		ok := true
+		healthTimer := c.newHealthTimer(c.jitter(healthInterval))
+		var refreshTicker *time.Ticker
+		var refreshC <-chan time.Time
+		if c.observeRefresh > 0 && len(observations) > 0 {
+			refreshTicker = time.NewTicker(c.observeRefresh)
+			refreshC = refreshTicker.C
+		}
+		stopTickers := func() {
+			healthTimer.Stop()
+			if refreshTicker != nil {
+				refreshTicker.Stop()
+			}
+		}
+		ok, forced := true, false
+		healthFailures := 0
 		for ok {
 			select {
 			case <-ctx.Done():
-				healthTicker.Stop()
-				for _, cancel := range obsCancels {
-					cancel()
-				}
+				stopTickers()
+				cancelObs()
 				return
-			case <-healthTicker.C:
-				hctx, cancel := context.WithTimeout(ctx, healthTimeout)
-				_, err := conn.Get(hctx, c.ProtocolConfig.MotionPath)
-				cancel()
-				if err != nil {
-					klog.Warningf("CoAP health check failed: %v (will reconnect)", err)
+			case <-healthTimer.C:
+				if err := c.healthCheck(ctx, conn); err != nil {
+					healthFailures++
+					if healthFailures < c.healthThreshold {
+						klog.Warningf("CoAP health check on %s failed (%d of %d): %v", addr, healthFailures, c.healthThreshold, err)
+						healthTimer.Reset(c.jitter(healthRetryInterval))
+						break
+					}
+					klog.ErrorS(err, "CoAP health check failed, reconnecting", "addr", addr, "failures", healthFailures)
+					c.connectFailed()
 					ok = false
+					break
 				}
+				healthFailures = 0
+				healthTimer.Reset(c.jitter(healthInterval))
+				c.connectSucceeded()
+				if c.primaryRecovered(ctx) {
+					c.switchAddr(c.ProtocolConfig.Addr, "primary recovered")
+					forced = true
+					ok = false
+				}
+			case <-c.reconnect:
+				c.V(LogConnection, 0).InfoS("CoAP reconnect requested", "addr", addr)
+				forced = true
+				ok = false
+			case <-refreshC:
+				if err := refreshObs(); err != nil {
+					klog.ErrorS(err, "CoAP observe refresh failed, reconnecting", "addr", addr)
+					c.connectFailed()
+					ok = false
+				} else {
+					c.V(LogObserve, 2).Infof("CoAP observe registrations refreshed")
+				}
+			case <-c.resync:
+				if err := syncObs(); err != nil {
+					klog.Warningf("CoAP observe update incomplete: %v", err)
+					if c.ProtocolConfig.RequireAllObserves {
+						c.connectFailed()
+						ok = false
+					}
+				}
+				if c.observeRefresh > 0 && refreshTicker == nil && len(observations) > 0 {
+					refreshTicker = time.NewTicker(c.observeRefresh)
+					refreshC = refreshTicker.C
+				}
+			case prop := <-obsEnded:
+				c.observationEnded(ctx, prop, observations, func() error {
+					return setupObs(obsTargets[prop])
+				})
 			}
 		}
+		stopTickers()
 
 		// Leave observe, close connection, backoff, then retry
-		for _, cancel := range obsCancels {
-			cancel()
-		}
+		cancelObs()
 		c.closeConn()
-		if !c.sleepOrExit(ctx, backoff) {
+		if forced {
+			continue
+		}
+		if !c.sleepOrExit(ctx, c.jitter(backoff)) {
 			return
 		}
 		backoff = nextBackoff(backoff)
@@ -195,14 +460,42 @@ func (c *CustomizedClient) runConnectionLoop(ctx context.Context) {
 }
 
 
-func (c *CustomizedClient) closeConn() {
+// ForceReconnect drops the current connection and observations and redials right away
+// instead of waiting for the health check to notice a failure. It is safe to call
+// concurrently and does nothing while the client is not connected, since the
+// connection loop is already dialing or backing off then.
+func (c *CustomizedClient) ForceReconnect() error {
 	c.deviceMutex.Lock()
-	defer c.deviceMutex.Unlock()
-	if c.conn != nil {
-		_ = c.conn.Close()
-		c.conn = nil
+	connected := c.isConnected && c.conn != nil
+	c.deviceMutex.Unlock()
+	if !connected {
+		c.V(LogConnection, 2).Infof("CoAP reconnect already in progress for %s", c.ProtocolConfig.Addr)
+		return nil
+	}
+	select {
+	case c.reconnect <- struct{}{}:
+	default:
+		// a request is already pending
 	}
+	return nil
+}
+
+// closeConn closes the current connection, if any, and then calls OnDisconnect.
+// Only the call that finds the connection open calls it.
+func (c *CustomizedClient) closeConn() {
+	c.deviceMutex.Lock()
+	conn, addr := c.conn, c.connAddr
+	c.conn = nil
 	c.isConnected = false
+	c.connectedSince = time.Time{}
+	c.deviceMutex.Unlock()
+	if conn == nil {
+		return
+	}
+	_ = conn.Close()
+	if c.OnDisconnect != nil {
+		c.OnDisconnect(addr)
+	}
 }
 
 
@@ -225,87 +518,246 @@ func (c *CustomizedClient) sleepOrExit(ctx context.Context, d time.Duration) boo
 	}
 }
 
-func parseBoolPayload(m *pool.Message) bool {
-	body, _ := m.ReadBody()
-	s := strings.TrimSpace(strings.ToLower(string(body)))
-	switch s {
-	case "true", "1", "on", "yes", "y", "motion", "motion_detected":
-		return true
-	case "false", "0", "off", "no", "n", "no_motion":
-		return false
-	default:
-		// best-effort: try to parse JSON "true"/"false"
-		b, err := strconv.ParseBool(s)
-		if err == nil {
-			return b
-		}
-		return false
-	}
+// GetDeviceData returns device data for a specific property
+func (c *CustomizedClient) GetDeviceData(visitor *VisitorConfig) (interface{}, error) {
+	return c.GetDeviceDataContext(context.Background(), visitor)
 }
 
+// GetDeviceDataContext is GetDeviceData traced as a child of the span in ctx.
+func (c *CustomizedClient) GetDeviceDataContext(ctx context.Context, visitor *VisitorConfig) (v interface{}, err error) {
+	klog.V(2).Infof("GetDeviceData called for property: %s", visitor.VisitorConfigData.PropertyName)
+	ctx, span := c.startSpan(ctx, "GetDeviceData", visitor.VisitorConfigData.PropertyName)
+	defer func() { endSpan(span, err) }()
+	readErr := c.refreshProperty(ctx, visitor)
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return c.readProperty(visitor, readErr)
+}
 
-// GetDeviceData returns device data for a specific property
-func (c *CustomizedClient) GetDeviceData(visitor *VisitorConfig) (interface{}, error) {
+// refreshProperty reads the property of visitor from the device when its value
+// cannot be served from the cache: it is polled, or observed but its visitor
+// sets ForceRefresh, the server ended the observation or said the value must
+// not be cached. It returns the error of the GET, nil when none was needed.
+// Caller must not hold deviceMutex.
+func (c *CustomizedClient) refreshProperty(ctx context.Context, visitor *VisitorConfig) error {
 	prop := visitor.VisitorConfigData.PropertyName
-	klog.V(2).Infof("GetDeviceData called for property: %s", prop)
 	c.deviceMutex.Lock()
-	defer c.deviceMutex.Unlock()
+	r, ok := c.resourceFor(prop)
+	// forceRefresh issues a direct GET even for observed properties; the observation
+	// itself is left untouched and the result is merged into the cache under the lock.
+	fetch := ok && c.propertyEnabled(prop) &&
+		(!r.observe || visitor.VisitorConfigData.ForceRefresh || c.unobserved[prop] || c.uncacheable[prop])
+	c.deviceMutex.Unlock()
+	if !fetch {
+		return nil
+	}
+	return c.poll(ctx, prop, r.path)
+}
 
-	switch prop {
-	case "motion":
-		// If observe enabled, just return cached state.
-		if c.ProtocolConfig.ObserveMotion && c.conn != nil {
-			if v, ok := c.pollBool(c.ProtocolConfig.MotionPath); ok {
-				c.motion = v
-			}
+// readProperty is GetDeviceData without the locking and the GET: it returns the
+// cached value of the property of visitor, readErr being the result of its
+// refreshProperty. Caller must hold deviceMutex.
+func (c *CustomizedClient) readProperty(visitor *VisitorConfig, readErr error) (interface{}, error) {
+	prop := visitor.VisitorConfigData.PropertyName
+
+	// last_raw_<property> reports the body as received, for debugging payload parsing
+	if raw, ok := strings.CutPrefix(prop, rawPropertyPrefix); ok {
+		if _, known := c.resourceFor(raw); !known {
+			return nil, c.propertyError(ErrUnknownProperty, prop, nil)
 		}
-		return c.motion, nil
+		return string(c.rawPayloads[raw]), nil
+	}
 
-	case "last_detection":
-		if !c.ProtocolConfig.ObserveLast && c.conn != nil {
-			if v, ok := c.pollString(c.ProtocolConfig.LastPath); ok {
-				c.lastDetected = v
-			}
+	if !c.propertyEnabled(prop) {
+		return nil, fmt.Errorf("property %s is disabled", prop)
+	}
+	if v, ok, err := c.compositeValue(prop); ok {
+		return v, err
+	}
+	if _, ok := c.resourceFor(prop); !ok {
+		return nil, c.propertyError(ErrUnknownProperty, prop, nil)
+	}
+	if err := c.parseErrs[prop]; err != nil {
+		return nil, c.propertyError(ErrConversion, prop, err)
+	}
+	if v, ok, err := c.fallback(visitor, prop, readErr); ok {
+		return v, err
+	}
+	cfg := visitor.VisitorConfigData
+	// the extracted value is the visitor's own: the cache keeps the payload as
+	// received, for the other visitors and for 2.03 Valid answers
+	var extracted interface{}
+	if cfg.JSONPath != "" {
+		v, err := c.extractCached(prop, cfg.JSONPath)
+		if err != nil {
+			return nil, err
 		}
-		return c.lastDetected, nil
+		extracted = v
+	}
+	if cfg.ArrayMode != "" {
+		v, err := c.reduceArray(prop, cfg, extracted)
+		if err != nil {
+			return nil, err
+		}
+		extracted = v
+	}
+	v := c.cachedValue(prop)
+	if cfg.JSONPath != "" || cfg.ArrayMode != "" {
+		v = c.propertyValue(prop, extracted)
+	}
+	return c.inferValue(cfg, cfg.MapValue(v)), nil
+}
 
-        case "class":
-                if !c.ProtocolConfig.ObserveClass && c.conn != nil {
-                        if v, ok := c.pollString(c.ProtocolConfig.ClassPath); ok {
-                                c.class = v
-                        }
-                }
-                return c.class, nil
-	default:
-		return nil, fmt.Errorf("unknown property: %s", prop)
+// seedObserved reads the current value of an observed resource once, for servers
+// that only notify on the next change. A notification that already arrived wins.
+func (c *CustomizedClient) seedObserved(r resource) {
+	c.deviceMutex.Lock()
+	notified := c.notified[r.prop]
+	c.deviceMutex.Unlock()
+	if notified {
+		return
+	}
+	if err := c.poll(context.Background(), r.prop, r.path); err == nil {
+		c.V(LogObserve, 2).InfoS("CoAP observe seeded with GET", "addr", c.ProtocolConfig.Addr, "property", r.prop)
+	}
+}
+
+// observeHandler returns the notification handler that updates prop's cached value.
+func (c *CustomizedClient) observeHandler(prop string) func(*pool.Message) {
+	return func(m *pool.Message) {
+		_, span := c.startSpan(context.Background(), "CoAP observe", prop)
+		defer endSpan(span, nil)
+		body, _ := m.ReadBody()
+		c.deviceMutex.Lock()
+		old := c.cachedValue(prop)
+		c.noteFormat(prop, m)
+		c.noteMaxAge(prop, m)
+		c.applyPayload(prop, body)
+		val := c.cachedValue(prop)
+		if old != val {
+			c.recordHistory(prop, val)
+		}
+		c.deviceMutex.Unlock()
+		if old != val {
+			c.publishEvent(prop, val)
+			c.V(LogObserve, 0).InfoS("CoAP observe value changed", "addr", c.ProtocolConfig.Addr, "property", prop, "old", old, "new", val)
+		} else {
+			c.V(LogObserve, 2).InfoS("CoAP observe notification", "addr", c.ProtocolConfig.Addr, "property", prop, "value", val)
+		}
 	}
 }
 
-func (c *CustomizedClient) pollBool(path string) (bool, bool) {
-	ctx, cancel := context.WithTimeout(context.Background(), getTimeout)
-	defer cancel()
+// pollRequest is a GET of a property prepared under deviceMutex, so that it can
+// be sent without holding it.
+type pollRequest struct {
+	prop, path string
+	conn       *udpClient.Conn
+	opts       []message.Option
+	// AcceptFormats of the visitor, see getAccepting
+	formats []string
+	// per-attempt timeout and retries, see getRetrying
+	timeout time.Duration
+	retries int
+	// an ETag is sent along
+	conditional bool
+	// lastRead of the property when the request was prepared
+	lastRead time.Time
+}
+
+// newPoll prepares the GET of prop on path. When the previous response carried
+// an ETag it is sent along, so the server can answer 2.03 Valid without a body
+// if nothing changed. Caller must hold deviceMutex.
+func (c *CustomizedClient) newPoll(prop, path string) (*pollRequest, error) {
 	if c.conn == nil {
-		return false, false
+		return nil, c.propertyError(ErrNotConnected, prop, nil)
+	}
+	p := &pollRequest{
+		prop:     prop,
+		path:     path,
+		conn:     c.conn,
+		opts:     c.requestOpts(prop),
+		formats:  c.visitors[prop].VisitorConfigData.AcceptFormats,
+		lastRead: c.lastRead[prop],
 	}
-	resp, err := c.conn.Get(ctx, path)
-	if err != nil || resp.Code() != codes.Content {
-		return false, false
+	p.timeout, p.retries = c.requestPolicy(prop)
+	if etag, ok := c.etags[prop]; ok {
+		p.opts = append(append([]message.Option(nil), p.opts...), message.Option{ID: message.ETag, Value: etag})
+		p.conditional = true
 	}
-	return parseBoolPayload(resp), true
+	return p, nil
 }
 
-func (c *CustomizedClient) pollString(path string) (string, bool) {
-	ctx, cancel := context.WithTimeout(context.Background(), getTimeout)
-	defer cancel()
-	if c.conn == nil {
-		return "", false
+// poll issues a GET on path for prop, traced as a child of the span in ctx, and
+// applies the response body to prop's cached value, or returns a PropertyError
+// saying why there is none. A failed GET is re-issued per the Retries of prop's
+// visitor, see getRetrying. deviceMutex is taken to prepare the request and to
+// apply the answer, not while the request is in flight, so a slow device does
+// not hold up notifications, writes and Diagnostics. Caller must not hold
+// deviceMutex.
+//
+// On a 2.03 Valid answer to a conditional GET the cached payload is applied
+// again. When prop was read otherwise while the GET was in flight, typically by
+// a notification, that value is the newer one and is kept.
+func (c *CustomizedClient) poll(ctx context.Context, prop, path string) (err error) {
+	ctx, span := c.startSpan(ctx, "CoAP GET", prop)
+	span.SetAttributes(attribute.String("path", path))
+	defer func() { endSpan(span, err) }()
+
+	c.deviceMutex.Lock()
+	p, err := c.newPoll(prop, path)
+	c.deviceMutex.Unlock()
+	if err != nil {
+		return err
 	}
-	resp, err := c.conn.Get(ctx, path)
-	if err != nil || resp.Code() != codes.Content {
-		return "", false
+	resp, format, err := c.getRetrying(ctx, p)
+	if err != nil {
+		return c.requestError(prop, fmt.Errorf("GET %s: %w", path, err))
+	}
+
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	if format != "" {
+		if c.formats == nil {
+			c.formats = make(map[string]string)
+		}
+		// a response without Content-Format is taken to be in the requested one
+		c.formats[prop] = format
+		c.noteFormat(prop, resp)
+	}
+	if !c.lastRead[prop].Equal(p.lastRead) {
+		klog.V(4).Infof("CoAP GET %s: %s was read meanwhile, keeping that value", path, prop)
+		return nil
+	}
+	if p.conditional && resp.Code() == codes.Valid {
+		klog.V(4).Infof("CoAP GET %s: 2.03 Valid, keeping cached payload", path)
+		c.applyPayload(prop, c.rawPayloads[prop])
+		return nil
+	}
+	if !c.acceptable(prop, resp.Code()) {
+		return fmt.Errorf("property %s: GET %s answered %v", prop, path, resp.Code())
+	}
+	if tag, err := resp.ETag(); err == nil {
+		c.etags[prop] = append([]byte(nil), tag...)
+	} else {
+		delete(c.etags, prop)
 	}
 	body, _ := resp.ReadBody()
-	return strings.TrimSpace(string(body)), true
+	c.applyPayload(prop, body)
+	return nil
+}
+
+// extractCached applies a JSON path to the last raw payload seen for prop.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) extractCached(prop, path string) (interface{}, error) {
+	body, ok := c.rawPayloads[prop]
+	if !ok || len(body) == 0 {
+		return nil, fmt.Errorf("property %s: no payload received yet to evaluate json path %q", prop, path)
+	}
+	v, err := extractJSONPath(body, path)
+	if err != nil {
+		return nil, fmt.Errorf("property %s: %w", prop, err)
+	}
+	return v, nil
 }
 
 
@@ -318,7 +770,8 @@ func (c *CustomizedClient) pollString(path string) (string, bool) {
 
 func (c *CustomizedClient) DeviceDataWrite(visitor *VisitorConfig, deviceMethodName string, propertyName string, data interface{}) error {
 	klog.V(3).Infof("DeviceDataWrite called for property: %s with data: %v", propertyName, data)
-	return nil
+	_, err := c.WriteProperty(propertyName, data, visitor.VisitorConfigData.NoResponse)
+	return err
 }
 
 func (c *CustomizedClient) SetDeviceData(data interface{}, visitor *VisitorConfig) error {
@@ -328,13 +781,36 @@ func (c *CustomizedClient) SetDeviceData(data interface{}, visitor *VisitorConfi
 
 func (c *CustomizedClient) GetDeviceStates() (string, error) {
 	c.deviceMutex.Lock()
-	connected := c.isConnected && c.conn != nil
+	connected := c.isConnected && (c.conn != nil || c.ProtocolConfig.Simulate)
+	statusPath := c.ProtocolConfig.StatusPath
 	c.deviceMutex.Unlock()
 
+	// with a status resource the device's own report decides, not just the link
+	if connected && statusPath != "" && !c.ProtocolConfig.Simulate {
+		connected = c.statusFromDevice(statusPath)
+	}
+
+	state := common.DeviceStatusDisCONN
 	if connected {
-		return common.DeviceStatusOK, nil
+		state = common.DeviceStatusOK
 	}
-	return common.DeviceStatusDisCONN, nil
+	d := c.Diagnostics()
+	c.V(LogConnection, 2).InfoS("CoAP device state", "state", state, "addr", d.Addr, "reconnects", d.ReconnectCount, "uptime", d.Uptime.Round(time.Second))
+	return state, nil
+}
+
+// GetDMIState returns the device state as the cloud expects it: the state of
+// GetDeviceStates, unknown when that fails, translated with StateMapping.
+// States without a mapping are reported as they are.
+func (c *CustomizedClient) GetDMIState() string {
+	s, err := c.GetDeviceStates()
+	if err != nil {
+		klog.Errorf("GetDeviceStates failed: %v", err)
+		s = common.DeviceStatusUnknown
+	}
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return state.Translate(c.ProtocolConfig.StateMapping, s)
 }
 
 // -------- Parsing helpers (kubeedge/api v1beta1) --------
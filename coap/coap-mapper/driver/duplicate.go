@@ -0,0 +1,51 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+)
+
+// exchangeLifetime is how long a peer may send a message again with the same
+// message ID (EXCHANGE_LIFETIME, RFC 7252 §4.8.2).
+const exchangeLifetime = 247 * time.Second
+
+// receivedKey identifies a received notification: a retransmission repeats
+// both its message ID and its token.
+type receivedKey struct {
+	mid   int32
+	token string
+}
+
+// countDuplicate counts m when it is an observe notification received before
+// with the same message ID and token: a CON notification sent again because
+// its ACK got lost, or a NON one the network duplicated. go-coap acknowledges
+// and drops those before the observe handlers run, so they are counted as they
+// arrive, from monitorReceived.
+func (c *CustomizedClient) countDuplicate(m *pool.Message) {
+	if m.Type() != message.Confirmable && m.Type() != message.NonConfirmable {
+		return
+	}
+	if _, err := m.Observe(); err != nil {
+		return
+	}
+	key := receivedKey{mid: m.MessageID(), token: string(m.Token())}
+	now := time.Now()
+	c.receivedMutex.Lock()
+	defer c.receivedMutex.Unlock()
+	if at, ok := c.receivedNotifications[key]; ok && now.Sub(at) < exchangeLifetime {
+		c.duplicateNotifications++
+		c.V(LogObserve, 2).Infof("CoAP observe: duplicate notification mid=%d", key.mid)
+		return
+	}
+	if c.receivedNotifications == nil {
+		c.receivedNotifications = make(map[receivedKey]time.Time)
+	}
+	for k, at := range c.receivedNotifications {
+		if now.Sub(at) >= exchangeLifetime {
+			delete(c.receivedNotifications, k)
+		}
+	}
+	c.receivedNotifications[key] = now
+}
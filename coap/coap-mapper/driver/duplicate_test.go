@@ -0,0 +1,34 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// TestDuplicateNotifications checks that a notification received again with
+// the same message ID is counted, although go-coap drops it before the handler.
+func TestDuplicateNotifications(t *testing.T) {
+	s := newTestServer(t)
+	s.handle("/motion", func() string { return "0" })
+	c := startClient(t, ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, ObserveMotion: true}})
+	waitFor(t, "observe /motion", func() bool {
+		_, ok := s.observer("/motion")
+		return ok
+	})
+
+	mid := s.notify(t, "/motion", 2, codes.Content, "1")
+	s.notifyMID(t, "/motion", mid, 2, codes.Content, "1")
+	waitFor(t, "duplicate counted", func() bool { return c.Diagnostics().DuplicateNotifications == 1 })
+
+	s.notify(t, "/motion", 3, codes.Content, "0")
+	waitFor(t, "motion false", func() bool {
+		v, err := c.GetProperty("motion")
+		return err == nil && v == false
+	})
+	time.Sleep(50 * time.Millisecond)
+	if n := c.Diagnostics().DuplicateNotifications; n != 1 {
+		t.Fatalf("DuplicateNotifications = %d after a new notification, want 1", n)
+	}
+}
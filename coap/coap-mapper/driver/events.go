@@ -0,0 +1,47 @@
+package driver
+
+import "github.com/kubeedge/mapper-common/pkg/events"
+
+// Sample is one recorded value of a property.
+type Sample = events.Sample
+
+// Policies for a subscriber that falls behind, see EventOverflow.
+const (
+	// EventDropOldest discards the oldest queued sample to make room (default).
+	EventDropOldest = events.DropOldest
+	// EventBlock makes the driver wait until the subscriber has room again.
+	EventBlock = events.Block
+)
+
+// Subscribe returns a channel receiving every observed change of property, in
+// order. Up to EventBuffer changes are queued for a subscriber that falls behind;
+// beyond that EventOverflow decides whether the oldest are dropped or the driver
+// waits. The channel is closed by Unsubscribe and by StopDevice.
+func (c *CustomizedClient) Subscribe(property string) <-chan Sample {
+	return c.events.Subscribe(property)
+}
+
+// Unsubscribe stops the delivery to a channel returned by Subscribe and closes it.
+func (c *CustomizedClient) Unsubscribe(ch <-chan Sample) {
+	c.events.Unsubscribe(ch)
+}
+
+// publishEvent hands a changed value of prop to its subscribers. With EventBlock
+// it waits for subscribers that are behind, so it is called without deviceMutex.
+func (c *CustomizedClient) publishEvent(prop string, v interface{}) {
+	c.events.Publish(prop, v, c.ProtocolConfig.EventBuffer, c.ProtocolConfig.EventOverflow)
+}
+
+// recordHistory appends a changed value of prop when HistorySize is set.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) recordHistory(prop string, v interface{}) {
+	c.history.Record(c.ProtocolConfig.HistorySize, prop, v)
+}
+
+// GetHistory returns the last n changes of property, oldest first, or all recorded
+// ones when n <= 0. It returns nil when history is disabled (HistorySize 0).
+func (c *CustomizedClient) GetHistory(property string, n int) []Sample {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return c.history.Last(property, n)
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultFallbackAfter is the number of failed connection attempts on the
+// active address before switching to the other one.
+const defaultFallbackAfter = 3
+
+// parseFallback validates FallbackAddr and FallbackAfter. A URL fallback is
+// accepted for symmetry with Addr, but only its host and port are used.
+func (c *CustomizedClient) parseFallback() error {
+	c.activeAddr = c.ProtocolConfig.Addr
+	if c.ProtocolConfig.FallbackAddr == "" {
+		return nil
+	}
+	addr, _, err := parseAddr(c.ProtocolConfig.FallbackAddr)
+	if err != nil {
+		return fmt.Errorf("fallbackAddr: %w", err)
+	}
+	c.ProtocolConfig.FallbackAddr = addr
+	switch {
+	case c.ProtocolConfig.FallbackAfter == 0:
+		c.ProtocolConfig.FallbackAfter = defaultFallbackAfter
+	case c.ProtocolConfig.FallbackAfter < 0:
+		return fmt.Errorf("invalid fallbackAfter %d", c.ProtocolConfig.FallbackAfter)
+	}
+	return nil
+}
+
+// currentAddr returns the address the connection loop is using.
+func (c *CustomizedClient) currentAddr() string {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return c.activeAddr
+}
+
+// switchAddr makes addr the active address and logs the switch.
+func (c *CustomizedClient) switchAddr(addr, reason string) {
+	c.deviceMutex.Lock()
+	from := c.activeAddr
+	c.activeAddr = addr
+	c.deviceMutex.Unlock()
+	c.addrFailures = 0
+	c.V(LogConnection, 0).InfoS("CoAP switching address", "from", from, "to", addr, "reason", reason)
+}
+
+// connectFailed records a failed connection attempt on the active address and
+// switches to the other address once FallbackAfter attempts in a row failed.
+// Only called from runConnectionLoop.
+func (c *CustomizedClient) connectFailed() {
+	if c.ProtocolConfig.FallbackAddr == "" {
+		return
+	}
+	c.addrFailures++
+	if c.addrFailures < c.ProtocolConfig.FallbackAfter {
+		return
+	}
+	if c.currentAddr() == c.ProtocolConfig.Addr {
+		c.switchAddr(c.ProtocolConfig.FallbackAddr, fmt.Sprintf("%d failed attempts on primary", c.addrFailures))
+	} else {
+		c.switchAddr(c.ProtocolConfig.Addr, fmt.Sprintf("%d failed attempts on fallback", c.addrFailures))
+	}
+}
+
+// connectSucceeded resets the failure count after a healthy check.
+func (c *CustomizedClient) connectSucceeded() {
+	c.addrFailures = 0
+}
+
+// primaryRecovered reports whether the primary address answers a ping while
+// the loop runs on the fallback. The probe connection is always closed.
+func (c *CustomizedClient) primaryRecovered(ctx context.Context) bool {
+	if c.ProtocolConfig.FallbackAddr == "" || c.currentAddr() == c.ProtocolConfig.Addr {
+		return false
+	}
+	conn, err := c.dial(ctx, c.ProtocolConfig.Addr)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	pctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	if err := conn.Ping(pctx); err != nil {
+		c.V(LogConnection, 2).Infof("CoAP primary %s still unreachable: %v", c.ProtocolConfig.Addr, err)
+		return false
+	}
+	return true
+}
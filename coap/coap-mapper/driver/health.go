@@ -0,0 +1,101 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+)
+
+// Liveness checks for HealthMode.
+const (
+	// HealthModeGet GETs motionPath with healthQuery (default).
+	HealthModeGet = "get"
+	// HealthModePing sends an empty confirmable message (CoAP ping), which no
+	// resource handler sees.
+	HealthModePing = "ping"
+	// HealthModeWellKnown GETs /.well-known/core, the resource directory.
+	HealthModeWellKnown = "wellknown"
+)
+
+const wellKnownCore = "/.well-known/core"
+
+const (
+	// defaultHealthFailureThreshold is how many health checks in a row must
+	// fail before the connection is dropped: a single lost UDP datagram is normal.
+	defaultHealthFailureThreshold = 3
+	// healthRetryInterval is the delay of the next check after a failed one, so
+	// a real outage is still noticed quickly.
+	healthRetryInterval = 2 * time.Second
+)
+
+// parseHealthThreshold checks HealthFailureThreshold, defaulting it.
+func (c *CustomizedClient) parseHealthThreshold() error {
+	switch n := c.ProtocolConfig.HealthFailureThreshold; {
+	case n == 0:
+		c.healthThreshold = defaultHealthFailureThreshold
+	case n < 0:
+		return fmt.Errorf("invalid healthFailureThreshold %d", n)
+	default:
+		c.healthThreshold = n
+	}
+	return nil
+}
+
+// validateHealthMode checks HealthMode, defaulting it to HealthModeGet.
+func (c *CustomizedClient) validateHealthMode() error {
+	switch c.ProtocolConfig.HealthMode {
+	case "":
+		c.ProtocolConfig.HealthMode = HealthModeGet
+	case HealthModeGet, HealthModePing, HealthModeWellKnown:
+	default:
+		return fmt.Errorf("invalid healthMode %q", c.ProtocolConfig.HealthMode)
+	}
+	return nil
+}
+
+// healthCheck probes conn according to HealthMode. acceptableCodes["health"]
+// only applies to the resource GET.
+func (c *CustomizedClient) healthCheck(ctx context.Context, conn *udpClient.Conn) error {
+	hctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	c.deviceMutex.Lock()
+	mode, path := c.ProtocolConfig.HealthMode, c.ProtocolConfig.MotionPath
+	opts, healthOpts := c.requestOpts(""), c.requestOpts(healthQueryKey)
+	c.deviceMutex.Unlock()
+	switch mode {
+	case HealthModePing, HealthModeWellKnown:
+		release, err := c.acquireRequest(hctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if mode == HealthModePing {
+			// a ping is an empty confirmable message answered by a reset
+			c.tap(TapRequest, codes.Empty, "", 0)
+			if err := conn.Ping(hctx); err != nil {
+				return err
+			}
+			c.tap(TapResponse, codes.Empty, "", 0)
+			return nil
+		}
+		// resource discovery stays unprotected under OSCORE
+		c.tap(TapRequest, codes.GET, wellKnownCore, 0)
+		resp, err := conn.Get(hctx, wellKnownCore, opts...)
+		c.tapResponse(wellKnownCore, resp)
+		return err
+	}
+	resp, err := c.get(hctx, conn, path, healthOpts...)
+	if err != nil {
+		return err
+	}
+	c.deviceMutex.Lock()
+	unexpected := c.checkHealthCode && !c.acceptable(healthQueryKey, resp.Code())
+	c.deviceMutex.Unlock()
+	if unexpected {
+		return fmt.Errorf("unexpected response code %v", resp.Code())
+	}
+	return nil
+}
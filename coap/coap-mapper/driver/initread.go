@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultInitTimeout = 10 * time.Second
+	initPollInterval   = 200 * time.Millisecond
+)
+
+// initTimeout parses InitTimeout, falling back to the default.
+func initTimeout(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultInitTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid initTimeout %q", raw)
+	}
+	return d, nil
+}
+
+// waitForFirstRead blocks until every property has been read once, issuing a GET
+// for each property still missing as soon as the connection loop has dialed.
+// Observed properties are also polled so a server that only notifies on change
+// does not hold up startup.
+func (c *CustomizedClient) waitForFirstRead(ctx context.Context, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(initPollInterval)
+	defer ticker.Stop()
+
+	for {
+		missing := c.readMissing()
+		if len(missing) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("%w: no initial read within %v for properties %v", ErrTimeout, timeout, missing)
+		case <-ticker.C:
+		}
+	}
+}
+
+// readMissing polls the properties that have not been read yet and returns
+// those that are still missing afterwards.
+func (c *CustomizedClient) readMissing() []string {
+	c.deviceMutex.Lock()
+	var pending []resource
+	for _, r := range c.resources() {
+		if _, ok := c.lastRead[r.prop]; ok || !c.propertyEnabled(r.prop) {
+			continue
+		}
+		pending = append(pending, r)
+	}
+	c.deviceMutex.Unlock()
+
+	var missing []string
+	for _, r := range pending {
+		if err := c.poll(context.Background(), r.prop, r.path); err == nil {
+			klog.V(2).InfoS("CoAP initial read", "addr", c.ProtocolConfig.Addr, "property", r.prop)
+			continue
+		}
+		missing = append(missing, r.prop)
+	}
+	return missing
+}
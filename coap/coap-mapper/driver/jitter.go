@@ -0,0 +1,38 @@
+package driver
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// defaultJitterPercent spreads reconnects and health checks of a fleet by ±10%.
+const defaultJitterPercent = 10
+
+// parseJitter validates JitterPercent and seeds the client's RNG. A fixed JitterSeed
+// makes the sequence of delays reproducible; otherwise the current time is used.
+func (c *CustomizedClient) parseJitter() error {
+	c.jitterPercent = defaultJitterPercent
+	if p := c.ProtocolConfig.JitterPercent; p != nil {
+		if *p < 0 || *p > 100 {
+			return fmt.Errorf("invalid jitterPercent %v, must be between 0 and 100", *p)
+		}
+		c.jitterPercent = *p
+	}
+	seed := c.ProtocolConfig.JitterSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	c.rng = rand.New(rand.NewSource(seed))
+	return nil
+}
+
+// jitter returns d moved randomly by up to ±jitterPercent. Only the connection
+// loop calls it, so the RNG needs no locking.
+func (c *CustomizedClient) jitter(d time.Duration) time.Duration {
+	if c.rng == nil || c.jitterPercent == 0 {
+		return d
+	}
+	f := 1 + (c.rng.Float64()*2-1)*c.jitterPercent/100
+	return time.Duration(float64(d) * f)
+}
@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// extractJSONPath decodes payload as JSON and returns the value selected by path.
+//
+// Only a minimal subset of JSONPath is supported:
+//   - segments are separated by dots, e.g. "sensors.0.motion"
+//   - a segment is either an object key or a zero-based array index
+//   - bracket indexes are accepted as an alias, e.g. "sensors[0].motion"
+//   - an optional leading "$" or "$." is ignored
+//
+// Wildcards, filters, slices and recursive descent are not supported.
+// An error is returned if the payload is not JSON or the path does not resolve.
+func extractJSONPath(payload []byte, path string) (interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, fmt.Errorf("payload is not valid JSON: %w", err)
+	}
+
+	cur := doc
+	for _, seg := range splitJSONPath(path) {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			v, ok := node[seg]
+			if !ok {
+				return nil, fmt.Errorf("json path %q: key %q not found", path, seg)
+			}
+			cur = v
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil {
+				return nil, fmt.Errorf("json path %q: %q is not an array index", path, seg)
+			}
+			if idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("json path %q: index %d out of range (len %d)", path, idx, len(node))
+			}
+			cur = node[idx]
+		default:
+			return nil, fmt.Errorf("json path %q: cannot descend into %q, value is not an object or array", path, seg)
+		}
+	}
+	return cur, nil
+}
+
+// splitJSONPath turns "$.sensors[0].motion" into ["sensors", "0", "motion"].
+func splitJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	var segs []string
+	for _, s := range strings.Split(path, ".") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// jsonValueToBool converts an extracted JSON value to a motion flag.
+func (c *CustomizedClient) jsonValueToBool(v interface{}) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		return c.boolTokens.IsTrue(t)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,48 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// TestJSONPathKeepsPayload checks that a read with a JSON path leaves the
+// notified payload cached, so visitors with other paths and a read without one
+// still see all of it.
+func TestJSONPathKeepsPayload(t *testing.T) {
+	const body = `{"label":"person","score":0.9}`
+	s := newTestServer(t)
+	s.handle("/class", func() string { return "none" })
+	c := startClient(t, ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, ObserveClass: true}})
+	waitFor(t, "observe /class", func() bool {
+		_, ok := s.observer("/class")
+		return ok
+	})
+	s.notify(t, "/class", 2, codes.Content, body)
+	waitFor(t, "class notified", func() bool {
+		v, err := c.GetProperty("class")
+		return err == nil && v == body
+	})
+
+	read := func(path string) interface{} {
+		t.Helper()
+		visitor := c.visitorFor("class")
+		visitor.VisitorConfigData.JSONPath = path
+		v, err := c.GetDeviceData(visitor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for i := 0; i < 2; i++ {
+		if v := read("$.label"); v != "person" {
+			t.Fatalf("class $.label read %d: %v, want person", i, v)
+		}
+		if v := read("$.score"); v != "0.9" {
+			t.Fatalf("class $.score read %d: %v, want 0.9", i, v)
+		}
+	}
+	if v := read(""); v != body {
+		t.Fatalf("class %v, want %s", v, body)
+	}
+}
@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/plgd-dev/go-coap/v3/options"
+)
+
+// defaultMaxConcurrentRequests sends one request to the device at a time.
+const defaultMaxConcurrentRequests = 1
+
+// parseRequestLimit validates MaxConcurrentRequests and creates the request slots.
+// go-coap sends one request of a client at a time by default; above that the
+// limit is raised to MaxConcurrentRequests, as is NSTART, see parseTransmission,
+// so that the slots are what limits.
+func (c *CustomizedClient) parseRequestLimit() error {
+	n := c.ProtocolConfig.MaxConcurrentRequests
+	if n < 0 {
+		return fmt.Errorf("invalid maxConcurrentRequests %d", n)
+	}
+	if n == 0 {
+		n = defaultMaxConcurrentRequests
+	}
+	c.requestSlots = make(chan struct{}, n)
+	if n > defaultMaxConcurrentRequests {
+		c.dialOpts = append(c.dialOpts, options.WithLimitClientParallelRequest(int64(n)))
+	}
+	return nil
+}
+
+// acquireRequest takes one of the MaxConcurrentRequests slots for a request to
+// the device, waiting until one is free, ctx is done or the device is stopped.
+// The returned func gives the slot back.
+func (c *CustomizedClient) acquireRequest(ctx context.Context) (func(), error) {
+	if c.requestSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.requestSlots <- struct{}{}:
+		return func() { <-c.requestSlots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a free request slot: %w", ctx.Err())
+	case <-c.stopped:
+		return nil, fmt.Errorf("device stopped")
+	}
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMaxConcurrentRequests checks that polls of different properties overlap
+// up to MaxConcurrentRequests, from concurrent GetDeviceData calls and ReadAll.
+func TestMaxConcurrentRequests(t *testing.T) {
+	props := []string{"motion", "last_detection", "class"}
+	for _, limit := range []int{1, 3} {
+		s := newTestServer(t)
+		var inFlight, peak atomic.Int32
+		slow := func() string {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(200 * time.Millisecond)
+			return "1"
+		}
+		for _, prop := range props {
+			s.handle("/"+prop, slow)
+		}
+		c := startClient(t, ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, MaxConcurrentRequests: limit}})
+
+		var wg sync.WaitGroup
+		for _, prop := range props {
+			wg.Add(1)
+			go func(prop string) {
+				defer wg.Done()
+				if _, err := c.GetProperty(prop); err != nil {
+					t.Error(err)
+				}
+			}(prop)
+		}
+		wg.Wait()
+		if got := peak.Load(); got != int32(limit) {
+			t.Errorf("limit %d: concurrent GetDeviceData had %d requests in flight", limit, got)
+		}
+
+		peak.Store(0)
+		if _, err := c.ReadAll(); err != nil {
+			t.Fatal(err)
+		}
+		if got := peak.Load(); got != int32(limit) {
+			t.Errorf("limit %d: ReadAll had %d requests in flight", limit, got)
+		}
+	}
+}
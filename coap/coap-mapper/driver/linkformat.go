@@ -0,0 +1,217 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"k8s.io/klog/v2"
+)
+
+// ResourceInfo holds the CoRE Link Format attributes (RFC 6690) a device
+// advertises for one of its resources in /.well-known/core.
+type ResourceInfo struct {
+	Path           string
+	ResourceTypes  []string            // rt
+	Interfaces     []string            // if, e.g. core.s (sensor) or core.a (actuator)
+	ContentFormats []message.MediaType // ct
+	Observable     bool                // obs
+}
+
+// readOnlyInterfaces are the CoRE interfaces of resources that only serve
+// reads: sensors and read-only parameters.
+var readOnlyInterfaces = map[string]bool{
+	"core.s":  true,
+	"core.rp": true,
+}
+
+// Writable reports whether the resource may accept writes: false only when it
+// advertises interfaces and all of them are read-only.
+func (r ResourceInfo) Writable() bool {
+	if len(r.Interfaces) == 0 {
+		return true
+	}
+	for _, i := range r.Interfaces {
+		if !readOnlyInterfaces[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceInfo returns the attributes the device advertised for path at the
+// last discovery, see ResourceDiscovery. ok is false when discovery is off, has
+// not succeeded yet or did not list path.
+func (c *CustomizedClient) ResourceInfo(path string) (info ResourceInfo, ok bool) {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	info, ok = c.links[linkPath(path)]
+	return info, ok
+}
+
+// discoverResources GETs /.well-known/core on conn and replaces the cached link
+// attributes with its listing. A failure keeps those of the last discovery.
+func (c *CustomizedClient) discoverResources(ctx context.Context, conn *udpClient.Conn) {
+	dctx, cancel := context.WithTimeout(ctx, getTimeout)
+	defer cancel()
+	release, err := c.acquireRequest(dctx)
+	if err != nil {
+		klog.Warningf("CoAP resource discovery on %s: %v", c.ProtocolConfig.Addr, err)
+		return
+	}
+	defer release()
+	c.deviceMutex.Lock()
+	opts := c.requestOpts("")
+	c.deviceMutex.Unlock()
+	// resource discovery stays unprotected under OSCORE
+	c.tap(TapRequest, codes.GET, wellKnownCore, 0)
+	resp, err := conn.Get(dctx, wellKnownCore, opts...)
+	c.tapResponse(wellKnownCore, resp)
+	if err == nil && resp.Code() != codes.Content {
+		err = fmt.Errorf("answered %v", resp.Code())
+	}
+	var links map[string]ResourceInfo
+	if err == nil {
+		var body []byte
+		if body, err = resp.ReadBody(); err == nil {
+			links, err = parseLinkFormat(string(body))
+		}
+	}
+	if err != nil {
+		klog.Warningf("CoAP resource discovery on %s: GET %s: %v", c.ProtocolConfig.Addr, wellKnownCore, err)
+		return
+	}
+	c.deviceMutex.Lock()
+	c.links = links
+	c.deviceMutex.Unlock()
+	c.V(LogConnection, 0).InfoS("CoAP resources discovered", "addr", c.ProtocolConfig.Addr, "resources", len(links))
+}
+
+// advertisedFormat returns the codec for the first Content-Format the device
+// advertises for prop's resource that the driver decodes: json or cbor, or ""
+// for text/plain, which keeps the default text handling, and when nothing usable
+// is advertised. Caller must hold deviceMutex.
+func (c *CustomizedClient) advertisedFormat(prop string) string {
+	r, ok := c.resourceFor(prop)
+	if !ok {
+		return ""
+	}
+	for _, cf := range c.links[linkPath(r.path)].ContentFormats {
+		switch cf {
+		case message.TextPlain:
+			return ""
+		case message.AppJSON:
+			return "json"
+		case message.AppCBOR:
+			return "cbor"
+		}
+	}
+	return ""
+}
+
+// linkPath is the key of a resource in the link cache: its path with a leading
+// slash, also for a link target given as an absolute URI.
+func linkPath(target string) string {
+	if strings.Contains(target, "://") {
+		if u, err := url.Parse(target); err == nil {
+			target = u.Path
+		}
+	}
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + target
+	}
+	return target
+}
+
+// parseLinkFormat parses a CoRE Link Format document, e.g.
+// </motion>;rt="sensor.motion";if="core.s";ct="0 50";obs,</led>;if=core.a
+// into the attributes per resource path. Attributes other than rt, if, ct and
+// obs are ignored.
+func parseLinkFormat(doc string) (map[string]ResourceInfo, error) {
+	links := make(map[string]ResourceInfo)
+	s := strings.TrimSpace(doc)
+	for s != "" {
+		if s[0] != '<' {
+			return nil, fmt.Errorf("link format: expected '<' at %q", s)
+		}
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			return nil, fmt.Errorf("link format: unterminated link target %q", s)
+		}
+		info := ResourceInfo{Path: linkPath(s[1:end])}
+		s = strings.TrimLeft(s[end+1:], " \t\r\n")
+		for len(s) > 0 && s[0] == ';' {
+			var name, val string
+			var err error
+			if name, val, s, err = nextLinkParam(s[1:]); err != nil {
+				return nil, err
+			}
+			switch name {
+			case "rt":
+				info.ResourceTypes = append(info.ResourceTypes, strings.Fields(val)...)
+			case "if":
+				info.Interfaces = append(info.Interfaces, strings.Fields(val)...)
+			case "ct":
+				for _, f := range strings.Fields(val) {
+					cf, err := strconv.ParseUint(f, 10, 16)
+					if err != nil {
+						return nil, fmt.Errorf("link format: %s: invalid ct %q", info.Path, f)
+					}
+					info.ContentFormats = append(info.ContentFormats, message.MediaType(cf))
+				}
+			case "obs":
+				info.Observable = true
+			}
+		}
+		links[info.Path] = info
+		switch {
+		case s == "":
+		case s[0] == ',':
+			s = strings.TrimLeft(s[1:], " \t\r\n")
+		default:
+			return nil, fmt.Errorf("link format: expected ',' at %q", s)
+		}
+	}
+	return links, nil
+}
+
+// nextLinkParam splits the link parameter at the start of s, after its ';',
+// into name and value (quotes removed) and returns the remainder from the next
+// ';' or ','.
+func nextLinkParam(s string) (name, val, rest string, err error) {
+	i := strings.IndexAny(s, "=;,")
+	if i < 0 {
+		return strings.TrimSpace(s), "", "", nil
+	}
+	name = strings.TrimSpace(s[:i])
+	if s[i] != '=' {
+		return name, "", s[i:], nil
+	}
+	s = strings.TrimLeft(s[i+1:], " \t")
+	if strings.HasPrefix(s, `"`) {
+		var b strings.Builder
+		for j := 1; j < len(s); j++ {
+			switch s[j] {
+			case '\\':
+				if j+1 < len(s) {
+					j++
+					b.WriteByte(s[j])
+				}
+			case '"':
+				return name, b.String(), strings.TrimLeft(s[j+1:], " \t\r\n"), nil
+			default:
+				b.WriteByte(s[j])
+			}
+		}
+		return "", "", "", fmt.Errorf("link format: unterminated value of %s", name)
+	}
+	if i = strings.IndexAny(s, ";,"); i < 0 {
+		return name, strings.TrimSpace(s), "", nil
+	}
+	return name, strings.TrimSpace(s[:i]), s[i:], nil
+}
@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Manager shares the scheduling of many clients: their health checks run off a
+// single timer and at most maxDials dials (including fallback probes) are in
+// flight across them, so a mapper with dozens of devices does not keep a timer
+// per device or flood the network when they all reconnect at once. Only the
+// timers and dials are shared: each client still runs its own connection loop,
+// backoff and observe handlers, so the goroutines remain per device. Set a
+// client's Manager before InitDevice, or start a group of clients with
+// InitDevices.
+type Manager struct {
+	dialSlots chan struct{}
+	mu        sync.Mutex
+	// pending health checks: the channel a connection loop waits on and when to fire it
+	due  map[chan time.Time]time.Time
+	wake chan struct{}
+}
+
+// NewManager returns a Manager whose timer runs until ctx is done. maxDials
+// bounds the concurrent dials, 0 means unbounded.
+func NewManager(ctx context.Context, maxDials int) (*Manager, error) {
+	if maxDials < 0 {
+		return nil, fmt.Errorf("invalid maxDials %d", maxDials)
+	}
+	m := &Manager{
+		due:  make(map[chan time.Time]time.Time),
+		wake: make(chan struct{}, 1),
+	}
+	if maxDials > 0 {
+		m.dialSlots = make(chan struct{}, maxDials)
+	}
+	go m.run(ctx)
+	return m, nil
+}
+
+// InitDevices registers the clients with m and initializes them in parallel,
+// so clients waiting for their first read do not hold up the others. It returns
+// the errors of the clients that failed, the rest are running.
+func (m *Manager) InitDevices(clients ...*CustomizedClient) error {
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		c.Manager = m
+		wg.Add(1)
+		go func(i int, c *CustomizedClient) {
+			defer wg.Done()
+			if err := c.InitDevice(); err != nil {
+				errs[i] = fmt.Errorf("device %s: %w", c.ProtocolConfig.Addr, err)
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// run fires the due health checks and sleeps until the next one.
+func (m *Manager) run(ctx context.Context) {
+	for {
+		m.mu.Lock()
+		now := time.Now()
+		next := time.Duration(-1)
+		for ch, at := range m.due {
+			if wait := at.Sub(now); wait > 0 {
+				if next < 0 || wait < next {
+					next = wait
+				}
+				continue
+			}
+			delete(m.due, ch)
+			select {
+			case ch <- now:
+			default:
+			}
+		}
+		m.mu.Unlock()
+
+		var t *time.Timer
+		var timerC <-chan time.Time
+		if next >= 0 {
+			t = time.NewTimer(next)
+			timerC = t.C
+		}
+		select {
+		case <-ctx.Done():
+			if t != nil {
+				t.Stop()
+			}
+			return
+		case <-timerC:
+		case <-m.wake:
+		}
+		if t != nil {
+			t.Stop()
+		}
+	}
+}
+
+// schedule fires ch after d, replacing a pending schedule of ch.
+func (m *Manager) schedule(ch chan time.Time, d time.Duration) {
+	m.mu.Lock()
+	m.due[ch] = time.Now().Add(d)
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// cancel drops a pending schedule of ch.
+func (m *Manager) cancel(ch chan time.Time) {
+	m.mu.Lock()
+	delete(m.due, ch)
+	m.mu.Unlock()
+}
+
+// acquireDial waits for a dial slot; release gives it back.
+func (m *Manager) acquireDial(ctx context.Context) (release func(), err error) {
+	if m.dialSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case m.dialSlots <- struct{}{}:
+		return func() { <-m.dialSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// healthTimer drives the health checks of one connection, from its own
+// time.Timer or, under a Manager, from the shared one.
+type healthTimer struct {
+	C     <-chan time.Time
+	Reset func(d time.Duration)
+	Stop  func()
+}
+
+// newHealthTimer returns a healthTimer firing after d.
+func (c *CustomizedClient) newHealthTimer(d time.Duration) *healthTimer {
+	if c.Manager == nil {
+		t := time.NewTimer(d)
+		return &healthTimer{
+			C:     t.C,
+			Reset: func(d time.Duration) { t.Reset(d) },
+			Stop:  func() { t.Stop() },
+		}
+	}
+	m := c.Manager
+	ch := make(chan time.Time, 1)
+	m.schedule(ch, d)
+	return &healthTimer{
+		C:     ch,
+		Reset: func(d time.Duration) { m.schedule(ch, d) },
+		Stop:  func() { m.cancel(ch) },
+	}
+}
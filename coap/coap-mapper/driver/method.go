@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// Request codes of RFC 8132, which go-coap does not define.
+const (
+	codePATCH  codes.Code = 6
+	codeIPATCH codes.Code = 7
+)
+
+// writeMethods maps the WriteMethod names, upper-cased, to their request codes.
+var writeMethods = map[string]codes.Code{
+	"":       codes.PUT,
+	"PUT":    codes.PUT,
+	"POST":   codes.POST,
+	"PATCH":  codePATCH,
+	"IPATCH": codeIPATCH,
+}
+
+// writeMethod returns the request code of a WriteMethod, matched case-insensitively.
+func writeMethod(name string) (codes.Code, error) {
+	code, ok := writeMethods[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid writeMethod %q, must be PUT, POST, PATCH or iPATCH", name)
+	}
+	return code, nil
+}
+
+// methodName prints a request code, including the RFC 8132 ones go-coap prints as numbers.
+func methodName(code codes.Code) string {
+	switch code {
+	case codePATCH:
+		return "PATCH"
+	case codeIPATCH:
+		return "iPATCH"
+	}
+	return code.String()
+}
+
+// patchFormat returns the content-format of a partial update: a JSON body is a
+// JSON merge patch (RFC 7396) under PATCH and iPATCH, anything else keeps cf.
+func patchFormat(method codes.Code, cf message.MediaType) message.MediaType {
+	if (method == codePATCH || method == codeIPATCH) && cf == message.AppJSON {
+		return message.AppJSONMergePatch
+	}
+	return cf
+}
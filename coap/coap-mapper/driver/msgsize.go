@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"k8s.io/klog/v2"
+)
+
+// blockHeadroom is reserved in MaxMessageSize for the CoAP header, token and
+// options that accompany each block.
+const blockHeadroom = 64
+
+// blockwiseTransferTimeout matches the go-coap default.
+const blockwiseTransferTimeout = 3 * time.Second
+
+// parseMessageSize validates MaxMessageSize and the socket buffer sizes and
+// returns the matching dial options.
+//
+// Blockwise transfer is always on in go-coap with 1024-byte blocks. MaxMessageSize
+// caps the datagrams the client accepts and lowers the block size to the largest
+// one that still fits, so a large resource arrives in several small responses
+// instead of one datagram the IP layer has to fragment. A block size configured
+// in the future must not exceed this limit.
+func (c *CustomizedClient) parseMessageSize() ([]udp.Option, error) {
+	if c.ProtocolConfig.ReadBufferSize < 0 || c.ProtocolConfig.WriteBufferSize < 0 {
+		return nil, fmt.Errorf("invalid socket buffer size, must not be negative")
+	}
+	limit := c.ProtocolConfig.MaxMessageSize
+	if limit == 0 {
+		return nil, nil
+	}
+	if limit < 16+blockHeadroom {
+		return nil, fmt.Errorf("invalid maxMessageSize %d, must be at least %d", limit, 16+blockHeadroom)
+	}
+	szx := blockwise.SZX16
+	for s := blockwise.SZX16; s <= blockwise.SZX1024; s++ {
+		if s.Size() <= int64(limit)-blockHeadroom {
+			szx = s
+		}
+	}
+	klog.V(2).Infof("CoAP maxMessageSize=%d, blockwise block size %d", limit, szx.Size())
+	return []udp.Option{
+		options.WithMaxMessageSize(limit),
+		options.WithBlockwise(true, szx, blockwiseTransferTimeout),
+	}, nil
+}
+
+// udpDial dials target with the configured options and applies the socket
+// buffer sizes, which go-coap has no option for.
+func (c *CustomizedClient) udpDial(target string) (*udpClient.Conn, error) {
+	conn, err := udp.Dial(target, c.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	uc, ok := conn.NetConn().(*net.UDPConn)
+	if !ok {
+		return conn, nil
+	}
+	if n := c.ProtocolConfig.ReadBufferSize; n > 0 {
+		if err := uc.SetReadBuffer(n); err != nil {
+			klog.Warningf("CoAP %s: set read buffer to %d: %v", target, n, err)
+		}
+	}
+	if n := c.ProtocolConfig.WriteBufferSize; n > 0 {
+		if err := uc.SetWriteBuffer(n); err != nil {
+			klog.Warningf("CoAP %s: set write buffer to %d: %v", target, n, err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,185 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	coapClient "github.com/plgd-dev/go-coap/v3/net/client"
+	"k8s.io/klog/v2"
+)
+
+// observeTarget is a resource or composite part the connection loop observes,
+// taken from the config under deviceMutex.
+type observeTarget struct {
+	// prop is the property, or the compositeKey of a composite part
+	prop, path string
+	opts       []message.Option
+	handler    func(*pool.Message)
+	// composite names the composite of a part, "" for a resource
+	composite string
+	// res is the resource, and seed whether to GET it after registering
+	res  resource
+	seed bool
+}
+
+// same reports whether t and o register the same observation.
+func (t observeTarget) same(o observeTarget) bool {
+	return t.path == o.path && fmt.Sprint(t.opts) == fmt.Sprint(o.opts)
+}
+
+// observeTargets returns the observed resources and then the parts of the
+// composites, leaving out disabled properties.
+func (c *CustomizedClient) observeTargets() []observeTarget {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	seed := c.ProtocolConfig.SeedObserveWithGet == nil || *c.ProtocolConfig.SeedObserveWithGet
+	var targets []observeTarget
+	for _, r := range c.resources() {
+		if r.observe && c.propertyEnabled(r.prop) {
+			targets = append(targets, observeTarget{prop: r.prop, path: r.path, opts: c.requestOpts(r.prop), handler: c.observeHandler(r.prop), res: r, seed: seed})
+		}
+	}
+	for name, parts := range c.ProtocolConfig.Composites {
+		if !c.propertyEnabled(name) {
+			continue
+		}
+		for field, path := range parts {
+			key := compositeKey(name, field)
+			targets = append(targets, observeTarget{prop: key, path: path, opts: c.requestOpts(key), handler: c.compositeHandler(name, field), composite: name})
+		}
+	}
+	return targets
+}
+
+// resetNotified forgets that prop was notified, used when the observation is
+// registered again.
+func (c *CustomizedClient) resetNotified(prop string) {
+	c.deviceMutex.Lock()
+	delete(c.notified, prop)
+	c.deviceMutex.Unlock()
+}
+
+// trackHandler wraps an observe handler so StopDevice can wait for invocations in
+// flight. Notifications arriving once the client is stopping are ignored.
+func (c *CustomizedClient) trackHandler(handler func(*pool.Message)) func(*pool.Message) {
+	return func(m *pool.Message) {
+		c.deviceMutex.Lock()
+		if c.stopping {
+			c.deviceMutex.Unlock()
+			return
+		}
+		c.handlers.Add(1)
+		c.deviceMutex.Unlock()
+		defer c.handlers.Done()
+		handler(m)
+	}
+}
+
+// drainHandlers blocks new observe handler invocations and waits up to timeout
+// for those in flight to return. It reports whether all of them finished.
+func (c *CustomizedClient) drainHandlers(timeout time.Duration) bool {
+	c.deviceMutex.Lock()
+	c.stopping = true
+	c.deviceMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// watchObserveEnd wraps an observe handler to notice the server ending the
+// observation: an error response, or a response without Observe option, which
+// removes the client from the observer list (RFC 7641 §3.2, §4.2). The property
+// is marked unobserved, so reads fall back to GET instead of returning the value
+// the ended observation left behind, and its key is sent on ended for the
+// connection loop to re-register. Error bodies are not applied as values.
+func (c *CustomizedClient) watchObserveEnd(ctx context.Context, prop string, ended chan<- string, handler func(*pool.Message)) func(*pool.Message) {
+	return func(m *pool.Message) {
+		_, err := m.Observe()
+		success := m.Code()>>5 == 2
+		c.deviceMutex.Lock()
+		if err == nil {
+			c.notified[prop] = true
+		}
+		if success && err == nil {
+			delete(c.unobserved, prop)
+		} else {
+			c.unobserved[prop] = true
+		}
+		c.deviceMutex.Unlock()
+		if success {
+			handler(m)
+		}
+		if success && err == nil {
+			return
+		}
+		klog.Warningf("CoAP observe %s ended by the server with %v", prop, m.Code())
+		// not from the handler itself: the loop may be waiting on this connection
+		go func() {
+			select {
+			case ended <- prop:
+			case <-ctx.Done():
+			}
+		}()
+	}
+}
+
+// observationEnded handles an observation of prop the server ended. It is
+// re-registered if it delivered at least one notification; one that ended
+// without any, typically a server refusing to observe the resource, is not
+// retried on this connection and the property is polled instead. Composite parts
+// have no polling path and keep the value received last.
+func (c *CustomizedClient) observationEnded(ctx context.Context, prop string, observations map[string]coapClient.Observation, reregister func() error) {
+	obs, active := observations[prop]
+	if !active {
+		return
+	}
+	delete(observations, prop)
+	cctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	_ = obs.Cancel(cctx)
+	cancel()
+
+	c.deviceMutex.Lock()
+	notified := c.notified[prop]
+	_, polled := c.resourceFor(prop)
+	c.deviceMutex.Unlock()
+	if notified {
+		err := reregister()
+		if err == nil {
+			c.V(LogObserve, 0).InfoS("CoAP observe re-registered", "addr", c.currentAddr(), "property", prop)
+			return
+		}
+		klog.Warningf("CoAP observe %s: re-register failed: %v", prop, err)
+	}
+	if polled {
+		c.V(LogObserve, 0).InfoS("CoAP observe not available, polling instead", "addr", c.currentAddr(), "property", prop)
+	} else {
+		c.V(LogObserve, 0).InfoS("CoAP observe not available, keeping the last value", "addr", c.currentAddr(), "part", prop)
+	}
+}
+
+// noteMaxAge records whether the notification m for prop allows caching its
+// value. Max-Age 0 says it is stale at once (RFC 7252 §5.10.5), so reads of prop
+// issue a GET instead of returning the cached value until a notification with a
+// positive or no Max-Age (default 60s) arrives. Caller must hold deviceMutex.
+func (c *CustomizedClient) noteMaxAge(prop string, m *pool.Message) {
+	if age, err := m.GetOptionUint32(message.MaxAge); err == nil && age == 0 {
+		if !c.uncacheable[prop] {
+			c.V(LogObserve, 2).InfoS("CoAP notification with Max-Age 0, reading with GET", "addr", c.ProtocolConfig.Addr, "property", prop)
+		}
+		c.uncacheable[prop] = true
+		return
+	}
+	delete(c.uncacheable, prop)
+}
@@ -0,0 +1,136 @@
+package driver
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// TestStaleNotificationsDropped checks that a notification with an older
+// Observe number than the last one received is not applied (RFC 7641 §3.4).
+func TestStaleNotificationsDropped(t *testing.T) {
+	s := newTestServer(t)
+	s.handle("/class", func() string { return "none" })
+	c := startClient(t, ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, ObserveClass: true, HistorySize: 10}})
+	waitFor(t, "observe /class", func() bool {
+		_, ok := s.observer("/class")
+		return ok
+	})
+
+	s.notify(t, "/class", 5, codes.Content, "person")
+	s.notify(t, "/class", 3, codes.Content, "car")
+	s.notify(t, "/class", 6, codes.Content, "dog")
+	waitFor(t, "class dog", func() bool {
+		v, err := c.GetProperty("class")
+		return err == nil && v == "dog"
+	})
+	var got []interface{}
+	for _, sample := range c.GetHistory("class", 0) {
+		got = append(got, sample.Value)
+	}
+	if len(got) < 2 || got[len(got)-2] != "person" || got[len(got)-1] != "dog" {
+		t.Fatalf("class history %v, want it to end with person, dog", got)
+	}
+}
+
+// TestObservationEndedByServer checks that an observation the server ends with
+// an error response is registered again when it had delivered notifications,
+// and that a resource the server does not observe is polled instead.
+func TestObservationEndedByServer(t *testing.T) {
+	t.Run("reregister", func(t *testing.T) {
+		s := newTestServer(t)
+		s.handle("/class", func() string { return "none" })
+		c := startClient(t, ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, ObserveClass: true}})
+		waitFor(t, "observe /class", func() bool {
+			_, ok := s.observer("/class")
+			return ok
+		})
+		s.notify(t, "/class", 2, codes.Content, "person")
+		waitFor(t, "class person", func() bool {
+			v, err := c.GetProperty("class")
+			return err == nil && v == "person"
+		})
+
+		o, _ := s.observer("/class")
+		s.notify(t, "/class", 0, codes.NotFound, "")
+		waitFor(t, "observe /class again", func() bool {
+			again, _ := s.observer("/class")
+			return string(again.token) != string(o.token)
+		})
+		s.notify(t, "/class", 2, codes.Content, "car")
+		waitFor(t, "class car", func() bool {
+			v, err := c.GetProperty("class")
+			return err == nil && v == "car"
+		})
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		s := newTestServer(t)
+		class := "none"
+		var mu sync.Mutex
+		s.handleUnobservable("/class", func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			return class
+		})
+		c := startClient(t, ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, ObserveClass: true}})
+		waitFor(t, "class polled", func() bool {
+			before := s.getCount("/class")
+			_, err := c.GetProperty("class")
+			return err == nil && s.getCount("/class") > before
+		})
+
+		mu.Lock()
+		class = "dog"
+		mu.Unlock()
+		before := s.getCount("/class")
+		if v, err := c.GetProperty("class"); err != nil || v != "dog" {
+			t.Fatalf("class %v, %v; want dog", v, err)
+		}
+		if got := s.getCount("/class"); got != before+1 {
+			t.Fatalf("%d GETs of /class, want %d", got, before+1)
+		}
+	})
+}
+
+// TestMaxAgeZero checks that after a notification with Max-Age 0 a read issues
+// a GET instead of returning the notified value, until a cacheable
+// notification arrives.
+func TestMaxAgeZero(t *testing.T) {
+	s := newTestServer(t)
+	s.handle("/class", func() string { return "none" })
+	c := startClient(t, ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, ObserveClass: true}})
+	waitFor(t, "observe /class", func() bool {
+		_, ok := s.observer("/class")
+		return ok
+	})
+	s.notify(t, "/class", 2, codes.Content, "person")
+	waitFor(t, "class person", func() bool {
+		v, err := c.GetProperty("class")
+		return err == nil && v == "person"
+	})
+	if got := s.getCount("/class"); got != 0 {
+		t.Fatalf("%d GETs of observed /class, want 0", got)
+	}
+
+	s.notify(t, "/class", 3, codes.Content, "car", message.Option{ID: message.MaxAge, Value: []byte{}})
+	waitFor(t, "GET after Max-Age 0", func() bool {
+		v, err := c.GetDeviceData(c.visitorFor("class"))
+		return err == nil && v == "none" && s.getCount("/class") > 0
+	})
+
+	s.notify(t, "/class", 4, codes.Content, "dog")
+	waitFor(t, "class dog", func() bool {
+		v, err := c.GetProperty("class")
+		return err == nil && v == "dog"
+	})
+	before := s.getCount("/class")
+	if _, err := c.GetProperty("class"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.getCount("/class"); got != before {
+		t.Fatalf("GET of /class after a cacheable notification")
+	}
+}
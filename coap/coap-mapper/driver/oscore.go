@@ -0,0 +1,505 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pion/dtls/v3/pkg/crypto/ccm"
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	coapClient "github.com/plgd-dev/go-coap/v3/net/client"
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"golang.org/x/crypto/hkdf"
+)
+
+// OSCOREConfig configures OSCORE (RFC 8613) object security. The peers share the
+// master secret and salt; SenderID is the mapper's ID and RecipientID the
+// device's. Binary values are hex encoded and IDs may be empty.
+type OSCOREConfig struct {
+	MasterSecret string `json:"masterSecret"`
+	MasterSalt   string `json:"masterSalt"`
+	SenderID     string `json:"senderID"`
+	RecipientID  string `json:"recipientID"`
+	// SequenceFile persists the sender sequence number. A nonce must never be
+	// reused with the same key, so the number has to survive restarts.
+	SequenceFile string `json:"sequenceFile"`
+}
+
+// The AEAD algorithm is AES-CCM-16-64-128, the mandatory one.
+const (
+	oscoreAlg      = 10 // COSE algorithm identifier
+	oscoreKeyLen   = 16
+	oscoreNonceLen = 13
+	oscoreTagLen   = 8
+	// IDs are padded into the nonce, leaving room for the size byte and the partial IV
+	oscoreMaxIDLen = oscoreNonceLen - 6
+	// sequence numbers are 40 bit
+	oscoreMaxSeq = 1<<40 - 1
+	// sequence numbers reserved in SequenceFile at a time
+	oscoreSeqReserve = 100
+)
+
+// oscoreOption is the OSCORE option number, not known to go-coap.
+const oscoreOption message.OptionID = 9
+
+// codeFETCH is the FETCH method (RFC 8132) carrying protected observe requests.
+const codeFETCH codes.Code = 5
+
+// outerOptions are the Class U options, left readable for proxies.
+var outerOptions = map[message.OptionID]bool{
+	message.URIHost:     true,
+	message.URIPort:     true,
+	message.ProxyURI:    true,
+	message.ProxyScheme: true,
+}
+
+// oscoreContext is the security context derived from an OSCOREConfig.
+type oscoreContext struct {
+	senderID    []byte
+	recipientID []byte
+	sender      cipher.AEAD
+	recipient   cipher.AEAD
+	commonIV    []byte
+
+	mu       sync.Mutex
+	seq      uint64
+	reserved uint64 // sequence numbers below it are recorded as used in seqFile
+	seqFile  string
+}
+
+// oscoreRequest keeps what a protected request contributes to its responses.
+type oscoreRequest struct {
+	piv   []byte
+	nonce []byte
+	aad   []byte
+}
+
+// parseOSCORE validates ProtocolConfig.OSCORE and derives the security context.
+func (c *CustomizedClient) parseOSCORE() error {
+	cfg := c.ProtocolConfig.OSCORE
+	if cfg == nil {
+		return nil
+	}
+	ctx, err := newOSCOREContext(cfg)
+	if err != nil {
+		return fmt.Errorf("oscore: %v", err)
+	}
+	c.oscore = ctx
+	return nil
+}
+
+func newOSCOREContext(cfg *OSCOREConfig) (*oscoreContext, error) {
+	secret, err := hex.DecodeString(cfg.MasterSecret)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("masterSecret must be a non-empty hex string")
+	}
+	salt, err := hex.DecodeString(cfg.MasterSalt)
+	if err != nil {
+		return nil, fmt.Errorf("masterSalt must be a hex string")
+	}
+	senderID, err := hex.DecodeString(cfg.SenderID)
+	if err != nil || len(senderID) > oscoreMaxIDLen {
+		return nil, fmt.Errorf("senderID must be a hex string of at most %d bytes", oscoreMaxIDLen)
+	}
+	recipientID, err := hex.DecodeString(cfg.RecipientID)
+	if err != nil || len(recipientID) > oscoreMaxIDLen {
+		return nil, fmt.Errorf("recipientID must be a hex string of at most %d bytes", oscoreMaxIDLen)
+	}
+	if bytes.Equal(senderID, recipientID) {
+		return nil, fmt.Errorf("senderID and recipientID must differ")
+	}
+	if cfg.SequenceFile == "" {
+		return nil, fmt.Errorf("sequenceFile is required")
+	}
+
+	o := &oscoreContext{senderID: senderID, recipientID: recipientID, seqFile: cfg.SequenceFile}
+	if o.sender, err = oscoreAEAD(oscoreDerive(secret, salt, senderID, "Key", oscoreKeyLen)); err != nil {
+		return nil, err
+	}
+	if o.recipient, err = oscoreAEAD(oscoreDerive(secret, salt, recipientID, "Key", oscoreKeyLen)); err != nil {
+		return nil, err
+	}
+	o.commonIV = oscoreDerive(secret, salt, nil, "IV", oscoreNonceLen)
+	if err := o.loadSeq(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// oscoreDerive derives a key or the common IV (RFC 8613, section 3.2.1).
+func oscoreDerive(secret, salt, id []byte, typ string, length int) []byte {
+	var info []byte
+	info = cborHead(info, 4, 5)
+	info = cborBytes(info, id)
+	info = append(info, 0xf6) // no ID Context
+	info = cborHead(info, 0, oscoreAlg)
+	info = cborHead(info, 3, len(typ))
+	info = append(info, typ...)
+	info = cborHead(info, 0, length)
+	out := make([]byte, length)
+	// reading less than 255 hash lengths from HKDF cannot fail
+	_, _ = io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out)
+	return out
+}
+
+func oscoreAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return ccm.NewCCM(block, oscoreTagLen, oscoreNonceLen)
+}
+
+// loadSeq resumes the sequence number from seqFile, skipping every number that
+// may have been used before.
+func (o *oscoreContext) loadSeq() error {
+	b, err := os.ReadFile(o.seqFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("read sequenceFile: %v", err)
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("sequenceFile %s is corrupt: %v", o.seqFile, err)
+	}
+	o.seq, o.reserved = seq, seq
+	return nil
+}
+
+// nextSeq returns the next sender sequence number, first recording a new block
+// of reserved numbers in seqFile when the current one is used up.
+func (o *oscoreContext) nextSeq() (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.seq > oscoreMaxSeq {
+		return 0, fmt.Errorf("oscore sequence numbers exhausted, a new master secret is needed")
+	}
+	if o.seq >= o.reserved {
+		reserved := o.seq + oscoreSeqReserve
+		if err := o.saveSeq(reserved); err != nil {
+			return 0, fmt.Errorf("write oscore sequenceFile: %v", err)
+		}
+		o.reserved = reserved
+	}
+	seq := o.seq
+	o.seq++
+	return seq, nil
+}
+
+// saveSeq replaces seqFile with reserved. The new file is synced before the
+// rename and its directory after it, so a crash cannot bring back an older
+// reservation and with it sequence numbers already used.
+func (o *oscoreContext) saveSeq(reserved uint64) error {
+	dir := filepath.Dir(o.seqFile)
+	tmp := filepath.Join(dir, "."+filepath.Base(o.seqFile)+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strconv.FormatUint(reserved, 10) + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, o.seqFile); err != nil {
+		return err
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// nonce builds the AEAD nonce from the ID of the partial IV's sender.
+func (o *oscoreContext) nonce(id, piv []byte) []byte {
+	n := make([]byte, oscoreNonceLen)
+	n[0] = byte(len(id))
+	copy(n[1+oscoreMaxIDLen-len(id):], id)
+	copy(n[oscoreNonceLen-len(piv):], piv)
+	for i := range n {
+		n[i] ^= o.commonIV[i]
+	}
+	return n
+}
+
+// aad is the additional authenticated data of a request and its responses.
+func (o *oscoreContext) aad(piv []byte) []byte {
+	var ext []byte
+	ext = cborHead(ext, 4, 5)
+	ext = cborHead(ext, 0, 1) // OSCORE version
+	ext = cborHead(ext, 4, 1)
+	ext = cborHead(ext, 0, oscoreAlg)
+	ext = cborBytes(ext, o.senderID)
+	ext = cborBytes(ext, piv)
+	ext = cborBytes(ext, nil) // no Class I options
+
+	var aad []byte
+	aad = cborHead(aad, 4, 3)
+	aad = cborHead(aad, 3, len("Encrypt0"))
+	aad = append(aad, "Encrypt0"...)
+	aad = cborBytes(aad, nil)
+	return cborBytes(aad, ext)
+}
+
+// protect replaces req with its OSCORE protected form: the code and Class E
+// options move into the encrypted payload, leaving the Class U options, the
+// OSCORE option and, for observe requests, Observe.
+func (o *oscoreContext) protect(req *pool.Message) (*oscoreRequest, error) {
+	seq, err := o.nextSeq()
+	if err != nil {
+		return nil, err
+	}
+	piv := []byte{byte(seq)}
+	for v := seq >> 8; v > 0; v >>= 8 {
+		piv = append([]byte{byte(v)}, piv...)
+	}
+
+	var inner, outer message.Options
+	for _, opt := range req.Options() {
+		if outerOptions[opt.ID] {
+			outer = append(outer, opt)
+		} else {
+			inner = append(inner, opt)
+		}
+	}
+	observe := req.HasOption(message.Observe)
+	if observe {
+		obs, _ := req.Options().GetBytes(message.Observe)
+		outer = outer.Add(message.Option{ID: message.Observe, Value: obs})
+	}
+	body, err := req.ReadBody()
+	if err != nil {
+		return nil, err
+	}
+	optLen, _ := inner.Marshal(nil)
+	plain := make([]byte, 1+optLen, 1+optLen+1+len(body))
+	plain[0] = byte(req.Code())
+	if _, err := inner.Marshal(plain[1:]); err != nil {
+		return nil, fmt.Errorf("oscore: %v", err)
+	}
+	if len(body) > 0 {
+		plain = append(append(plain, 0xff), body...)
+	}
+
+	st := &oscoreRequest{piv: piv, nonce: o.nonce(o.senderID, piv), aad: o.aad(piv)}
+	sealed := o.sender.Seal(nil, st.nonce, plain, st.aad)
+
+	optValue := append([]byte{byte(len(piv)) | 0x08}, piv...)
+	optValue = append(optValue, o.senderID...)
+	outer = outer.Add(message.Option{ID: oscoreOption, Value: optValue})
+	req.ResetOptionsTo(outer)
+	if observe {
+		req.SetCode(codeFETCH)
+	} else {
+		req.SetCode(codes.POST)
+	}
+	req.SetBody(bytes.NewReader(sealed))
+	return st, nil
+}
+
+// unprotect verifies and decrypts a response to st in place. It returns the
+// partial IV of a notification, hasPIV being false when the response reuses
+// the request's.
+func (o *oscoreContext) unprotect(resp *pool.Message, st *oscoreRequest) (seq uint64, hasPIV bool, err error) {
+	opt, err := resp.Options().GetBytes(oscoreOption)
+	if err != nil {
+		return 0, false, fmt.Errorf("oscore: unprotected response %v", resp.Code())
+	}
+	nonce := st.nonce
+	if len(opt) > 0 {
+		n := int(opt[0] & 0x07)
+		if len(opt) < 1+n {
+			return 0, false, fmt.Errorf("oscore: malformed option")
+		}
+		if n > 0 {
+			piv := opt[1 : 1+n]
+			nonce = o.nonce(o.recipientID, piv)
+			for _, b := range piv {
+				seq = seq<<8 | uint64(b)
+			}
+			hasPIV = true
+		}
+	}
+	sealed, err := resp.ReadBody()
+	if err != nil {
+		return 0, false, err
+	}
+	plain, err := o.recipient.Open(nil, nonce, sealed, st.aad)
+	if err != nil || len(plain) == 0 {
+		return 0, false, fmt.Errorf("oscore: response does not verify")
+	}
+
+	inner := make(message.Options, 0, 16)
+	n, err := inner.Unmarshal(plain[1:], message.CoapOptionDefs)
+	if err != nil {
+		return 0, false, fmt.Errorf("oscore: decrypted options: %v", err)
+	}
+	if obs, err := resp.Options().GetBytes(message.Observe); err == nil {
+		inner = inner.Remove(message.Observe).Add(message.Option{ID: message.Observe, Value: obs})
+	}
+	resp.ResetOptionsTo(inner)
+	resp.SetCode(codes.Code(plain[0]))
+	resp.SetBody(bytes.NewReader(plain[1+n:]))
+	return seq, hasPIV, nil
+}
+
+// cborHead appends a CBOR head of the given major type and argument.
+func cborHead(b []byte, major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return append(b, major<<5|byte(n))
+	case n < 256:
+		return append(b, major<<5|24, byte(n))
+	default:
+		return append(b, major<<5|25, byte(n>>8), byte(n))
+	}
+}
+
+func cborBytes(b, v []byte) []byte {
+	return append(cborHead(b, 2, len(v)), v...)
+}
+
+// get issues a GET to path, protected with OSCORE when configured. Like put and
+// observe it waits for a request slot first, see acquireRequest.
+func (c *CustomizedClient) get(ctx context.Context, conn *udpClient.Conn, path string, opts ...message.Option) (*pool.Message, error) {
+	release, err := c.acquireRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	c.tap(TapRequest, codes.GET, path, 0)
+	req, err := conn.NewGetRequest(ctx, path, append(opts[:len(opts):len(opts)], size2Request)...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.ReleaseMessage(req)
+	done := c.trackBlocks(path, req.Token())
+	var resp *pool.Message
+	if c.oscore == nil {
+		resp, err = conn.Do(req)
+	} else {
+		resp, err = c.doProtected(conn, req)
+	}
+	done()
+	c.tapResponse(path, resp)
+	return resp, err
+}
+
+// put issues a PUT to path, protected with OSCORE when configured.
+func (c *CustomizedClient) put(ctx context.Context, conn *udpClient.Conn, path string, cf message.MediaType, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
+	return c.send(ctx, conn, codes.PUT, path, cf, payload, opts...)
+}
+
+// send issues a request with a body (PUT, POST, PATCH or iPATCH) to path,
+// protected with OSCORE when configured.
+func (c *CustomizedClient) send(ctx context.Context, conn *udpClient.Conn, method codes.Code, path string, cf message.MediaType, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
+	release, err := c.acquireRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	req, err := conn.NewPutRequest(ctx, path, cf, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.ReleaseMessage(req)
+	req.SetCode(method)
+	c.tap(TapRequest, method, path, readerLen(payload))
+	var resp *pool.Message
+	if c.oscore == nil {
+		resp, err = conn.Do(req)
+	} else {
+		resp, err = c.doProtected(conn, req)
+	}
+	c.tapResponse(path, resp)
+	return resp, err
+}
+
+func (c *CustomizedClient) doProtected(conn *udpClient.Conn, req *pool.Message) (*pool.Message, error) {
+	st, err := c.oscore.protect(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := c.oscore.unprotect(resp, st); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// observe registers an observation of path, protected with OSCORE when configured.
+// Protected notifications that do not verify, or replay an older partial IV, are
+// dropped. Cancelling a protected observation is not protected; the device then
+// ends it on the next notification the client rejects.
+func (c *CustomizedClient) observe(ctx context.Context, conn *udpClient.Conn, path string, handler func(*pool.Message), opts ...message.Option) (coapClient.Observation, error) {
+	release, err := c.acquireRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	c.tap(TapRequest, codes.GET, path, 0)
+	next := handler
+	handler = func(m *pool.Message) {
+		c.tapResponse(path, m)
+		next(m)
+	}
+	if c.oscore == nil {
+		return conn.Observe(ctx, path, handler, opts...)
+	}
+	req, err := conn.NewObserveRequest(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.ReleaseMessage(req)
+	st, err := c.oscore.protect(req)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	var last uint64
+	// seen is set by the first partial IV, which may be 0
+	seen := false
+	return conn.DoObserve(req, func(m *pool.Message) {
+		seq, hasPIV, err := c.oscore.unprotect(m, st)
+		if err != nil {
+			c.V(LogObserve, 2).Infof("CoAP observe %s: %v, dropping notification", path, err)
+			return
+		}
+		mu.Lock()
+		replay := hasPIV && seen && seq <= last
+		if hasPIV && !replay {
+			last, seen = seq, true
+		}
+		mu.Unlock()
+		if replay {
+			c.V(LogObserve, 2).Infof("CoAP observe %s: replayed notification %d, dropping", path, seq)
+			return
+		}
+		handler(m)
+	})
+}
@@ -0,0 +1,74 @@
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"k8s.io/klog/v2"
+)
+
+// Reactions to an unusable payload, see OnParseError.
+const (
+	// ParseKeepLast leaves the last good value in place (default).
+	ParseKeepLast = "keepLast"
+	// ParseSetDefault replaces the value with the visitor's DefaultValue, or the
+	// zero value of the property when none is registered.
+	ParseSetDefault = "setDefault"
+	// ParseReportError makes GetDeviceData fail until a good payload arrives.
+	ParseReportError = "reportError"
+)
+
+// validateParseErrorModes checks the OnParseError entries.
+func (c *CustomizedClient) validateParseErrorModes() error {
+	for prop, mode := range c.ProtocolConfig.OnParseError {
+		switch mode {
+		case ParseKeepLast, ParseSetDefault, ParseReportError:
+		default:
+			return fmt.Errorf("onParseError: invalid mode %q for %s", mode, prop)
+		}
+	}
+	c.parseErrs = make(map[string]error)
+	return nil
+}
+
+// payloadError reports why a normalized payload cannot be used as the value of
+// prop: it is empty, looks like JSON but does not parse (e.g. truncated), or is
+// not a recognized boolean for motion. JSON bodies are left to JSONPath.
+func (c *CustomizedClient) payloadError(prop, v string) error {
+	if v == "" {
+		return errors.New("empty payload")
+	}
+	if v[0] == '{' || v[0] == '[' || v[0] == '"' {
+		if !json.Valid([]byte(v)) {
+			return fmt.Errorf("invalid or truncated JSON %q", v)
+		}
+		return nil
+	}
+	if prop == "motion" {
+		if _, ok := c.boolTokens.Parse(v); !ok {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+	}
+	return nil
+}
+
+// handleParseError applies the OnParseError mode of prop to a failed payload.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) handleParseError(prop string, err error) {
+	mode := c.ProtocolConfig.OnParseError[prop]
+	if mode == "" {
+		mode = ParseKeepLast
+	}
+	klog.Warningf("CoAP %s: unusable payload: %v (onParseError=%s)", prop, err, mode)
+	switch mode {
+	case ParseSetDefault:
+		var def interface{} = c.visitors[prop].VisitorConfigData.DefaultValue
+		if def == "" && prop == "motion" {
+			def = false
+		}
+		c.setValue(prop, def)
+	case ParseReportError:
+		c.parseErrs[prop] = err
+	}
+}
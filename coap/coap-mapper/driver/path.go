@@ -0,0 +1,79 @@
+package driver
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPathSegment is the longest Uri-Path option value CoAP allows.
+const maxPathSegment = 255
+
+// cleanPath normalizes a configured resource path so "motion", "/motion/" and
+// "//motion" all mean "/motion". Each Uri-Path option carries the raw bytes of a
+// segment, so percent-escapes are decoded ("/living%20room" reaches the device
+// as "living room"). Queries, fragments, dot segments and escapes that go-coap
+// would split or send literally are rejected.
+func cleanPath(raw string) (string, error) {
+	if strings.ContainsAny(raw, "?#") {
+		return "", fmt.Errorf("path %q: queries and fragments are not part of the path, use the query options", raw)
+	}
+	var segments []string
+	for _, seg := range strings.Split(raw, "/") {
+		if seg == "" {
+			continue
+		}
+		dec, err := url.PathUnescape(seg)
+		if err != nil {
+			return "", fmt.Errorf("path %q: invalid escape in %q", raw, seg)
+		}
+		switch {
+		case dec == "." || dec == "..":
+			return "", fmt.Errorf("path %q: dot segments are not allowed", raw)
+		case strings.Contains(dec, "/"):
+			return "", fmt.Errorf("path %q: escaped slash in %q", raw, seg)
+		case !utf8.ValidString(dec) || strings.IndexFunc(dec, isControl) >= 0:
+			return "", fmt.Errorf("path %q: segment %q is not printable UTF-8", raw, seg)
+		case len(dec) > maxPathSegment:
+			return "", fmt.Errorf("path %q: segment longer than %d bytes", raw, maxPathSegment)
+		}
+		segments = append(segments, dec)
+	}
+	return "/" + strings.Join(segments, "/"), nil
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// cleanPaths runs cleanPath over every configured path, the ones used to observe,
+// poll, write and health check alike. Optional paths left empty stay empty.
+func (c *CustomizedClient) cleanPaths() error {
+	paths := []*string{&c.ProtocolConfig.MotionPath, &c.ProtocolConfig.LastPath, &c.ProtocolConfig.ClassPath}
+	for _, p := range []*string{&c.ProtocolConfig.BatchPath, &c.ProtocolConfig.StatusPath} {
+		if *p != "" {
+			paths = append(paths, p)
+		}
+	}
+	for i := range c.ProtocolConfig.Properties {
+		paths = append(paths, &c.ProtocolConfig.Properties[i].Path)
+	}
+	for _, p := range paths {
+		clean, err := cleanPath(*p)
+		if err != nil {
+			return err
+		}
+		*p = clean
+	}
+	for name, parts := range c.ProtocolConfig.Composites {
+		for field, p := range parts {
+			clean, err := cleanPath(p)
+			if err != nil {
+				return fmt.Errorf("composite %s: part %s: %v", name, field, err)
+			}
+			parts[field] = clean
+		}
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mapper-common/pkg/codec"
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// resource binds a property to the CoAP resource it is read from.
+type resource struct {
+	prop    string
+	path    string
+	observe bool
+}
+
+// legacyProperties are configured by the dedicated ConfigData fields and have default paths.
+var legacyProperties = map[string]bool{"motion": true, "last_detection": true, "class": true}
+
+// resources lists the properties served by the device and their resources:
+// the three legacy properties followed by the additional Properties entries.
+func (c *CustomizedClient) resources() []resource {
+	res := []resource{
+		{prop: "motion", path: c.ProtocolConfig.MotionPath, observe: c.ProtocolConfig.ObserveMotion},
+		{prop: "last_detection", path: c.ProtocolConfig.LastPath, observe: c.ProtocolConfig.ObserveLast},
+		{prop: "class", path: c.ProtocolConfig.ClassPath, observe: c.ProtocolConfig.ObserveClass},
+	}
+	for _, p := range c.ProtocolConfig.Properties {
+		if !legacyProperties[p.Name] {
+			res = append(res, resource{prop: p.Name, path: p.Path, observe: p.Observe})
+		}
+	}
+	return res
+}
+
+// applyProperties validates the Properties list. Every entry needs an explicit path;
+// only the legacy properties get default paths. An entry named like a legacy property
+// takes precedence over the dedicated fields (MotionPath, ObserveMotion, MotionQuery, ...).
+// Must run before the legacy defaults are filled in.
+func (c *CustomizedClient) applyProperties() error {
+	seen := make(map[string]bool, len(c.ProtocolConfig.Properties))
+	for _, p := range c.ProtocolConfig.Properties {
+		if p.Name == "" {
+			return fmt.Errorf("properties: entry without name")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("properties: duplicate entry %s", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Path == "" {
+			return fmt.Errorf("properties: %s requires a path", p.Name)
+		}
+		switch p.Name {
+		case "motion":
+			c.ProtocolConfig.MotionPath, c.ProtocolConfig.ObserveMotion, c.ProtocolConfig.MotionQuery = p.Path, p.Observe, p.Query
+		case "last_detection":
+			c.ProtocolConfig.LastPath, c.ProtocolConfig.ObserveLast, c.ProtocolConfig.LastQuery = p.Path, p.Observe, p.Query
+		case "class":
+			c.ProtocolConfig.ClassPath, c.ProtocolConfig.ObserveClass, c.ProtocolConfig.ClassQuery = p.Path, p.Observe, p.Query
+		}
+	}
+	c.values = make(map[string]string)
+	return nil
+}
+
+// parseResources fills in and validates the resource settings: paths with
+// their defaults and the Addr prefix, queries, virtual host, acceptable codes,
+// normalizers and composites.
+func (c *CustomizedClient) parseResources(prefix string) error {
+	if err := c.applyProperties(); err != nil {
+		return err
+	}
+	// default paths only exist for the legacy properties
+	if c.ProtocolConfig.MotionPath == "" {
+		c.ProtocolConfig.MotionPath = "/motion"
+	}
+	if c.ProtocolConfig.LastPath == "" {
+		c.ProtocolConfig.LastPath = "/last_detection"
+	}
+	if c.ProtocolConfig.ClassPath == "" {
+		c.ProtocolConfig.ClassPath = "/class"
+	}
+	if prefix != "" && c.ProtocolConfig.PrefixPaths {
+		c.prefixPaths(prefix)
+	}
+	if err := c.parseQueries(); err != nil {
+		return err
+	}
+	if err := c.parseVirtualHost(); err != nil {
+		return err
+	}
+	if err := c.parseAcceptableCodes(); err != nil {
+		return err
+	}
+	if err := c.parseNormalizers(); err != nil {
+		return err
+	}
+	if err := c.validateComposites(); err != nil {
+		return err
+	}
+	return c.cleanPaths()
+}
+
+// parseNormalizers compiles ProtocolConfig.Normalize.
+func (c *CustomizedClient) parseNormalizers() error {
+	n, err := value.CompileNormalizers(c.ProtocolConfig.Normalize)
+	if err != nil {
+		return err
+	}
+	c.normalizers = n
+	return nil
+}
+
+// parseBoolTokens installs BoolTokens, or the shared defaults when unset.
+func (c *CustomizedClient) parseBoolTokens() error {
+	t, err := value.BoolTokensOrDefault(c.ProtocolConfig.BoolTokens)
+	if err != nil {
+		return err
+	}
+	c.boolTokens = t
+	return nil
+}
+
+// resourceFor looks up the resource backing prop.
+func (c *CustomizedClient) resourceFor(prop string) (resource, bool) {
+	for _, r := range c.resources() {
+		if r.prop == prop {
+			return r, true
+		}
+	}
+	return resource{}, false
+}
+
+// applyPayload parses body into the cached value of prop and records a successful read.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) applyPayload(prop string, body []byte) {
+	v, err := c.decodePayload(prop, body)
+	if err == nil {
+		err = c.payloadError(prop, v)
+	}
+	// TimestampFormat rewrites a timestamp in the canonical form
+	if format := c.visitors[prop].VisitorConfigData.TimestampFormat; err == nil && format != "" {
+		v, err = value.CanonicalTimestamp(v, format)
+	}
+	c.rawPayloads[prop] = body
+	if err != nil {
+		c.handleParseError(prop, err)
+		return
+	}
+	delete(c.parseErrs, prop)
+	v = c.smooth(prop, v)
+	switch prop {
+	case "motion":
+		c.motion = c.boolTokens.IsTrue(v)
+	case "last_detection":
+		c.lastDetected = v
+	case "class":
+		c.class = v
+	default:
+		c.values[prop] = v
+	}
+	klog.V(4).Infof("CoAP %s raw payload: %q", prop, body)
+	c.lastRead[prop] = time.Now()
+}
+
+// setValue stores an already parsed value for prop. Caller must hold deviceMutex.
+func (c *CustomizedClient) setValue(prop string, v interface{}) {
+	switch prop {
+	case "motion":
+		c.motion = c.jsonValueToBool(v)
+	case "last_detection":
+		c.lastDetected = value.Stringify(v)
+	case "class":
+		c.class = value.Stringify(v)
+	default:
+		c.values[prop] = value.Stringify(v)
+	}
+}
+
+// propertyValue converts an already parsed value to the type setValue caches
+// for prop, without caching it.
+func (c *CustomizedClient) propertyValue(prop string, v interface{}) interface{} {
+	if prop == "motion" {
+		return c.jsonValueToBool(v)
+	}
+	return value.Stringify(v)
+}
+
+// cachedValue returns the cached value of prop. Caller must hold deviceMutex.
+func (c *CustomizedClient) cachedValue(prop string) interface{} {
+	switch prop {
+	case "motion":
+		return c.motion
+	case "last_detection":
+		return c.lastDetected
+	case "class":
+		return c.class
+	}
+	return c.values[prop]
+}
+
+// fallback decides what GetDeviceData returns when no fresh value is available.
+// The precedence is: fresh read > last-known-good > DefaultValue > error.
+//
+// A property counts as fresh when its GET just succeeded (readErr is nil), or when it
+// is observed and a notification has been received. If a GET failed (or the client is disconnected)
+// the last-known-good value is used unless ReturnErrorOnStale is set. If nothing was
+// ever read, DefaultValue is used when configured; otherwise an error is returned when
+// ReturnErrorOnStale is set, and the zero value is returned when it is not.
+//
+// ok is false when the caller should go on and return the cached value.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) fallback(visitor *VisitorConfig, prop string, readErr error) (v interface{}, ok bool, err error) {
+	cfg := visitor.VisitorConfigData
+	_, seen := c.lastRead[prop]
+	switch {
+	case seen && readErr == nil:
+		return nil, false, nil
+	case seen:
+		if cfg.ReturnErrorOnStale {
+			return nil, true, fmt.Errorf("read failed and returnErrorOnStale is set: %w", readErr)
+		}
+		return nil, false, nil
+	case cfg.DefaultValue != "":
+		if prop == "motion" {
+			return c.boolTokens.IsTrue(cfg.DefaultValue), true, nil
+		}
+		return cfg.DefaultValue, true, nil
+	case cfg.ReturnErrorOnStale:
+		if readErr != nil {
+			return nil, true, fmt.Errorf("no successful read yet: %w", readErr)
+		}
+		return nil, true, fmt.Errorf("property %s: no successful read yet", prop)
+	}
+	return nil, false, nil
+}
+
+// rawPropertyPrefix selects the raw payload of a property, e.g. "last_raw_motion".
+const rawPropertyPrefix = "last_raw_"
+
+// GetRawPayload returns a copy of the last untouched body received for prop and
+// when it arrived. The time is zero if nothing was received yet.
+func (c *CustomizedClient) GetRawPayload(prop string) ([]byte, time.Time) {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return append([]byte(nil), c.rawPayloads[prop]...), c.lastRead[prop]
+}
+
+// RegisterVisitor records the visitor config of a property so GetProperty and
+// SetProperty apply the same settings (ValueMap, NoResponse, ...) as the twin.
+// It rejects an invalid BinaryType, Endianness, TimestampFormat, WriteMethod,
+// SmoothingAlpha, AcceptFormats, Timeout or Retries and an unknown Codec.
+func (c *CustomizedClient) RegisterVisitor(visitor *VisitorConfig) error {
+	if cfg := visitor.VisitorConfigData; cfg.BinaryType != "" || cfg.Endianness != "" {
+		if err := value.ValidateBinary(cfg.BinaryType, cfg.Endianness); err != nil {
+			return fmt.Errorf("property %s: %v", cfg.PropertyName, err)
+		}
+	}
+	if cfg := visitor.VisitorConfigData; cfg.TimestampFormat != "" {
+		if cfg.PropertyName == "motion" {
+			return fmt.Errorf("property motion: timestampFormat does not apply to a boolean")
+		}
+		if err := value.ValidateTimestampFormat(cfg.TimestampFormat); err != nil {
+			return fmt.Errorf("property %s: %v", cfg.PropertyName, err)
+		}
+	}
+	if cfg := visitor.VisitorConfigData; cfg.Codec != "" {
+		if cfg.BinaryType != "" {
+			return fmt.Errorf("property %s: binaryType and codec are exclusive", cfg.PropertyName)
+		}
+		if _, err := codec.Lookup(cfg.Codec); err != nil {
+			return fmt.Errorf("property %s: %v", cfg.PropertyName, err)
+		}
+	}
+	if _, err := writeMethod(visitor.VisitorConfigData.WriteMethod); err != nil {
+		return fmt.Errorf("property %s: %v", visitor.VisitorConfigData.PropertyName, err)
+	}
+	if err := validateSmoothing(visitor.VisitorConfigData); err != nil {
+		return fmt.Errorf("property %s: %v", visitor.VisitorConfigData.PropertyName, err)
+	}
+	if err := validateAcceptFormats(visitor.VisitorConfigData); err != nil {
+		return fmt.Errorf("property %s: %v", visitor.VisitorConfigData.PropertyName, err)
+	}
+	if err := validateRetry(visitor.VisitorConfigData); err != nil {
+		return fmt.Errorf("property %s: %v", visitor.VisitorConfigData.PropertyName, err)
+	}
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	if c.visitors == nil {
+		c.visitors = make(map[string]VisitorConfig)
+	}
+	c.visitors[visitor.VisitorConfigData.PropertyName] = *visitor
+	return nil
+}
+
+// visitorFor returns the registered visitor of prop, or a bare one naming it.
+func (c *CustomizedClient) visitorFor(prop string) *VisitorConfig {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return c.registeredVisitor(prop)
+}
+
+// registeredVisitor is visitorFor without the locking. Caller must hold deviceMutex.
+func (c *CustomizedClient) registeredVisitor(prop string) *VisitorConfig {
+	if v, ok := c.visitors[prop]; ok {
+		return &v
+	}
+	return &VisitorConfig{ProtocolName: c.ProtocolConfig.ProtocolName, VisitorConfigData: VisitorConfigData{PropertyName: prop}}
+}
+
+// DisableProperties marks properties that must not be observed, subscribed or read.
+// It must be called before InitDevice; changing the set requires a restart of the device.
+func (c *CustomizedClient) DisableProperties(names []string) {
+	c.disabled = make(map[string]bool, len(names))
+	for _, name := range names {
+		c.disabled[name] = true
+	}
+}
+
+// propertyEnabled reports whether prop was not disabled with DisableProperties.
+func (c *CustomizedClient) propertyEnabled(prop string) bool {
+	return !c.disabled[prop]
+}
+
+// inferValue converts v to the type value.Infer finds when the visitor leaves
+// DataType empty and sets InferType. The type is logged the first time it is
+// inferred for a property. Caller must hold deviceMutex.
+func (c *CustomizedClient) inferValue(cfg VisitorConfigData, v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || !cfg.InferType || cfg.DataType != "" {
+		return v
+	}
+	inferred, typ, first := c.inferredTypes.Infer(cfg.PropertyName, s)
+	if first {
+		c.V(LogReport, 0).InfoS("Inferred property type", "property", cfg.PropertyName, "type", typ)
+	}
+	return inferred
+}
+
+// GetProperty reads a property by name without a VisitorConfig, through the same
+// path as GetDeviceData.
+func (c *CustomizedClient) GetProperty(name string) (interface{}, error) {
+	return c.GetPropertyContext(context.Background(), name)
+}
+
+// GetPropertyContext is GetProperty traced as a child of the span in ctx.
+func (c *CustomizedClient) GetPropertyContext(ctx context.Context, name string) (interface{}, error) {
+	return c.GetDeviceDataContext(ctx, c.visitorFor(name))
+}
+
+// SetProperty writes a property by name without a VisitorConfig, through the same
+// path as DeviceDataWrite.
+func (c *CustomizedClient) SetProperty(name string, v interface{}) error {
+	return c.DeviceDataWrite(c.visitorFor(name), "", name, v)
+}
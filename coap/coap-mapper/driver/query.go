@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+)
+
+// healthQueryKey is the queryOpts key used by the connection health check.
+const healthQueryKey = "health"
+
+// queryOptions converts a query string such as "type=motion&unit=%C2%B0C" into one
+// Uri-Query option per parameter. Percent-encoding in the input is decoded, since
+// CoAP carries option values unescaped (RFC 7252 §6.4). A leading "?" is ignored.
+func queryOptions(raw string) ([]message.Option, error) {
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "?")
+	if raw == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", raw, err)
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var opts []message.Option
+	for _, k := range keys {
+		for _, v := range values[k] {
+			q := k
+			if v != "" {
+				q = k + "=" + v
+			}
+			if len(q) > 255 {
+				return nil, fmt.Errorf("query parameter %q exceeds 255 bytes", k)
+			}
+			opts = append(opts, message.Option{ID: message.URIQuery, Value: []byte(q)})
+		}
+	}
+	return opts, nil
+}
+
+// parseQueries validates the configured queries and caches them as CoAP options.
+func (c *CustomizedClient) parseQueries() error {
+	health := c.ProtocolConfig.HealthQuery
+	if health == "" {
+		health = c.ProtocolConfig.MotionQuery
+	}
+	raw := map[string]string{
+		"motion":         c.ProtocolConfig.MotionQuery,
+		"last_detection": c.ProtocolConfig.LastQuery,
+		"class":          c.ProtocolConfig.ClassQuery,
+		healthQueryKey:   health,
+	}
+	for _, p := range c.ProtocolConfig.Properties {
+		if !legacyProperties[p.Name] {
+			raw[p.Name] = p.Query
+		}
+	}
+	c.queryOpts = make(map[string][]message.Option, len(raw))
+	for key, q := range raw {
+		opts, err := queryOptions(q)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		c.queryOpts[key] = opts
+	}
+	return nil
+}
+
+// parseVirtualHost caches the Uri-Host/Uri-Port options selecting a virtual
+// resource behind a shared endpoint. They are sent with every request.
+func (c *CustomizedClient) parseVirtualHost() error {
+	c.hostOpts = nil
+	if h := c.ProtocolConfig.UriHost; h != "" {
+		if len(h) > 255 {
+			return fmt.Errorf("uriHost %q exceeds 255 bytes", h)
+		}
+		c.hostOpts = append(c.hostOpts, message.Option{ID: message.URIHost, Value: []byte(h)})
+	}
+	if p := c.ProtocolConfig.UriPort; p != 0 {
+		if p < 0 || p > 65535 {
+			return fmt.Errorf("invalid uriPort %d", p)
+		}
+		buf := make([]byte, 4)
+		n, err := message.EncodeUint32(buf, uint32(p))
+		if err != nil {
+			return fmt.Errorf("encode uriPort %d: %w", p, err)
+		}
+		c.hostOpts = append(c.hostOpts, message.Option{ID: message.URIPort, Value: buf[:n]})
+	}
+	return nil
+}
+
+// requestOpts returns the options for a request on behalf of key (a property,
+// composite part or healthQueryKey): the virtual host options followed by the
+// key's Uri-Query options. Caller must hold deviceMutex.
+func (c *CustomizedClient) requestOpts(key string) []message.Option {
+	if len(c.hostOpts) == 0 {
+		return c.queryOpts[key]
+	}
+	return append(append([]message.Option(nil), c.hostOpts...), c.queryOpts[key]...)
+}
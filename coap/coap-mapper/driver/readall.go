@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ReadAll returns the current value of every enabled property, composites
+// included. Each property goes through the same path as GetDeviceData with its
+// registered visitor: polled properties are fetched with a GET, observed ones
+// are served from the cache unless their visitor sets ForceRefresh. The GETs
+// are issued first, in parallel as far as MaxConcurrentRequests allows and
+// without deviceMutex; the values are then taken under a single acquisition so
+// they form one consistent snapshot.
+//
+// A property that cannot be read is left out of the map and its error is joined
+// into the returned error, so one unreachable resource does not hide the others.
+func (c *CustomizedClient) ReadAll() (map[string]interface{}, error) {
+	c.deviceMutex.Lock()
+	var props []string
+	for _, r := range c.resources() {
+		props = append(props, r.prop)
+	}
+	composites := make([]string, 0, len(c.ProtocolConfig.Composites))
+	for name := range c.ProtocolConfig.Composites {
+		composites = append(composites, name)
+	}
+	sort.Strings(composites)
+	props = append(props, composites...)
+	c.deviceMutex.Unlock()
+
+	readErrs := make([]error, len(props))
+	var wg sync.WaitGroup
+	for i, prop := range props {
+		wg.Add(1)
+		go func(i int, prop string) {
+			defer wg.Done()
+			readErrs[i] = c.refreshProperty(context.Background(), c.visitorFor(prop))
+		}(i, prop)
+	}
+	wg.Wait()
+
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	values := make(map[string]interface{}, len(props))
+	var errs []error
+	for i, prop := range props {
+		if !c.propertyEnabled(prop) {
+			continue
+		}
+		v, err := c.readProperty(c.registeredVisitor(prop), readErrs[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values[prop] = v
+	}
+	return values, errors.Join(errs...)
+}
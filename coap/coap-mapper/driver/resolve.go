@@ -0,0 +1,104 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"k8s.io/klog/v2"
+)
+
+// Address family preferences for AddressFamily.
+const (
+	FamilyIPv6First = "ipv6first"
+	FamilyIPv4First = "ipv4first"
+	FamilyIPv6Only  = "ipv6"
+	FamilyIPv4Only  = "ipv4"
+)
+
+// validateAddressFamily checks AddressFamily, defaulting it to FamilyIPv6First.
+func (c *CustomizedClient) validateAddressFamily() error {
+	switch c.ProtocolConfig.AddressFamily {
+	case "":
+		c.ProtocolConfig.AddressFamily = FamilyIPv6First
+	case FamilyIPv6First, FamilyIPv4First, FamilyIPv6Only, FamilyIPv4Only:
+	default:
+		return fmt.Errorf("invalid addressFamily %q", c.ProtocolConfig.AddressFamily)
+	}
+	return nil
+}
+
+// orderCandidates sorts resolved addresses by the preferred family, dropping
+// the other family for the *only preferences.
+func orderCandidates(ips []net.IPAddr, family string) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			v4 = append(v4, ip.IP)
+		} else {
+			v6 = append(v6, ip.IP)
+		}
+	}
+	switch family {
+	case FamilyIPv4First:
+		return append(v4, v6...)
+	case FamilyIPv6Only:
+		return v6
+	case FamilyIPv4Only:
+		return v4
+	default:
+		return append(v6, v4...)
+	}
+}
+
+// dial connects to addr (Addr or FallbackAddr). A hostname resolving to several addresses is tried one
+// candidate at a time in AddressFamily order; since UDP dials cannot fail on their
+// own, a candidate is only kept if it answers a CoAP ping. Bare IPs and hostnames
+// with a single address are dialed directly as before.
+func (c *CustomizedClient) dial(ctx context.Context, addr string) (*udpClient.Conn, error) {
+	if c.Manager != nil {
+		release, err := c.Manager.acquireDial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return c.udpDial(addr)
+	}
+	rctx, cancel := context.WithTimeout(ctx, getTimeout)
+	ips, err := net.DefaultResolver.LookupIPAddr(rctx, host)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	candidates := orderCandidates(ips, c.ProtocolConfig.AddressFamily)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("resolve %s: no %s address", host, c.ProtocolConfig.AddressFamily)
+	}
+	if len(candidates) == 1 {
+		return c.udpDial(net.JoinHostPort(candidates[0].String(), port))
+	}
+
+	var errs []error
+	for _, ip := range candidates {
+		target := net.JoinHostPort(ip.String(), port)
+		conn, err := c.udpDial(target)
+		if err == nil {
+			pctx, cancel := context.WithTimeout(ctx, healthTimeout)
+			err = conn.Ping(pctx)
+			cancel()
+			if err == nil {
+				klog.V(2).Infof("CoAP %s reachable at %s", host, target)
+				return conn, nil
+			}
+			_ = conn.Close()
+		}
+		klog.V(2).Infof("CoAP candidate %s for %s failed: %v", target, host, err)
+		errs = append(errs, fmt.Errorf("%s: %w", target, err))
+	}
+	return nil, fmt.Errorf("no address of %s reachable: %w", host, errors.Join(errs...))
+}
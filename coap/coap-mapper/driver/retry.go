@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"k8s.io/klog/v2"
+)
+
+// maxRetries caps VisitorConfigData.Retries. Every attempt takes a request slot,
+// see acquireRequest, so more would stall the other properties for too long.
+const maxRetries = 10
+
+// validateRetry checks the Timeout and Retries of a visitor.
+func validateRetry(cfg VisitorConfigData) error {
+	if cfg.Timeout != "" {
+		if d, err := time.ParseDuration(cfg.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", cfg.Timeout)
+		}
+	}
+	if cfg.Retries < 0 || cfg.Retries > maxRetries {
+		return fmt.Errorf("invalid retries %d, must be 0 to %d", cfg.Retries, maxRetries)
+	}
+	return nil
+}
+
+// requestPolicy returns how long a GET of prop may take and how often a failed
+// one is re-issued: the Timeout and Retries of its visitor, getTimeout and none
+// by default. Caller must hold deviceMutex.
+func (c *CustomizedClient) requestPolicy(prop string) (time.Duration, int) {
+	cfg := c.visitors[prop].VisitorConfigData
+	timeout := getTimeout
+	if d, err := time.ParseDuration(cfg.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	return timeout, cfg.Retries
+}
+
+// getRetrying is getAccepting with the poll's timeout per attempt, re-issued up
+// to its retries times after a failed request or a 5.xx answer. ctx bounds all
+// the attempts together. It runs without deviceMutex.
+func (c *CustomizedClient) getRetrying(ctx context.Context, p *pollRequest) (*pool.Message, string, error) {
+	for attempt := 0; ; attempt++ {
+		actx, cancel := context.WithTimeout(ctx, p.timeout)
+		resp, format, err := c.getAccepting(actx, p)
+		cancel()
+		if (err == nil && resp.Code()>>5 != 5) || attempt == p.retries || ctx.Err() != nil {
+			return resp, format, err
+		}
+		if err == nil {
+			err = fmt.Errorf("answered %v", resp.Code())
+		}
+		klog.V(2).Infof("CoAP GET %s for %s failed (attempt %d of %d), retrying: %v", p.path, p.prop, attempt+1, p.retries+1, err)
+	}
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+	"github.com/kubeedge/mapper-framework/pkg/common"
+)
+
+// selfTestPoll is how often SelfTest looks whether the connection is up.
+const selfTestPoll = 100 * time.Millisecond
+
+// RegisterModelProperty records the model of prop, whose DataType, Minimum and
+// Maximum SelfTest checks the value against.
+func (c *CustomizedClient) RegisterModelProperty(prop string, model common.ModelProperty) {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	if c.models == nil {
+		c.models = make(map[string]common.ModelProperty)
+	}
+	c.models[prop] = model
+}
+
+// SelfTest checks the device mapping end to end, e.g. right after provisioning:
+// it waits for the connection until ctx is done, probes the device like the
+// health check does, reads every enabled property once through ReadAll and
+// checks each value against the DataType and bounds of its model, or the
+// DataType of its visitor without one. Every problem found is joined into the
+// returned error; the values that pass are logged.
+func (c *CustomizedClient) SelfTest(ctx context.Context) error {
+	conn, err := c.waitConnected(ctx)
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	if conn != nil {
+		if err := c.healthCheck(ctx, conn); err != nil {
+			return fmt.Errorf("self-test: device at %s does not respond: %v", c.ProtocolConfig.Addr, err)
+		}
+	}
+	values, err := c.ReadAll()
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	props := make([]string, 0, len(values))
+	for prop := range values {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	for _, prop := range props {
+		model, ok := c.models[prop]
+		if !ok {
+			model.DataType = c.registeredVisitor(prop).VisitorConfigData.DataType
+		}
+		if err := value.Check(values[prop], model.DataType, model.Minimum, model.Maximum); err != nil {
+			errs = append(errs, fmt.Errorf("property %s: %v", prop, err))
+			continue
+		}
+		klog.InfoS("Self-test read", "addr", c.ProtocolConfig.Addr, "property", prop, "value", values[prop])
+	}
+	return errors.Join(errs...)
+}
+
+// waitConnected returns the connection once it is up, or an error when ctx ends
+// first. In simulation mode there is no connection and nil is returned.
+func (c *CustomizedClient) waitConnected(ctx context.Context) (*udpClient.Conn, error) {
+	ticker := time.NewTicker(selfTestPoll)
+	defer ticker.Stop()
+	for {
+		c.deviceMutex.Lock()
+		conn, simulated := c.conn, c.ProtocolConfig.Simulate
+		c.deviceMutex.Unlock()
+		if conn != nil || simulated {
+			return conn, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w to %s: %v", ErrNotConnected, c.ProtocolConfig.Addr, ctx.Err())
+		}
+	}
+}
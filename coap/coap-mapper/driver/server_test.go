@@ -0,0 +1,193 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/options/config"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+)
+
+// testServer is a CoAP server on a loopback port standing in for a device.
+// Tests register its resources with handle before a client connects.
+type testServer struct {
+	addr   string
+	router *mux.Router
+
+	mu sync.Mutex
+	// plain GETs per path, without Observe
+	gets map[string]int
+	// observers per path: the connection and token to notify
+	observers map[string]testObserver
+}
+
+type testObserver struct {
+	conn  mux.Conn
+	token message.Token
+}
+
+// newTestServer starts a testServer, stopped when the test ends.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	l, err := net.NewListenUDP("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		addr:      l.LocalAddr().String(),
+		router:    mux.NewRouter(),
+		gets:      make(map[string]int),
+		observers: make(map[string]testObserver),
+	}
+	// handle requests concurrently like a device with several workers; go-coap
+	// serves those of one client in turn by default
+	concurrent := func(req *pool.Message, cc *udpClient.Conn, handler config.HandlerFunc[*udpClient.Conn]) {
+		go cc.ProcessReceivedMessageWithHandler(req, handler)
+	}
+	// without blockwise a message is sent with the message ID it was given
+	srv := udp.NewServer(options.WithMux(s.router), options.WithProcessReceivedMessageFunc(concurrent),
+		options.WithBlockwise(false, blockwise.SZX1024, time.Second))
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = l.Close()
+	})
+	return s
+}
+
+// handle serves path with a 2.05 Content of the text value returns. A GET with
+// Observe 0 registers the client as the observer of path.
+func (s *testServer) handle(path string, value func() string) {
+	_ = s.router.Handle(path, mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		if obs, err := r.Observe(); err == nil && obs == 0 {
+			s.mu.Lock()
+			s.observers[path] = testObserver{conn: w.Conn(), token: append(message.Token(nil), r.Token()...)}
+			s.mu.Unlock()
+			_ = w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte(value())), message.Option{ID: message.Observe, Value: []byte{1}})
+			return
+		}
+		s.countGet(path)
+		_ = w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte(value())))
+	}))
+}
+
+// handleUnobservable serves path like handle, but answers a GET with Observe
+// without the option, like a device that cannot observe the resource.
+func (s *testServer) handleUnobservable(path string, value func() string) {
+	_ = s.router.Handle(path, mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		if _, err := r.Observe(); err != nil {
+			s.countGet(path)
+		}
+		_ = w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte(value())))
+	}))
+}
+
+func (s *testServer) countGet(path string) {
+	s.mu.Lock()
+	s.gets[path]++
+	s.mu.Unlock()
+}
+
+// getCount returns the number of plain GETs of path received.
+func (s *testServer) getCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets[path]
+}
+
+// observer returns the registered observer of path, if any.
+func (s *testServer) observer(path string) (testObserver, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.observers[path]
+	return o, ok
+}
+
+// notify sends the observer of path a NON notification with Observe number seq,
+// the response code and the text body, followed by opts, and returns its
+// message ID.
+func (s *testServer) notify(t *testing.T, path string, seq uint32, code codes.Code, body string, opts ...message.Option) int32 {
+	t.Helper()
+	o, ok := s.observer(path)
+	if !ok {
+		t.Fatalf("no observer of %s", path)
+	}
+	mid := o.conn.(*udpClient.Conn).GetMessageID()
+	s.notifyMID(t, path, mid, seq, code, body, opts...)
+	return mid
+}
+
+// notifyMID is notify with the message ID given, to send a notification again.
+func (s *testServer) notifyMID(t *testing.T, path string, mid int32, seq uint32, code codes.Code, body string, opts ...message.Option) {
+	t.Helper()
+	if err := s.send(path, mid, seq, code, body, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// send is notifyMID returning the error, for use off the test goroutine. A
+// negative mid picks the next message ID.
+func (s *testServer) send(path string, mid int32, seq uint32, code codes.Code, body string, opts ...message.Option) error {
+	o, ok := s.observer(path)
+	if !ok {
+		return fmt.Errorf("no observer of %s", path)
+	}
+	if mid < 0 {
+		mid = o.conn.(*udpClient.Conn).GetMessageID()
+	}
+	m := o.conn.AcquireMessage(context.Background())
+	defer o.conn.ReleaseMessage(m)
+	m.SetCode(code)
+	m.SetToken(o.token)
+	m.SetType(message.NonConfirmable)
+	m.SetMessageID(mid)
+	if code == codes.Content {
+		m.SetObserve(seq)
+	}
+	m.SetContentFormat(message.TextPlain)
+	m.SetBody(bytes.NewReader([]byte(body)))
+	for _, opt := range opts {
+		m.SetOptionBytes(opt.ID, opt.Value)
+	}
+	return o.conn.WriteMessage(m)
+}
+
+// startClient initializes a client with cfg, waits until it is connected and
+// stops it when the test ends.
+func startClient(t *testing.T, cfg ProtocolConfig) *CustomizedClient {
+	t.Helper()
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitDevice(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.StopDevice() })
+	waitFor(t, "connect", func() bool { return c.Diagnostics().Connected })
+	return c
+}
+
+// waitFor polls cond until it holds, failing the test after five seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: not within 5s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,67 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const defaultSimulateInterval = 5 * time.Second
+
+var defaultSimulateClasses = []string{"person", "cat", "unknown"}
+
+// simulateInterval parses the configured generator period, falling back to the default.
+func simulateInterval(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultSimulateInterval, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid simulateInterval %q", raw)
+	}
+	return d, nil
+}
+
+// runSimulation drives the cached values from a simple generator instead of a real device:
+// motion toggles every interval, last_detection is stamped whenever motion turns on and
+// class cycles through SimulateClasses.
+func (c *CustomizedClient) runSimulation(ctx context.Context, interval time.Duration) {
+	classes := c.ProtocolConfig.SimulateClasses
+	if len(classes) == 0 {
+		classes = defaultSimulateClasses
+	}
+
+	c.deviceMutex.Lock()
+	c.isConnected = true
+	c.markConnected()
+	c.deviceMutex.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	next := 0
+	for {
+		select {
+		case <-ctx.Done():
+			c.deviceMutex.Lock()
+			c.isConnected = false
+			c.deviceMutex.Unlock()
+			return
+		case <-ticker.C:
+			c.deviceMutex.Lock()
+			now := time.Now()
+			c.motion = !c.motion
+			if c.motion {
+				c.lastDetected = now.UTC().Format(time.RFC3339)
+				c.class = classes[next%len(classes)]
+				next++
+			}
+			for _, r := range c.resources() {
+				c.lastRead[r.prop] = now
+			}
+			klog.V(2).Infof("CoAP simulate: motion=%v last_detection=%s class=%s", c.motion, c.lastDetected, c.class)
+			c.deviceMutex.Unlock()
+		}
+	}
+}
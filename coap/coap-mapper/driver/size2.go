@@ -0,0 +1,96 @@
+package driver
+
+import (
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"k8s.io/klog/v2"
+)
+
+// BlockTransfer describes the last blockwise response read from a path.
+type BlockTransfer struct {
+	// Expected is the total size the server announced with Size2, -1 if it did not.
+	Expected int64
+	// Received is the number of bytes that arrived, up to the end of the last block.
+	Received int64
+	Blocks   int
+	// Complete is set when the last block (without the more flag) arrived.
+	Complete bool
+}
+
+// size2Request asks the server to announce the total size of a blockwise
+// response: a Size2 option of 0 in the request (RFC 7959 section 4).
+var size2Request = message.Option{ID: message.Size2}
+
+// trackBlocks starts following the Block2 responses to the GET of path sent with
+// token, and returns the function that ends it once the GET returned. A transfer
+// that ended short of its Size2, or without its last block, is logged.
+//
+// go-coap reassembles the blocks and strips Size2 on the way, so the blocks are
+// followed on the receive path by monitorBlocks instead.
+func (c *CustomizedClient) trackBlocks(path string, token message.Token) func() {
+	key := string(token)
+	c.blockMutex.Lock()
+	c.blocksInFlight[key] = &BlockTransfer{Expected: -1}
+	c.blockMutex.Unlock()
+	return func() {
+		c.blockMutex.Lock()
+		defer c.blockMutex.Unlock()
+		t := c.blocksInFlight[key]
+		delete(c.blocksInFlight, key)
+		if t.Blocks == 0 {
+			return
+		}
+		c.blockTransfers[path] = *t
+		switch {
+		case t.Expected >= 0 && t.Received < t.Expected:
+			klog.Warningf("CoAP GET %s: blockwise transfer ended short, received %d of %d bytes", path, t.Received, t.Expected)
+		case !t.Complete:
+			klog.Warningf("CoAP GET %s: blockwise transfer ended short, received %d bytes in %d blocks", path, t.Received, t.Blocks)
+		}
+	}
+}
+
+// monitorReceived sees every message received on the connection before go-coap
+// processes it, retransmissions included. It never drops a message.
+func (c *CustomizedClient) monitorReceived(_ *udpClient.Conn, m *pool.Message) (bool, error) {
+	c.countDuplicate(m)
+	c.monitorBlocks(m)
+	return false, nil
+}
+
+// monitorBlocks records the Block2 responses of the GETs followed by trackBlocks.
+func (c *CustomizedClient) monitorBlocks(m *pool.Message) {
+	block, err := m.GetOptionUint32(message.Block2)
+	if err != nil {
+		return
+	}
+	szx, num, more, err := blockwise.DecodeBlockOption(block)
+	if err != nil {
+		return
+	}
+	c.blockMutex.Lock()
+	defer c.blockMutex.Unlock()
+	t, ok := c.blocksInFlight[string(m.Token())]
+	if !ok {
+		return
+	}
+	if size, err := m.GetOptionUint32(message.Size2); err == nil {
+		t.Expected = int64(size)
+	}
+	n, _ := m.BodySize()
+	if end := num*szx.Size() + n; end > t.Received {
+		t.Received = end
+	}
+	t.Blocks++
+	t.Complete = t.Complete || !more
+}
+
+// receiveMonitor is the dial option installing monitorReceived. go-coap only offers
+// the request monitor as a server option, the client config field is set directly.
+type receiveMonitor struct{ c *CustomizedClient }
+
+func (o receiveMonitor) UDPClientApply(cfg *udpClient.Config) {
+	cfg.RequestMonitor = o.c.monitorReceived
+}
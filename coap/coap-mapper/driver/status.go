@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// reportsHealthy interprets a status resource body: either a JSON object with a
+// "status" field, e.g. {"status":"ok"}, or the plain status text. Only "ok"
+// (case-insensitive) counts as healthy.
+func reportsHealthy(body []byte) bool {
+	status := strings.TrimSpace(string(body))
+	var doc struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &doc); err == nil {
+		status = doc.Status
+	}
+	return strings.EqualFold(strings.TrimSpace(status), "ok")
+}
+
+// statusFromDevice GETs path, the StatusPath, and reports whether the device
+// says it is healthy.
+func (c *CustomizedClient) statusFromDevice(path string) bool {
+	c.deviceMutex.Lock()
+	conn := c.conn
+	opts := c.requestOpts("")
+	c.deviceMutex.Unlock()
+	if conn == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+	resp, err := c.get(ctx, conn, path, opts...)
+	if err != nil {
+		c.V(LogConnection, 2).Infof("CoAP status GET %s failed: %v", path, err)
+		return false
+	}
+	if resp.Code() != codes.Content {
+		c.V(LogConnection, 2).Infof("CoAP status GET %s returned %v", path, resp.Code())
+		return false
+	}
+	body, _ := resp.ReadBody()
+	ok := reportsHealthy(body)
+	if !ok {
+		c.V(LogConnection, 2).Infof("CoAP device reports unhealthy status: %s", body)
+	}
+	return ok
+}
@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// TestStopWhileNotified stops clients while notifications arrive and checks
+// that no observe handler changes the client once StopDevice returned. Run
+// with -race.
+func TestStopWhileNotified(t *testing.T) {
+	s := newTestServer(t)
+	s.handle("/motion", func() string { return "0" })
+	var token string
+	for i := 0; i < 20; i++ {
+		c, err := NewClient(ProtocolConfig{ConfigData: ConfigData{Addr: s.addr, ObserveMotion: true, ShutdownTimeout: "1s"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.InitDevice(); err != nil {
+			t.Fatal(err)
+		}
+		// wait for this client's registration
+		waitFor(t, "observe /motion", func() bool {
+			o, ok := s.observer("/motion")
+			return ok && string(o.token) != token
+		})
+		o, _ := s.observer("/motion")
+		token = string(o.token)
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := uint32(2); ; seq++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if err := s.send("/motion", -1, seq, codes.Content, strconv.Itoa(int(seq%2))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		if err := c.StopDevice(); err != nil {
+			t.Fatal(err)
+		}
+		c.deviceMutex.Lock()
+		last := c.lastRead["motion"]
+		c.deviceMutex.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		c.deviceMutex.Lock()
+		after := c.lastRead["motion"]
+		c.deviceMutex.Unlock()
+		close(done)
+		wg.Wait()
+		if !after.Equal(last) {
+			t.Fatalf("round %d: motion read at %v after StopDevice returned", i, after)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"context"
+	"io"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+)
+
+// Directions passed to MessageTap.
+const (
+	TapRequest  = "request"
+	TapResponse = "response"
+)
+
+// tapQueue is the number of messages buffered for a MessageTap that falls behind.
+const tapQueue = 256
+
+// tapEvent is one message handed to MessageTap.
+type tapEvent struct {
+	direction, code, path string
+	payloadLen            int
+}
+
+// startTap runs MessageTap, when set, on its own goroutine until ctx is done.
+// Requests are often sent with deviceMutex held, so the tap is fed through a
+// queue instead of being called inline.
+func (c *CustomizedClient) startTap(ctx context.Context) {
+	if c.MessageTap == nil {
+		return
+	}
+	c.tapEvents = make(chan tapEvent, tapQueue)
+	go func() {
+		for {
+			select {
+			case e := <-c.tapEvents:
+				c.MessageTap(e.direction, e.code, e.path, e.payloadLen)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// tap queues a message for MessageTap. It never blocks: with the queue full
+// the message is dropped.
+func (c *CustomizedClient) tap(direction string, code codes.Code, path string, payloadLen int) {
+	if c.tapEvents == nil {
+		return
+	}
+	select {
+	case c.tapEvents <- tapEvent{direction: direction, code: code.String(), path: path, payloadLen: payloadLen}:
+	default:
+	}
+}
+
+// tapResponse queues a response or notification received for path.
+func (c *CustomizedClient) tapResponse(path string, resp *pool.Message) {
+	if c.tapEvents == nil || resp == nil {
+		return
+	}
+	n, _ := resp.BodySize()
+	c.tap(TapResponse, resp.Code(), path, int(n))
+}
+
+// readerLen returns the length of a request payload without consuming it.
+func readerLen(r io.ReadSeeker) int {
+	cur, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0
+	}
+	if _, err := r.Seek(cur, io.SeekStart); err != nil {
+		return 0
+	}
+	return int(end - cur)
+}
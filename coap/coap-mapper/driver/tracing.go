@@ -0,0 +1,36 @@
+package driver
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans around device reads. It comes from the global
+// TracerProvider, which is a no-op until the mapper registers one with
+// otel.SetTracerProvider, so tracing costs next to nothing when unused.
+var tracer = otel.Tracer("github.com/kubeedge/coap/driver")
+
+// startSpan starts a span of this device for prop as a child of the span in ctx.
+func (c *CustomizedClient) startSpan(ctx context.Context, name, prop string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("device", c.ProtocolConfig.Addr),
+		attribute.String("property", prop),
+		attribute.String("protocol", "coap"),
+	))
+}
+
+// endSpan records the result of the traced operation and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String("result", "error"))
+	} else {
+		span.SetAttributes(attribute.String("result", "ok"))
+	}
+	span.End()
+}
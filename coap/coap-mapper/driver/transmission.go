@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/udp"
+)
+
+// RFC 7252 §4.8 defaults, as used by go-coap.
+const (
+	defaultAckTimeout    = 2 * time.Second
+	defaultMaxRetransmit = 4
+
+	minAckTimeout      = 100 * time.Millisecond
+	maxAckTimeout      = 60 * time.Second
+	maxMaxRetransmit   = 10
+	transmissionNStart = 1
+)
+
+// parseTransmission validates AckTimeout and MaxRetransmit and returns the matching
+// dial options, or none when both are unset and MaxConcurrentRequests is at most 1.
+//
+// A confirmable request is retransmitted MaxRetransmit times, doubling the wait from
+// AckTimeout each time, so without a response a request gives up after roughly
+// AckTimeout * (2^(MaxRetransmit+1) - 1): 62s with the defaults. Reads and health
+// checks are additionally bounded by their own timeouts (getTimeout, healthTimeout),
+// so lowering these values mainly makes observe registrations and writes fail faster.
+//
+// go-coap holds back a confirmable request while NSTART (RFC 7252 §4.7, default 1)
+// others to the device are waiting for their answer, so NSTART is raised to
+// MaxConcurrentRequests for those requests to actually overlap.
+func (c *CustomizedClient) parseTransmission() ([]udp.Option, error) {
+	nstart := uint32(transmissionNStart)
+	if n := c.ProtocolConfig.MaxConcurrentRequests; n > transmissionNStart {
+		nstart = uint32(n)
+	}
+	if c.ProtocolConfig.AckTimeout == "" && c.ProtocolConfig.MaxRetransmit == nil && nstart == transmissionNStart {
+		return nil, nil
+	}
+	ackTimeout := defaultAckTimeout
+	if c.ProtocolConfig.AckTimeout != "" {
+		d, err := time.ParseDuration(c.ProtocolConfig.AckTimeout)
+		if err != nil || d < minAckTimeout || d > maxAckTimeout {
+			return nil, fmt.Errorf("invalid ackTimeout %q, must be between %v and %v", c.ProtocolConfig.AckTimeout, minAckTimeout, maxAckTimeout)
+		}
+		ackTimeout = d
+	}
+	maxRetransmit := uint32(defaultMaxRetransmit)
+	if c.ProtocolConfig.MaxRetransmit != nil {
+		if *c.ProtocolConfig.MaxRetransmit > maxMaxRetransmit {
+			return nil, fmt.Errorf("invalid maxRetransmit %d, must be at most %d", *c.ProtocolConfig.MaxRetransmit, maxMaxRetransmit)
+		}
+		maxRetransmit = *c.ProtocolConfig.MaxRetransmit
+	}
+	return []udp.Option{options.WithTransmission(nstart, ackTimeout, maxRetransmit)}, nil
+}
@@ -0,0 +1,195 @@
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kubeedge/mapper-common/pkg/state"
+)
+
+// ErrRestartRequired is returned by UpdateConfig when a changed setting only
+// takes effect on a new connection. The caller stops the device and runs
+// InitDevice with the new config instead.
+var ErrRestartRequired = errors.New("config change requires a restart")
+
+// hotFields are the ConfigData fields UpdateConfig applies to a running client:
+// the resources, how they are observed and read, and logging. Everything else
+// (addresses, transport and retransmission tuning, OSCORE, timeouts, request
+// limits, history and event buffers, simulation) is fixed for the life of the
+// connection.
+var hotFields = map[string]bool{
+	"PrefixPaths":        true,
+	"MotionPath":         true,
+	"LastPath":           true,
+	"ClassPath":          true,
+	"Properties":         true,
+	"BatchPath":          true,
+	"StatusPath":         true,
+	"MotionQuery":        true,
+	"LastQuery":          true,
+	"ClassQuery":         true,
+	"HealthQuery":        true,
+	"HealthMode":         true,
+	"AcceptableCodes":    true,
+	"Normalize":          true,
+	"OnParseError":       true,
+	"BoolTokens":         true,
+	"ObserveMotion":      true,
+	"ObserveLast":        true,
+	"ObserveClass":       true,
+	"SeedObserveWithGet": true,
+	"Composites":         true,
+	"LogLevels":          true,
+	"StateMapping":       true,
+}
+
+// UpdateConfig applies a changed protocol config to the running client without
+// reconnecting. Observations whose resource, query or observe flag changed are
+// cancelled and registered again by the connection loop; the other
+// observations, the connection and the cached values of the properties that
+// remain stay. Only the hotFields may change: any other difference returns
+// ErrRestartRequired naming the fields, and the client is left untouched, as
+// it is on a validation error.
+func (c *CustomizedClient) UpdateConfig(cfg ProtocolConfig) error {
+	c.deviceMutex.Lock()
+	spec := c.spec.ConfigData
+	c.deviceMutex.Unlock()
+	if changed := restartFields(spec, cfg.ConfigData); len(changed) > 0 {
+		return fmt.Errorf("%w: %s changed", ErrRestartRequired, strings.Join(changed, ", "))
+	}
+	_, prefix, err := parseAddr(cfg.Addr)
+	if err != nil {
+		return err
+	}
+	// validate on a scratch client so a bad config changes nothing
+	n := &CustomizedClient{ProtocolConfig: cloneConfig(cfg)}
+	if err := n.parseHotConfig(prefix); err != nil {
+		return err
+	}
+
+	c.deviceMutex.Lock()
+	oldRes := make(map[string]resource)
+	oldOpts := make(map[string]string)
+	for _, r := range c.resources() {
+		oldRes[r.prop] = r
+		oldOpts[r.prop] = fmt.Sprint(c.queryOpts[r.prop])
+	}
+	// only the hotFields are written, so the others can be read without the lock
+	setHotFields(&c.ProtocolConfig.ConfigData, n.ProtocolConfig.ConfigData)
+	c.spec = cloneConfig(cfg)
+	c.queryOpts = n.queryOpts
+	c.acceptCodes = n.acceptCodes
+	_, c.checkHealthCode = c.acceptCodes[healthQueryKey]
+	c.normalizers = n.normalizers
+	c.boolTokens = n.boolTokens
+	c.setLogLevels(c.ProtocolConfig.LogLevels)
+	// keep the parts received for the composites and parts that remain
+	for name, parts := range c.compositeParts {
+		for field := range parts {
+			if _, ok := c.ProtocolConfig.Composites[name][field]; !ok {
+				delete(parts, field)
+			}
+		}
+		if len(parts) > 0 {
+			n.compositeParts[name] = parts
+		}
+	}
+	c.compositeParts = n.compositeParts
+	current := make(map[string]bool)
+	for _, r := range c.resources() {
+		current[r.prop] = true
+		if old, ok := oldRes[r.prop]; ok && (old.path != r.path || oldOpts[r.prop] != fmt.Sprint(c.queryOpts[r.prop])) {
+			// the ETag was issued for the old resource
+			delete(c.etags, r.prop)
+		}
+	}
+	for prop := range oldRes {
+		if !current[prop] {
+			c.forgetProperty(prop)
+		}
+	}
+	c.deviceMutex.Unlock()
+
+	select {
+	case c.resync <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// parseHotConfig runs the InitDevice checks of the hotFields.
+func (c *CustomizedClient) parseHotConfig(prefix string) error {
+	if err := c.parseResources(prefix); err != nil {
+		return err
+	}
+	if err := c.validateHealthMode(); err != nil {
+		return err
+	}
+	if err := c.validateParseErrorModes(); err != nil {
+		return err
+	}
+	if err := c.validateLogLevels(); err != nil {
+		return err
+	}
+	if err := state.ValidateMapping(c.ProtocolConfig.StateMapping); err != nil {
+		return err
+	}
+	return c.parseBoolTokens()
+}
+
+// forgetProperty drops the state kept for a property that is no longer
+// configured. Caller must hold deviceMutex.
+func (c *CustomizedClient) forgetProperty(prop string) {
+	delete(c.values, prop)
+	delete(c.rawPayloads, prop)
+	delete(c.lastRead, prop)
+	delete(c.etags, prop)
+	delete(c.parseErrs, prop)
+	delete(c.formats, prop)
+	delete(c.averages, prop)
+	delete(c.uncacheable, prop)
+}
+
+// restartFields returns the json names of the ConfigData fields outside
+// hotFields that differ between old and new.
+func restartFields(old, new ConfigData) []string {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	var changed []string
+	for i := 0; i < ov.NumField(); i++ {
+		f := ov.Type().Field(i)
+		if hotFields[f.Name] || reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		changed = append(changed, name)
+	}
+	return changed
+}
+
+// setHotFields copies the hotFields from src to dst. The other fields keep the
+// values InitDevice filled in (the parsed Addr, FallbackAfter, ...).
+func setHotFields(dst *ConfigData, src ConfigData) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for i := 0; i < dv.NumField(); i++ {
+		if hotFields[dv.Type().Field(i).Name] {
+			dv.Field(i).Set(sv.Field(i))
+		}
+	}
+}
+
+// cloneConfig returns a deep copy of cfg through its JSON form, so InitDevice
+// filling in defaults and cleaning paths does not write through to the copy.
+func cloneConfig(cfg ProtocolConfig) ProtocolConfig {
+	var out ProtocolConfig
+	b, err := json.Marshal(cfg)
+	if err == nil {
+		err = json.Unmarshal(b, &out)
+	}
+	if err != nil {
+		return cfg
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// TestUpdateConfigConcurrent changes the hot config while reads, writes, health
+// checks and observe re-registrations use it. Run with -race.
+func TestUpdateConfigConcurrent(t *testing.T) {
+	s := newTestServer(t)
+	for _, path := range []string{"/motion", "/motion2", "/last_detection", "/class", "/batch", "/batch2"} {
+		s.handle(path, func() string { return "1" })
+	}
+	s.handle("/status", func() string { return "ok" })
+	configs := []ProtocolConfig{
+		{ConfigData: ConfigData{
+			Addr: s.addr, MotionPath: "/motion", ObserveMotion: true, BatchPath: "/batch",
+			LogLevels: map[string]int{LogObserve: 2},
+		}},
+		{ConfigData: ConfigData{
+			Addr: s.addr, MotionPath: "/motion2", ObserveMotion: true, MotionQuery: "unit=1", BatchPath: "/batch2",
+			StatusPath: "/status", HealthMode: HealthModeWellKnown, Composites: map[string]map[string]string{"pair": {"a": "/class"}},
+			LogLevels: map[string]int{LogObserve: -1},
+		}},
+	}
+	c := startClient(t, configs[0])
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	worker := func(op func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				op()
+			}
+		}()
+	}
+	worker(func() { _, _ = c.GetProperty("motion") })
+	worker(func() { _, _ = c.ReadAll() })
+	worker(func() { _ = c.WriteBatch(map[string]interface{}{"class": "person"}) })
+	worker(func() { _, _ = c.WriteProperty("class", "car", false) })
+	worker(func() { _, _ = c.GetDeviceStates() })
+	worker(func() {
+		c.deviceMutex.Lock()
+		conn := c.conn
+		c.deviceMutex.Unlock()
+		if conn != nil {
+			_ = c.healthCheck(context.Background(), conn)
+		}
+	})
+
+	for i := 0; i < 20; i++ {
+		if err := c.UpdateConfig(configs[i%2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the last config, configs[1], observes /motion2
+	waitFor(t, "observe /motion2", func() bool {
+		_, ok := s.observer("/motion2")
+		return ok
+	})
+	close(done)
+	wg.Wait()
+}
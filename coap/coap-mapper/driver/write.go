@@ -0,0 +1,101 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"k8s.io/klog/v2"
+)
+
+// noResponseAll is the No-Response option value suppressing every response class (RFC 7967).
+const noResponseAll = 26
+
+// WriteResult is the device's acknowledgment of a write.
+type WriteResult struct {
+	// Acknowledged is false for No-Response writes, which get no reply at all.
+	Acknowledged bool
+	Code         codes.Code
+	Body         []byte
+}
+
+// WriteProperty sends data to the resource backing prop with the WriteMethod of
+// its visitor (PUT by default) and returns the response. An error is returned
+// when the device answers with anything but 2.01, 2.04 or 2.05; the result still
+// carries the code and body then. A device without support for the method
+// answers 4.05 Method Not Allowed, which is reported as such: link-format
+// discovery has no attribute announcing the methods a resource accepts. With
+// ResourceDiscovery, a resource advertising only read-only interfaces is not
+// written at all.
+//
+// With noResponse the request is sent as a non-confirmable message carrying the
+// No-Response option and the call returns as soon as it is written: there is no ACK
+// and no response, so delivery is not confirmed and failures on the device go unnoticed.
+func (c *CustomizedClient) WriteProperty(prop string, data interface{}, noResponse bool) (WriteResult, error) {
+	c.deviceMutex.Lock()
+	r, ok := c.resourceFor(prop)
+	if !ok {
+		c.deviceMutex.Unlock()
+		return WriteResult{}, c.propertyError(ErrUnknownProperty, prop, nil)
+	}
+	opts := c.requestOpts(prop)
+	body, cf, err := c.encodePayload(prop, data)
+	method, _ := writeMethod(c.registeredVisitor(prop).VisitorConfigData.WriteMethod)
+	conn := c.conn
+	info, discovered := c.links[linkPath(r.path)]
+	c.deviceMutex.Unlock()
+	if discovered && !info.Writable() {
+		return WriteResult{}, fmt.Errorf("property %s: %s advertises read-only interface %s", prop, r.path, strings.Join(info.Interfaces, " "))
+	}
+	if err != nil {
+		return WriteResult{}, c.propertyError(ErrConversion, prop, err)
+	}
+	cf = patchFormat(method, cf)
+	name := methodName(method)
+	if conn == nil {
+		return WriteResult{}, c.propertyError(ErrNotConnected, prop, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), getTimeout)
+	defer cancel()
+	if noResponse {
+		release, err := c.acquireRequest(ctx)
+		if err != nil {
+			return WriteResult{}, c.requestError(prop, err)
+		}
+		defer release()
+		req, err := conn.NewPutRequest(ctx, r.path, cf, bytes.NewReader(body), opts...)
+		if err != nil {
+			return WriteResult{}, fmt.Errorf("property %s: build %s %s: %v", prop, name, r.path, err)
+		}
+		defer conn.ReleaseMessage(req)
+		req.SetCode(method)
+		req.SetType(message.NonConfirmable)
+		req.SetOptionUint32(message.NoResponse, noResponseAll)
+		c.tap(TapRequest, method, r.path, len(body))
+		if err := conn.WriteMessage(req); err != nil {
+			return WriteResult{}, c.requestError(prop, fmt.Errorf("%s %s: %w", name, r.path, err))
+		}
+		klog.V(2).Infof("CoAP %s %s=%s sent without response", name, r.path, body)
+		return WriteResult{}, nil
+	}
+
+	resp, err := c.send(ctx, conn, method, r.path, cf, bytes.NewReader(body), opts...)
+	if err != nil {
+		return WriteResult{}, c.requestError(prop, fmt.Errorf("%s %s: %w", name, r.path, err))
+	}
+	res := WriteResult{Acknowledged: true, Code: resp.Code()}
+	res.Body, _ = resp.ReadBody()
+	switch res.Code {
+	case codes.Changed, codes.Created, codes.Content:
+	case codes.MethodNotAllowed:
+		return res, fmt.Errorf("property %s: device does not support %s on %s", prop, name, r.path)
+	default:
+		return res, fmt.Errorf("property %s: %s %s: device rejected write with %v: %s", prop, name, r.path, res.Code, res.Body)
+	}
+	klog.V(2).Infof("CoAP %s %s=%s acknowledged with %v", name, r.path, body, res.Code)
+	return res, nil
+}
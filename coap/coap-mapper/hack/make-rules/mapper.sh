@@ -112,6 +112,7 @@ function package() {
   echo "packaging ${image_tag}"
   sudo docker build \
     --platform "${platform}" \
+    --build-context mapper-common="${ROOT_DIR}/mapper-common" \
     -t "${image_tag}" .
   popd >/dev/null 2>&1
 
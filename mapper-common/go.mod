@@ -0,0 +1,21 @@
+module github.com/kubeedge/mapper-common
+
+go 1.22.9
+
+require (
+	github.com/go-logr/logr v1.4.2
+	github.com/kubeedge/api v1.20.0
+	github.com/kubeedge/mapper-framework v1.20.1-0.20250628103114-bd14c0473a82
+	github.com/spf13/pflag v1.0.6-0.20210604193023-d5e0c0615ace
+	k8s.io/klog/v2 v2.120.1
+)
+
+require (
+	github.com/golang/protobuf v1.5.4 // indirect
+	golang.org/x/net v0.30.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241007155032-5fefd90f89a9 // indirect
+	google.golang.org/grpc v1.67.1 // indirect
+	google.golang.org/protobuf v1.35.2 // indirect
+)
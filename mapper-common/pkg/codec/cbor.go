@@ -0,0 +1,291 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxCBORDepth bounds the nesting of arrays and maps Decode accepts.
+const maxCBORDepth = 32
+
+// CBOR reads and writes one CBOR data item (RFC 8949) of the JSON data model:
+// integers, floats, text and byte strings, booleans, null, arrays and maps.
+// Tags are skipped and indefinite lengths are not supported. Map keys that are
+// not text are turned into text. Scalars are converted to dataType like JSON does.
+type CBOR struct{}
+
+// Decode parses payload as a single CBOR data item.
+func (CBOR) Decode(payload []byte, dataType string) (interface{}, error) {
+	v, rest, err := decodeCBOR(payload, 0)
+	if err != nil {
+		return nil, fmt.Errorf("decode cbor: %v", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("decode cbor: %d bytes after the data item", len(rest))
+	}
+	return coerce(v, dataType)
+}
+
+// Encode writes v, converted to dataType if it is a scalar. Values outside the
+// JSON data model, such as structs, are encoded through their JSON form.
+func (CBOR) Encode(v interface{}, dataType string) ([]byte, error) {
+	v, err := coerce(v, dataType)
+	if err != nil {
+		return nil, err
+	}
+	return appendCBOR(nil, v)
+}
+
+// cborHead encodes a major type with its argument in the shortest form.
+func cborHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+func cborInt(b []byte, n int64) []byte {
+	if n < 0 {
+		return cborHead(b, 1, uint64(-1-n))
+	}
+	return cborHead(b, 0, uint64(n))
+}
+
+func appendCBOR(b []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(b, 0xf6), nil
+	case bool:
+		if t {
+			return append(b, 0xf5), nil
+		}
+		return append(b, 0xf4), nil
+	case int:
+		return cborInt(b, int64(t)), nil
+	case int8:
+		return cborInt(b, int64(t)), nil
+	case int16:
+		return cborInt(b, int64(t)), nil
+	case int32:
+		return cborInt(b, int64(t)), nil
+	case int64:
+		return cborInt(b, t), nil
+	case uint:
+		return cborHead(b, 0, uint64(t)), nil
+	case uint8:
+		return cborHead(b, 0, uint64(t)), nil
+	case uint16:
+		return cborHead(b, 0, uint64(t)), nil
+	case uint32:
+		return cborHead(b, 0, uint64(t)), nil
+	case uint64:
+		return cborHead(b, 0, t), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xfa), math.Float32bits(t)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xfb), math.Float64bits(t)), nil
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return cborInt(b, n), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("encode cbor: %v", err)
+		}
+		return appendCBOR(b, f)
+	case string:
+		return append(cborHead(b, 3, uint64(len(t))), t...), nil
+	case []byte:
+		return append(cborHead(b, 2, uint64(len(t))), t...), nil
+	case []interface{}:
+		b = cborHead(b, 4, uint64(len(t)))
+		for _, e := range t {
+			var err error
+			if b, err = appendCBOR(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = cborHead(b, 5, uint64(len(t)))
+		for _, k := range keys {
+			b = append(cborHead(b, 3, uint64(len(k))), k...)
+			var err error
+			if b, err = appendCBOR(b, t[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	// anything else goes through its JSON form, e.g. structs and typed maps
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode cbor: %v", err)
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("encode cbor: %v", err)
+	}
+	return appendCBOR(b, generic)
+}
+
+// decodeCBOR decodes the data item at the start of b and returns the rest.
+func decodeCBOR(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, fmt.Errorf("nested deeper than %d", maxCBORDepth)
+	}
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of data")
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+	if major == 7 {
+		return decodeSimple(info, b)
+	}
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(b) < size {
+			return nil, nil, fmt.Errorf("unexpected end of data")
+		}
+		for _, x := range b[:size] {
+			n = n<<8 | uint64(x)
+		}
+		b = b[size:]
+	case info == 31:
+		return nil, nil, fmt.Errorf("indefinite lengths are not supported")
+	default:
+		return nil, nil, fmt.Errorf("malformed initial byte")
+	}
+
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return n, b, nil
+		}
+		return int64(n), b, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("negative integer out of range")
+		}
+		return -1 - int64(n), b, nil
+	case 2, 3:
+		if n > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("unexpected end of data")
+		}
+		if major == 2 {
+			return append([]byte(nil), b[:n]...), b[n:], nil
+		}
+		return string(b[:n]), b[n:], nil
+	case 4:
+		// every element takes at least one byte
+		if n > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("unexpected end of data")
+		}
+		arr := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var e interface{}
+			var err error
+			if e, b, err = decodeCBOR(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			arr = append(arr, e)
+		}
+		return arr, b, nil
+	case 5:
+		if n > uint64(len(b))/2 {
+			return nil, nil, fmt.Errorf("unexpected end of data")
+		}
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			var k, e interface{}
+			var err error
+			if k, b, err = decodeCBOR(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			if e, b, err = decodeCBOR(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			m[key] = e
+		}
+		return m, b, nil
+	}
+	// major 6: a tag, decode the tagged item
+	return decodeCBOR(b, depth+1)
+}
+
+// decodeSimple decodes major type 7: false, true, null, undefined and floats.
+func decodeSimple(info byte, b []byte) (interface{}, []byte, error) {
+	switch info {
+	case 20:
+		return false, b, nil
+	case 21:
+		return true, b, nil
+	case 22, 23:
+		return nil, b, nil
+	case 25:
+		if len(b) < 2 {
+			return nil, nil, fmt.Errorf("unexpected end of data")
+		}
+		return halfToFloat(binary.BigEndian.Uint16(b)), b[2:], nil
+	case 26:
+		if len(b) < 4 {
+			return nil, nil, fmt.Errorf("unexpected end of data")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), b[4:], nil
+	case 27:
+		if len(b) < 8 {
+			return nil, nil, fmt.Errorf("unexpected end of data")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	}
+	return nil, nil, fmt.Errorf("unsupported simple value %d", info)
+}
+
+// halfToFloat converts an IEEE 754 half-precision float (RFC 8949 Appendix D).
+func halfToFloat(h uint16) float64 {
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,108 @@
+// Package codec converts property values to and from device payloads. The
+// drivers pick a codec per property by name; json, text and cbor are built in
+// and Register adds others.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// Codec decodes a payload into a value and encodes a value into a payload.
+// dataType is the twin data type of the property (int, float, double, boolean,
+// string) or empty; a codec uses it to produce or accept values of that type.
+type Codec interface {
+	Decode(payload []byte, dataType string) (interface{}, error)
+	Encode(v interface{}, dataType string) ([]byte, error)
+}
+
+var (
+	codecsMutex sync.RWMutex
+	codecs      = map[string]Codec{
+		"text": Text{},
+		"json": JSON{},
+		"cbor": CBOR{},
+	}
+)
+
+// Register makes a codec available under name. It fails if the name is taken.
+func Register(name string, c Codec) error {
+	if name == "" || c == nil {
+		return fmt.Errorf("codec needs a name and an implementation")
+	}
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	if _, ok := codecs[name]; ok {
+		return fmt.Errorf("codec %q is already registered", name)
+	}
+	codecs[name] = c
+	return nil
+}
+
+// Lookup returns the codec registered under name.
+func Lookup(name string) (Codec, error) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+	return c, nil
+}
+
+// coerce converts a scalar to dataType. Objects and arrays, and any value when
+// dataType is empty, are returned unchanged.
+func coerce(v interface{}, dataType string) (interface{}, error) {
+	if dataType == "" {
+		return v, nil
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return v, nil
+	}
+	return value.ToType(strings.ToLower(dataType), value.Stringify(v))
+}
+
+// Text reads the payload as trimmed text and writes values as Stringify does.
+type Text struct{}
+
+// Decode returns the trimmed payload, converted to dataType if set.
+func (Text) Decode(payload []byte, dataType string) (interface{}, error) {
+	return coerce(strings.TrimSpace(string(payload)), dataType)
+}
+
+// Encode returns v as text.
+func (Text) Encode(v interface{}, dataType string) ([]byte, error) {
+	return []byte(value.Stringify(v)), nil
+}
+
+// JSON reads and writes a JSON document. Scalars are converted to dataType, so
+// "25" is written as 25 for an int property.
+type JSON struct{}
+
+// Decode parses payload as one JSON document.
+func (JSON) Decode(payload []byte, dataType string) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decode json: %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("decode json: data after the document")
+	}
+	return coerce(v, dataType)
+}
+
+// Encode marshals v, converted to dataType if it is a scalar.
+func (JSON) Encode(v interface{}, dataType string) ([]byte, error) {
+	v, err := coerce(v, dataType)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,180 @@
+// Package events queues the changes of device properties for subscribers and
+// keeps the most recent ones per property.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Sample is one recorded value of a property.
+type Sample struct {
+	Value interface{}
+	Time  time.Time
+}
+
+// Policies for a subscriber that falls behind, see Broker.Publish.
+const (
+	// DropOldest discards the oldest queued sample to make room (default).
+	DropOldest = "dropOldest"
+	// Block makes the publisher wait until the subscriber has room again.
+	Block = "block"
+)
+
+// DefaultBuffer is the number of samples queued per subscriber by default.
+const DefaultBuffer = 16
+
+// Validate checks the eventBuffer and eventOverflow settings of a mapper.
+func Validate(buffer int, overflow string) error {
+	if buffer < 0 {
+		return fmt.Errorf("invalid eventBuffer %d", buffer)
+	}
+	switch overflow {
+	case "", DropOldest, Block:
+		return nil
+	}
+	return fmt.Errorf("invalid eventOverflow %q, must be %s or %s", overflow, DropOldest, Block)
+}
+
+// Broker hands the changes of properties to their subscribers. The zero value
+// has no subscribers and is ready to use.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string][]*subscription
+}
+
+// Subscribe returns a channel receiving every sample published for prop, in
+// order. The channel is closed by Unsubscribe and by Close.
+func (b *Broker) Subscribe(prop string) <-chan Sample {
+	s := &subscription{
+		prop:  prop,
+		ch:    make(chan Sample),
+		done:  make(chan struct{}),
+		wake:  make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[string][]*subscription)
+	}
+	b.subscribers[prop] = append(b.subscribers[prop], s)
+	b.mu.Unlock()
+	go s.run()
+	return s.ch
+}
+
+// Unsubscribe stops the delivery to a channel returned by Subscribe and closes it.
+func (b *Broker) Unsubscribe(ch <-chan Sample) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for prop, subs := range b.subscribers {
+		for i, s := range subs {
+			if s.ch == ch {
+				close(s.done)
+				b.subscribers[prop] = append(subs[:i:i], subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Close closes every subscription.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subs := range b.subscribers {
+		for _, s := range subs {
+			close(s.done)
+		}
+	}
+	b.subscribers = nil
+}
+
+// Publish hands v, a changed value of prop, to its subscribers. Up to buffer
+// samples, or DefaultBuffer when 0, are queued for a subscriber that falls
+// behind; beyond that overflow decides whether the oldest are dropped or
+// Publish waits, so with Block it must not be called under a lock the
+// subscribers need.
+func (b *Broker) Publish(prop string, v interface{}, buffer int, overflow string) {
+	b.mu.Lock()
+	subs := append([]*subscription(nil), b.subscribers[prop]...)
+	b.mu.Unlock()
+	if len(subs) == 0 {
+		return
+	}
+	if buffer == 0 {
+		buffer = DefaultBuffer
+	}
+	block := overflow == Block
+	sample := Sample{Value: v, Time: time.Now()}
+	for _, s := range subs {
+		s.push(sample, buffer, block)
+	}
+}
+
+// subscription queues the changes of one property for one subscriber and feeds
+// them to its channel from its own goroutine.
+type subscription struct {
+	prop  string
+	ch    chan Sample
+	done  chan struct{}
+	wake  chan struct{} // a sample was queued
+	space chan struct{} // a sample was taken, for Block
+	mu    sync.Mutex
+	queue []Sample
+}
+
+func (s *subscription) run() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		next := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		select {
+		case s.space <- struct{}{}:
+		default:
+		}
+		select {
+		case s.ch <- next:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// push queues sample, applying the overflow policy once limit samples are queued.
+func (s *subscription) push(sample Sample, limit int, block bool) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) < limit {
+			break
+		}
+		if !block {
+			s.queue = s.queue[1:]
+			break
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.space:
+		case <-s.done:
+			return
+		}
+	}
+	s.queue = append(s.queue, sample)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
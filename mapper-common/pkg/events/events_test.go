@@ -0,0 +1,86 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func values(samples []Sample) string {
+	var vs []interface{}
+	for _, s := range samples {
+		vs = append(vs, s.Value)
+	}
+	return fmt.Sprint(vs)
+}
+
+func TestHistory(t *testing.T) {
+	var h History
+	h.Record(0, "class", "ignored")
+	if got := h.Last("class", 0); got != nil {
+		t.Fatalf("history with size 0: %v, want nil", got)
+	}
+	for _, v := range []string{"person", "car", "dog", "cat"} {
+		h.Record(3, "class", v)
+	}
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "[car dog cat]"},
+		{2, "[dog cat]"},
+		{5, "[car dog cat]"},
+	}
+	for _, tt := range tests {
+		if got := values(h.Last("class", tt.n)); got != tt.want {
+			t.Errorf("Last(class, %d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+// TestBroker checks that a subscriber receives the samples of its property in
+// order and that Unsubscribe closes its channel.
+func TestBroker(t *testing.T) {
+	var b Broker
+	ch := b.Subscribe("class")
+	for _, v := range []string{"person", "car", "dog"} {
+		b.Publish("class", v, 0, Block)
+		b.Publish("motion", true, 0, Block)
+	}
+	var got []Sample
+	timeout := time.After(5 * time.Second)
+	for len(got) < 3 {
+		select {
+		case s := <-ch:
+			got = append(got, s)
+		case <-timeout:
+			t.Fatalf("received %s within 5s, want 3 samples", values(got))
+		}
+	}
+	if vs := values(got); vs != "[person car dog]" {
+		t.Fatalf("received %s, want [person car dog]", vs)
+	}
+	b.Unsubscribe(ch)
+	if _, ok := <-ch; ok {
+		t.Fatal("channel open after Unsubscribe")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		buffer   int
+		overflow string
+		wantErr  bool
+	}{
+		{0, "", false},
+		{8, DropOldest, false},
+		{8, Block, false},
+		{-1, "", true},
+		{8, "drop", true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.buffer, tt.overflow); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%d, %q) = %v, want error %v", tt.buffer, tt.overflow, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package events
+
+import "time"
+
+// History keeps the most recent samples of each property. The zero value is
+// ready to use. It is not safe for concurrent use.
+type History struct {
+	rings map[string]*ring
+}
+
+// Record appends v as a sample of prop, keeping the last size samples of each
+// property. A size of 0 or less records nothing.
+func (h *History) Record(size int, prop string, v interface{}) {
+	if size <= 0 {
+		return
+	}
+	if h.rings == nil {
+		h.rings = make(map[string]*ring)
+	}
+	r, ok := h.rings[prop]
+	if !ok {
+		r = &ring{samples: make([]Sample, size)}
+		h.rings[prop] = r
+	}
+	r.add(Sample{Value: v, Time: time.Now()})
+}
+
+// Last returns the last n samples of prop, oldest first, or all recorded ones
+// when n <= 0. It returns nil when nothing was recorded for prop.
+func (h *History) Last(prop string, n int) []Sample {
+	r, ok := h.rings[prop]
+	if !ok {
+		return nil
+	}
+	return r.last(n)
+}
+
+// ring is a fixed-size ring of the most recent samples of a property.
+type ring struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func (r *ring) add(s Sample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// last returns up to n samples, oldest first.
+func (r *ring) last(n int) []Sample {
+	count := r.next
+	if r.full {
+		count = len(r.samples)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	out := make([]Sample, 0, n)
+	for i := count - n; i < count; i++ {
+		out = append(out, r.samples[(r.next-count+i+len(r.samples))%len(r.samples)])
+	}
+	return out
+}
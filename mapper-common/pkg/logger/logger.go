@@ -0,0 +1,40 @@
+// Package logger sets up the klog output of a mapper.
+package logger
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"k8s.io/klog/v2"
+)
+
+// Supported values of the --log-format flag.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup routes klog output according to format. FormatText keeps klog's default
+// output. FormatJSON writes one JSON object per line to stderr, so the key/value
+// pairs passed to klog.InfoS/ErrorS become fields a log pipeline can parse.
+func Setup(format string) error {
+	switch format {
+	case "", FormatText:
+		return nil
+	case FormatJSON:
+		klog.SetLogger(funcr.NewJSON(func(obj string) {
+			fmt.Fprintln(os.Stderr, obj)
+		}, funcr.Options{
+			LogTimestamp:    true,
+			TimestampFormat: time.RFC3339Nano,
+			// verbosity filtering is left to klog's -v flag
+			Verbosity: math.MaxInt32,
+		}))
+		return nil
+	default:
+		return fmt.Errorf("unsupported log format %q, must be %q or %q", format, FormatText, FormatJSON)
+	}
+}
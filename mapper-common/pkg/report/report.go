@@ -0,0 +1,211 @@
+// Package report sends the twin reports of a mapper and limits their rate
+// across all of its devices.
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
+)
+
+// Reporter sends twin reports. Mappers report to EdgeCore through grpcclient
+// unless a device is given another Reporter, e.g. a sidecar aggregator or a
+// fake in tests.
+type Reporter interface {
+	ReportDeviceStatus(*dmiapi.ReportDeviceStatusRequest) error
+}
+
+// ReporterFunc adapts a function to Reporter.
+type ReporterFunc func(*dmiapi.ReportDeviceStatusRequest) error
+
+// ReportDeviceStatus calls f.
+func (f ReporterFunc) ReportDeviceStatus(req *dmiapi.ReportDeviceStatusRequest) error {
+	return f(req)
+}
+
+// queuedReport is a throttled report waiting for a token.
+type queuedReport struct {
+	key string
+	req *dmiapi.ReportDeviceStatusRequest
+	to  Reporter
+}
+
+// limiter is a token bucket shared by every device of the mapper that throttles
+// ReportDeviceStatus calls, whatever their Reporter. Reports that cannot be sent right away are
+// coalesced per device property, so only the latest value is sent once a token frees up,
+// unless they are appended.
+type limiter struct {
+	mu       sync.Mutex
+	rate     float64 // tokens per second, <= 0 disables limiting
+	burst    float64
+	tokens   float64
+	last     time.Time
+	pending  map[string]queuedReport
+	order    []string
+	flushing bool
+	// appended numbers the appended reports, which are queued under their own keys
+	appended uint64
+}
+
+// twins limits the reports sent with Submit, see SetRateLimit.
+var twins = newLimiter(0, 1)
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+		pending: make(map[string]queuedReport),
+	}
+}
+
+// SetRateLimit limits twin reports of all devices to rate per second with the
+// given burst of at least 1. A rate of 0 disables limiting. It must be called
+// before devices start.
+func SetRateLimit(rate float64, burst int) error {
+	if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return fmt.Errorf("invalid report rate %v, must be 0 or more", rate)
+	}
+	if burst < 1 {
+		return fmt.Errorf("invalid report burst %d, must be 1 or more", burst)
+	}
+	twins = newLimiter(rate, burst)
+	if rate > 0 {
+		klog.Infof("Twin reports limited to %v/s, burst %d", rate, burst)
+	}
+	return nil
+}
+
+// Flags are the command line flags of the report rate limit.
+type Flags struct {
+	QPS   float64
+	Burst int
+}
+
+// AddFlags registers --report-qps and --report-burst on fs.
+func (f *Flags) AddFlags(fs *pflag.FlagSet) {
+	fs.Float64Var(&f.QPS, "report-qps", 0, "max twin reports per second sent to edgecore across all devices, 0 for unlimited")
+	fs.IntVar(&f.Burst, "report-burst", 1, "burst allowed above report-qps, at least 1")
+}
+
+// Apply sets the rate limit of the flags, see SetRateLimit.
+func (f *Flags) Apply() error {
+	return SetRateLimit(f.QPS, f.Burst)
+}
+
+// Submit sends req to r, or queues it when the rate limit is reached, see
+// SetRateLimit. A queued report replaces the one queued before under the same
+// key, e.g. namespace/device/property, so only the latest value is sent. queued
+// tells the two apart: the error of a queued report is only logged.
+func Submit(key string, req *dmiapi.ReportDeviceStatusRequest, r Reporter) (queued bool, err error) {
+	return twins.submit(key, req, r, true)
+}
+
+// Append is Submit without coalescing: a queued report is sent after the ones
+// queued before it, including those under the same key, e.g. for every value a
+// property took between two reports.
+func Append(key string, req *dmiapi.ReportDeviceStatusRequest, r Reporter) (queued bool, err error) {
+	return twins.submit(key, req, r, false)
+}
+
+// Target returns the device name and namespace a report of property is filed
+// under. nameTemplate may use {deviceName}, {namespace} and {property}, e.g.
+// "{deviceName}-{property}" to report each property into its own device CR.
+// Empty values report under device and namespace.
+func Target(device, namespace, property, nameTemplate, namespaceOverride string) (name, ns string) {
+	name, ns = device, namespace
+	if namespaceOverride != "" {
+		ns = namespaceOverride
+	}
+	if nameTemplate != "" {
+		name = strings.NewReplacer(
+			"{deviceName}", device,
+			"{namespace}", namespace,
+			"{property}", property,
+		).Replace(nameTemplate)
+	}
+	return name, ns
+}
+
+// refill adds the tokens accumulated since the last call. Caller must hold mu.
+func (l *limiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// submit sends req to r now if a token is available and nothing is queued, otherwise
+// it queues req for the flusher to send later, with coalesce replacing any report
+// queued for key. queued tells the two apart: the error of a queued report is only
+// logged by the flusher.
+func (l *limiter) submit(key string, req *dmiapi.ReportDeviceStatusRequest, r Reporter, coalesce bool) (queued bool, err error) {
+	if l.rate <= 0 {
+		return false, r.ReportDeviceStatus(req)
+	}
+	l.mu.Lock()
+	l.refill(time.Now())
+	if len(l.order) == 0 && l.tokens >= 1 {
+		l.tokens--
+		l.mu.Unlock()
+		return false, r.ReportDeviceStatus(req)
+	}
+	id := key
+	if !coalesce {
+		l.appended++
+		id = fmt.Sprintf("%s#%d", key, l.appended)
+	}
+	if _, ok := l.pending[id]; !ok {
+		l.order = append(l.order, id)
+	} else {
+		klog.V(3).Infof("Twin report for %s throttled, replacing queued value", key)
+	}
+	l.pending[id] = queuedReport{key: key, req: req, to: r}
+	if !l.flushing {
+		l.flushing = true
+		go l.flush()
+	}
+	l.mu.Unlock()
+	return true, nil
+}
+
+// flush sends queued reports, oldest first, as tokens become available.
+func (l *limiter) flush() {
+	for {
+		l.mu.Lock()
+		if len(l.order) == 0 {
+			l.flushing = false
+			l.mu.Unlock()
+			return
+		}
+		l.refill(time.Now())
+		if l.tokens < 1 {
+			wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+			l.mu.Unlock()
+			time.Sleep(wait)
+			continue
+		}
+		l.tokens--
+		id := l.order[0]
+		l.order = l.order[1:]
+		queued := l.pending[id]
+		delete(l.pending, id)
+		l.mu.Unlock()
+
+		req := queued.req
+		if err := queued.to.ReportDeviceStatus(req); err != nil {
+			klog.ErrorS(err, "Failed to report throttled twin", "device", req.DeviceName, "namespace", req.DeviceNamespace, "key", queued.key)
+		} else {
+			klog.V(2).InfoS("Reported throttled twin", "device", req.DeviceName, "namespace", req.DeviceNamespace, "key", queued.key)
+		}
+	}
+}
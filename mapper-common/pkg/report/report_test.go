@@ -0,0 +1,100 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kubeedge/mapper-common/pkg/report/reporttest"
+)
+
+// TestLimiter checks that reports beyond the burst are queued, that a queued
+// report is replaced by a newer one of the same key and that the queue is
+// flushed oldest key first.
+func TestLimiter(t *testing.T) {
+	r := &reporttest.Recorder{}
+	l := newLimiter(5, 1)
+	submit := func(prop, v string, wantQueued bool) {
+		t.Helper()
+		queued, err := l.submit("default/camera/"+prop, reporttest.Twin("camera", prop, v), r, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if queued != wantQueued {
+			t.Fatalf("report %s=%s queued %v, want %v", prop, v, queued, wantQueued)
+		}
+	}
+	submit("class", "person", false)
+	submit("class", "car", true)
+	submit("motion", "true", true)
+	submit("class", "dog", true)
+	if got := fmt.Sprint(r.Twins()); got != "[class=person]" {
+		t.Fatalf("sent %s before the flush, want [class=person]", got)
+	}
+
+	want := "[class=person class=dog motion=true]"
+	if got := fmt.Sprint(r.WaitTwins(t, 3)); got != want {
+		t.Fatalf("sent %s, want %s", got, want)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := fmt.Sprint(r.Twins()); got != want {
+		t.Fatalf("sent %s after the flush, want %s", got, want)
+	}
+}
+
+// TestLimiterAppend checks that appended reports are all sent, in order, and
+// that a coalesced report of the same key queued after them is sent last.
+func TestLimiterAppend(t *testing.T) {
+	r := &reporttest.Recorder{}
+	l := newLimiter(20, 1)
+	for _, v := range []string{"person", "car", "dog"} {
+		if _, err := l.submit("default/camera/class", reporttest.Twin("camera", "class", v), r, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range []string{"cat", "bird"} {
+		if _, err := l.submit("default/camera/class", reporttest.Twin("camera", "class", v), r, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "[class=person class=car class=dog class=bird]"
+	if got := fmt.Sprint(r.WaitTwins(t, 4)); got != want {
+		t.Fatalf("sent %s, want %s", got, want)
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	r := &reporttest.Recorder{}
+	l := newLimiter(0, 1)
+	for _, v := range []string{"person", "car", "dog"} {
+		if queued, err := l.submit("default/camera/class", reporttest.Twin("camera", "class", v), r, true); err != nil || queued {
+			t.Fatalf("unlimited report queued %v, %v", queued, err)
+		}
+	}
+	if got := fmt.Sprint(r.Twins()); got != "[class=person class=car class=dog]" {
+		t.Fatalf("sent %s without a limit", got)
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	defer func() { _ = SetRateLimit(0, 1) }()
+	tests := []struct {
+		rate    float64
+		burst   int
+		wantErr bool
+	}{
+		{0, 1, false},
+		{2.5, 10, false},
+		{-1, 1, true},
+		{math.NaN(), 1, true},
+		{math.Inf(1), 1, true},
+		{1, 0, true},
+		{0, -1, true},
+	}
+	for _, tt := range tests {
+		if err := SetRateLimit(tt.rate, tt.burst); (err != nil) != tt.wantErr {
+			t.Errorf("SetRateLimit(%v, %d) = %v, want error %v", tt.rate, tt.burst, err, tt.wantErr)
+		}
+	}
+}
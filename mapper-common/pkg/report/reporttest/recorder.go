@@ -0,0 +1,71 @@
+// Package reporttest provides a report.Reporter for the tests of the mappers.
+package reporttest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
+)
+
+// Recorder is a report.Reporter keeping the requests it receives.
+type Recorder struct {
+	mu   sync.Mutex
+	reqs []*dmiapi.ReportDeviceStatusRequest
+}
+
+// ReportDeviceStatus records req.
+func (r *Recorder) ReportDeviceStatus(req *dmiapi.ReportDeviceStatusRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reqs = append(r.reqs, req)
+	return nil
+}
+
+// Requests returns the requests received, in order.
+func (r *Recorder) Requests() []*dmiapi.ReportDeviceStatusRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*dmiapi.ReportDeviceStatusRequest(nil), r.reqs...)
+}
+
+// Twins returns the twins of the requests received as property=value, in order.
+func (r *Recorder) Twins() []string {
+	var out []string
+	for _, req := range r.Requests() {
+		for _, twin := range req.GetReportedDevice().GetTwins() {
+			out = append(out, twin.GetPropertyName()+"="+twin.GetReported().GetValue())
+		}
+	}
+	return out
+}
+
+// WaitTwins waits until Twins returns n twins and returns them, failing the
+// test after five seconds.
+func (r *Recorder) WaitTwins(t testing.TB, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		twins := r.Twins()
+		if len(twins) >= n {
+			return twins
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reported %v, want %d twins", twins, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Twin returns a request reporting value as property of the device.
+func Twin(device, property, value string) *dmiapi.ReportDeviceStatusRequest {
+	return &dmiapi.ReportDeviceStatusRequest{
+		DeviceName:      device,
+		DeviceNamespace: "default",
+		ReportedDevice: &dmiapi.DeviceStatus{Twins: []*dmiapi.Twin{{
+			PropertyName: property,
+			Reported:     &dmiapi.TwinProperty{Value: value},
+		}}},
+	}
+}
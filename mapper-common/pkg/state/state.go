@@ -0,0 +1,41 @@
+// Package state translates the device states of a mapper into the states the
+// cloud expects.
+package state
+
+import (
+	"fmt"
+
+	"github.com/kubeedge/mapper-framework/pkg/common"
+)
+
+// deviceStates are the states a driver reports, the keys of a state mapping.
+// unknown stands for a state that could not be determined.
+var deviceStates = map[string]bool{
+	common.DeviceStatusOK:        true,
+	common.DeviceStatusDisCONN:   true,
+	common.DeviceStatusUnhealthy: true,
+	common.DeviceStatusUnknown:   true,
+}
+
+// ValidateMapping rejects mapping entries for unknown states and empty DMI states.
+func ValidateMapping(mapping map[string]string) error {
+	for state, dmi := range mapping {
+		if !deviceStates[state] {
+			return fmt.Errorf("stateMapping: unknown state %q, must be %s, %s, %s or %s", state,
+				common.DeviceStatusOK, common.DeviceStatusDisCONN, common.DeviceStatusUnhealthy, common.DeviceStatusUnknown)
+		}
+		if dmi == "" {
+			return fmt.Errorf("stateMapping: empty state for %q", state)
+		}
+	}
+	return nil
+}
+
+// Translate returns the DMI state mapping gives for state. States without a
+// mapping are returned as they are.
+func Translate(mapping map[string]string, state string) string {
+	if dmi, ok := mapping[state]; ok {
+		return dmi
+	}
+	return state
+}
@@ -0,0 +1,60 @@
+package value
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// binarySizes maps the supported BinaryType names to their size in bytes.
+var binarySizes = map[string]int{
+	"uint8": 1, "int8": 1,
+	"uint16": 2, "int16": 2,
+	"uint32": 4, "int32": 4,
+	"uint64": 8, "int64": 8,
+}
+
+// ValidateBinary checks a BinaryType and Endianness pair. An empty endianness
+// means big endian, the network byte order.
+func ValidateBinary(typ, endianness string) error {
+	if _, ok := binarySizes[typ]; !ok {
+		return fmt.Errorf("unsupported binaryType %q", typ)
+	}
+	switch endianness {
+	case "", "big", "little":
+		return nil
+	}
+	return fmt.Errorf("invalid endianness %q, must be big or little", endianness)
+}
+
+// DecodeBinary reads b as a single integer of type typ (uint8 to int64) in the
+// given byte order. The payload must be exactly as long as the type.
+func DecodeBinary(typ, endianness string, b []byte) (interface{}, error) {
+	if err := ValidateBinary(typ, endianness); err != nil {
+		return nil, err
+	}
+	if size := binarySizes[typ]; len(b) != size {
+		return nil, fmt.Errorf("%s payload must be %d bytes, got %d", typ, size, len(b))
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if endianness == "little" {
+		order = binary.LittleEndian
+	}
+	switch typ {
+	case "uint8":
+		return b[0], nil
+	case "int8":
+		return int8(b[0]), nil
+	case "uint16":
+		return order.Uint16(b), nil
+	case "int16":
+		return int16(order.Uint16(b)), nil
+	case "uint32":
+		return order.Uint32(b), nil
+	case "int32":
+		return int32(order.Uint32(b)), nil
+	case "uint64":
+		return order.Uint64(b), nil
+	default:
+		return int64(order.Uint64(b)), nil
+	}
+}
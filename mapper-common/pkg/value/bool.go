@@ -0,0 +1,81 @@
+// Package value holds the payload conversions shared by the CoAP and MQTT
+// drivers.
+package value
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BoolTokens lists the payloads read as true and as false. Tokens are matched
+// case-insensitively after trimming whitespace.
+type BoolTokens struct {
+	True  []string `json:"true"`
+	False []string `json:"false"`
+}
+
+// DefaultBoolTokens covers the usual sensor vocabularies.
+var DefaultBoolTokens = BoolTokens{
+	True:  []string{"true", "1", "on", "yes", "y", "open", "motion", "motion_detected"},
+	False: []string{"false", "0", "off", "no", "n", "closed", "no_motion"},
+}
+
+// Validate rejects empty token lists and tokens listed as both true and false.
+func (t BoolTokens) Validate() error {
+	if len(t.True) == 0 || len(t.False) == 0 {
+		return fmt.Errorf("boolTokens needs at least one true and one false token")
+	}
+	for _, f := range t.False {
+		for _, tr := range t.True {
+			if strings.EqualFold(strings.TrimSpace(f), strings.TrimSpace(tr)) {
+				return fmt.Errorf("boolTokens: %q is both true and false", f)
+			}
+		}
+	}
+	return nil
+}
+
+// Parse reads raw as a boolean. Payloads matching no token fall back to
+// strconv.ParseBool; ok is false when that fails too.
+func (t BoolTokens) Parse(raw string) (b, ok bool) {
+	s := strings.TrimSpace(raw)
+	for _, tok := range t.True {
+		if strings.EqualFold(s, strings.TrimSpace(tok)) {
+			return true, true
+		}
+	}
+	for _, tok := range t.False {
+		if strings.EqualFold(s, strings.TrimSpace(tok)) {
+			return false, true
+		}
+	}
+	b, err := strconv.ParseBool(strings.ToLower(s))
+	if err != nil {
+		return false, false
+	}
+	return b, true
+}
+
+// ParseBool is Parse with DefaultBoolTokens.
+func ParseBool(raw string) (b, ok bool) {
+	return DefaultBoolTokens.Parse(raw)
+}
+
+// BoolTokensOrDefault returns the configured tokens t, validated, or
+// DefaultBoolTokens when t is nil.
+func BoolTokensOrDefault(t *BoolTokens) (BoolTokens, error) {
+	if t == nil {
+		return DefaultBoolTokens, nil
+	}
+	if err := t.Validate(); err != nil {
+		return BoolTokens{}, err
+	}
+	return *t, nil
+}
+
+// IsTrue reads raw like Parse; unrecognized payloads are false.
+func (t BoolTokens) IsTrue(raw string) bool {
+	b, _ := t.Parse(raw)
+	return b
+}
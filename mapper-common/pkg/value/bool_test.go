@@ -0,0 +1,88 @@
+package value
+
+import "testing"
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   bool
+		wantOK bool
+	}{
+		// the payloads the MQTT driver compared with "true"
+		{"true", true, true},
+		{" true\n", true, true},
+		{"false", false, true},
+		// the CoAP motion vocabulary
+		{"1", true, true},
+		{"on", true, true},
+		{"YES", true, true},
+		{"y", true, true},
+		{"open", true, true},
+		{"motion", true, true},
+		{"Motion_Detected", true, true},
+		{"0", false, true},
+		{"off", false, true},
+		{"no", false, true},
+		{"n", false, true},
+		{"closed", false, true},
+		{"no_motion", false, true},
+		// strconv.ParseBool fallback
+		{"t", true, true},
+		{"F", false, true},
+		// neither
+		{"", false, false},
+		{"2", false, false},
+		{"1.0", false, false},
+		{"person", false, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseBool(tt.raw)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseBool(%q) = %v, %v; want %v, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBoolTokens(t *testing.T) {
+	tokens := BoolTokens{True: []string{"detected", " HIGH "}, False: []string{"clear", "low"}}
+	if err := tokens.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		raw    string
+		want   bool
+		wantOK bool
+	}{
+		{"detected", true, true},
+		{"high", true, true},
+		{" Clear ", false, true},
+		{"low", false, true},
+		{"true", true, true},
+		{"motion", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		got, ok := tokens.Parse(tt.raw)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Parse(%q) = %v, %v; want %v, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBoolTokensValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  BoolTokens
+		wantErr bool
+	}{
+		{"default", DefaultBoolTokens, false},
+		{"no true", BoolTokens{False: []string{"off"}}, true},
+		{"no false", BoolTokens{True: []string{"on"}}, true},
+		{"both", BoolTokens{True: []string{"on"}, False: []string{" ON"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.tokens.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
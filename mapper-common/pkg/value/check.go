@@ -0,0 +1,48 @@
+package value
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Check validates v, a value read from a property, against its model: it must
+// convert to dataType (see ToType) and, for int, float and double, lie within
+// minimum and maximum when they are set. An empty dataType accepts any value.
+func Check(v interface{}, dataType, minimum, maximum string) error {
+	if dataType == "" {
+		return nil
+	}
+	s := Stringify(v)
+	converted, err := ToType(dataType, s)
+	if err != nil {
+		return fmt.Errorf("value %q is not a %s", s, dataType)
+	}
+	var f float64
+	switch n := converted.(type) {
+	case int64:
+		f = float64(n)
+	case float64:
+		f = n
+	default:
+		return nil
+	}
+	if minimum != "" {
+		lo, err := strconv.ParseFloat(minimum, 64)
+		if err != nil {
+			return fmt.Errorf("invalid minimum %q", minimum)
+		}
+		if f < lo {
+			return fmt.Errorf("value %s is below the minimum %s", s, minimum)
+		}
+	}
+	if maximum != "" {
+		hi, err := strconv.ParseFloat(maximum, 64)
+		if err != nil {
+			return fmt.Errorf("invalid maximum %q", maximum)
+		}
+		if f > hi {
+			return fmt.Errorf("value %s is above the maximum %s", s, maximum)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Stringify renders a value the way it is reported and published: strings and
+// bytes verbatim, numbers in plain decimal notation, nil as "", everything else
+// as JSON (falling back to fmt formatting for unencodable values).
+func Stringify(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	case int:
+		return strconv.Itoa(t)
+	case int8, int16, int32, int64:
+		return fmt.Sprintf("%d", t)
+	case uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", t)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// ToType converts s to the twin data type named by dataType: int, float, double,
+// boolean or string. Booleans accept DefaultBoolTokens.
+func ToType(dataType, s string) (interface{}, error) {
+	switch dataType {
+	case "int":
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		return strconv.ParseFloat(s, 32)
+	case "double":
+		return strconv.ParseFloat(s, 64)
+	case "boolean":
+		b, ok := ParseBool(s)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %q to boolean", s)
+		}
+		return b, nil
+	case "string":
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported data type %q", dataType)
+}
@@ -0,0 +1,77 @@
+package value
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToType(t *testing.T) {
+	tests := []struct {
+		dataType, in string
+		want         interface{}
+		wantErr      bool
+	}{
+		{"int", "42", int64(42), false},
+		{"int", "-7", int64(-7), false},
+		{"int", "4.2", nil, true},
+		{"int", "", nil, true},
+		{"float", "0.5", float64(float32(0.5)), false},
+		{"float", "1e3", float64(1000), false},
+		{"float", "x", nil, true},
+		{"double", "3.25", 3.25, false},
+		{"double", "", nil, true},
+		{"boolean", "true", true, false},
+		{"boolean", "on", true, false},
+		{"boolean", "no_motion", false, false},
+		{"boolean", "0", false, false},
+		{"boolean", "", nil, true},
+		{"boolean", "maybe", nil, true},
+		{"string", "", "", false},
+		{"string", " person ", " person ", false},
+		{"bytes", "x", nil, true},
+		{"", "x", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ToType(tt.dataType, tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ToType(%q, %q) = %v, want an error", tt.dataType, tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ToType(%q, %q) = %v (%T), %v; want %v (%T)", tt.dataType, tt.in, got, got, err, tt.want, tt.want)
+		}
+	}
+}
+
+func TestStringify(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, ""},
+		{"", ""},
+		{"person", "person"},
+		{[]byte("car"), "car"},
+		{0.1, "0.1"},
+		{1e21, "1000000000000000000000"},
+		{float64(-2), "-2"},
+		{float32(0.1), "0.1"},
+		{42, "42"},
+		{int8(-8), "-8"},
+		{int64(math.MaxInt64), "9223372036854775807"},
+		{uint8(255), "255"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{true, "true"},
+		{false, "false"},
+		{map[string]interface{}{"class": "dog", "score": 0.9}, `{"class":"dog","score":0.9}`},
+		{[]interface{}{1, "a"}, `[1,"a"]`},
+		{math.Inf(1), "+Inf"},
+	}
+	for _, tt := range tests {
+		if got := Stringify(tt.in); got != tt.want {
+			t.Errorf("Stringify(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package value
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Infer reads s as the most specific twin data type it parses as cleanly, trying
+// boolean, int, double and string in that order, and returns the converted value
+// with the name of that type. Only "true" and "false" count as booleans, so "1"
+// is an int rather than a DefaultBoolTokens match; NaN and infinities stay strings.
+func Infer(s string) (interface{}, string) {
+	t := strings.TrimSpace(s)
+	switch {
+	case strings.EqualFold(t, "true"):
+		return true, "boolean"
+	case strings.EqualFold(t, "false"):
+		return false, "boolean"
+	}
+	if i, err := strconv.ParseInt(t, 10, 64); err == nil {
+		return i, "int"
+	}
+	if f, err := strconv.ParseFloat(t, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f, "double"
+	}
+	return s, "string"
+}
+
+// InferredTypes remembers the type Infer found first for each property. The
+// zero value is ready to use. It is not safe for concurrent use.
+type InferredTypes struct {
+	types map[string]string
+}
+
+// Infer is Infer for a value of prop. first is true the first time a type is
+// inferred for prop, e.g. to log it once.
+func (t *InferredTypes) Infer(prop, s string) (v interface{}, typ string, first bool) {
+	v, typ = Infer(s)
+	if _, seen := t.types[prop]; !seen {
+		if t.types == nil {
+			t.types = make(map[string]string)
+		}
+		t.types[prop] = typ
+		first = true
+	}
+	return v, typ, first
+}
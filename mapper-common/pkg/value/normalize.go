@@ -0,0 +1,106 @@
+package value
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// NormalizeConfig describes how a raw payload is cleaned up before it is converted.
+// The steps run in field order: trim, lowercase, strip quotes, regex extract.
+type NormalizeConfig struct {
+	Trim        *bool  `json:"trim"`        // trim surrounding whitespace (default: true)
+	Lowercase   bool   `json:"lowercase"`   // lowercase the payload
+	StripQuotes bool   `json:"stripQuotes"` // remove one pair of surrounding " or ' quotes, e.g. "\"person\"" -> "person"
+	Regex       string `json:"regex"`       // keep the first capture group (or the whole match), e.g. "motion=(\\d)"
+}
+
+// Normalizer is a compiled NormalizeConfig.
+type Normalizer struct {
+	trim, lower, unquote bool
+	re                   *regexp.Regexp
+}
+
+// DefaultNormalizer only trims whitespace.
+var DefaultNormalizer = &Normalizer{trim: true}
+
+// Compile validates cfg and builds its Normalizer.
+func (cfg NormalizeConfig) Compile() (*Normalizer, error) {
+	n := &Normalizer{
+		trim:    cfg.Trim == nil || *cfg.Trim,
+		lower:   cfg.Lowercase,
+		unquote: cfg.StripQuotes,
+	}
+	if cfg.Regex != "" {
+		re, err := regexp.Compile(cfg.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		n.re = re
+	}
+	return n, nil
+}
+
+// Apply runs the pipeline over s. matched is false when a regex is configured
+// and does not match, in which case s is returned without extraction.
+func (n *Normalizer) Apply(s string) (out string, matched bool) {
+	if n.trim {
+		s = strings.TrimSpace(s)
+	}
+	if n.lower {
+		s = strings.ToLower(s)
+	}
+	if n.unquote && len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	if n.re == nil {
+		return s, true
+	}
+	m := n.re.FindStringSubmatch(s)
+	switch {
+	case m == nil:
+		return s, false
+	case len(m) > 1:
+		return m[1], true
+	default:
+		return m[0], true
+	}
+}
+
+// Normalizers are the compiled pipelines of the properties of a device.
+type Normalizers map[string]*Normalizer
+
+// CompileNormalizers compiles the pipeline configured for each property.
+func CompileNormalizers(cfgs map[string]NormalizeConfig) (Normalizers, error) {
+	ns := make(Normalizers, len(cfgs))
+	for prop, cfg := range cfgs {
+		n, err := cfg.Compile()
+		if err != nil {
+			return nil, fmt.Errorf("normalize %s: %v", prop, err)
+		}
+		ns[prop] = n
+	}
+	return ns, nil
+}
+
+// Apply runs the pipeline configured for prop over payload; properties
+// without one are only trimmed, which is what the handlers always did.
+func (ns Normalizers) Apply(prop, payload string) string {
+	n, ok := ns[prop]
+	if !ok {
+		return Normalize(payload)
+	}
+	s, matched := n.Apply(payload)
+	if !matched {
+		klog.V(2).Infof("Normalize %s: regex does not match %q, keeping payload", prop, s)
+	}
+	return s
+}
+
+// Normalize applies DefaultNormalizer to s.
+func Normalize(s string) string {
+	out, _ := DefaultNormalizer.Apply(s)
+	return out
+}
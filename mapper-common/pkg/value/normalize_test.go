@@ -0,0 +1,57 @@
+package value
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"  ", ""},
+		{" person\r\n", "person"},
+		{"\t42 ", "42"},
+		{"Person", "Person"},
+		{`"person"`, `"person"`},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	off := false
+	tests := []struct {
+		name        string
+		cfg         NormalizeConfig
+		in          string
+		want        string
+		wantMatched bool
+	}{
+		{"no trim", NormalizeConfig{Trim: &off}, " x ", " x ", true},
+		{"lowercase", NormalizeConfig{Lowercase: true}, " PERSON ", "person", true},
+		{"double quotes", NormalizeConfig{StripQuotes: true}, ` "person" `, "person", true},
+		{"single quotes", NormalizeConfig{StripQuotes: true}, `'car'`, "car", true},
+		{"unbalanced quotes", NormalizeConfig{StripQuotes: true}, `"car'`, `"car'`, true},
+		{"lone quote", NormalizeConfig{StripQuotes: true}, `"`, `"`, true},
+		{"capture group", NormalizeConfig{Regex: `motion=(\d)`}, "ts=1 motion=1", "1", true},
+		{"whole match", NormalizeConfig{Regex: `\d+`}, "temp 23C", "23", true},
+		{"no match", NormalizeConfig{Regex: `motion=(\d)`}, " idle ", "idle", false},
+		{"steps in order", NormalizeConfig{Lowercase: true, StripQuotes: true, Regex: `^c(.*)`}, ` "CAR" `, "ar", true},
+	}
+	for _, tt := range tests {
+		n, err := tt.cfg.Compile()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, matched := n.Apply(tt.in)
+		if got != tt.want || matched != tt.wantMatched {
+			t.Errorf("%s: Apply(%q) = %q, %v; want %q, %v", tt.name, tt.in, got, matched, tt.want, tt.wantMatched)
+		}
+	}
+
+	if _, err := (NormalizeConfig{Regex: "("}).Compile(); err == nil {
+		t.Error("Compile accepted an invalid regex")
+	}
+}
@@ -0,0 +1,83 @@
+package value
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Canonical timestamp formats, see CanonicalTimestamp.
+const (
+	// TimestampRFC3339 is RFC 3339 in UTC, with the fractional seconds the input had.
+	TimestampRFC3339 = "rfc3339"
+	// TimestampEpochMillis is milliseconds since the Unix epoch.
+	TimestampEpochMillis = "epochMillis"
+)
+
+// timestampLayouts are the textual forms ParseTimestamp accepts. Forms without
+// a zone are read as UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// ValidateTimestampFormat checks a canonical timestamp format name.
+func ValidateTimestampFormat(format string) error {
+	switch format {
+	case TimestampRFC3339, TimestampEpochMillis:
+		return nil
+	}
+	return fmt.Errorf("invalid timestampFormat %q, must be %s or %s", format, TimestampRFC3339, TimestampEpochMillis)
+}
+
+// ParseTimestamp reads a timestamp sent by a device: a Unix epoch number in
+// seconds, milliseconds, microseconds or nanoseconds, told apart by magnitude
+// (seconds may carry a fraction), or an RFC 3339 / ISO 8601 date and time.
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		abs := n
+		if abs < 0 {
+			abs = -abs
+		}
+		switch {
+		case abs < 1e11:
+			return time.Unix(n, 0), nil
+		case abs < 1e14:
+			return time.UnixMilli(n), nil
+		case abs < 1e17:
+			return time.UnixMicro(n), nil
+		}
+		return time.Unix(0, n), nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: want Unix epoch seconds or milliseconds, or an RFC 3339 date and time", s)
+}
+
+// CanonicalTimestamp parses s with ParseTimestamp and formats it as format.
+func CanonicalTimestamp(s, format string) (string, error) {
+	if err := ValidateTimestampFormat(format); err != nil {
+		return "", err
+	}
+	t, err := ParseTimestamp(s)
+	if err != nil {
+		return "", err
+	}
+	if format == TimestampEpochMillis {
+		return strconv.FormatInt(t.UnixMilli(), 10), nil
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
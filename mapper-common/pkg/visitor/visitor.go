@@ -0,0 +1,36 @@
+// Package visitor holds the property visitor settings shared by the drivers.
+package visitor
+
+import "fmt"
+
+// Options are the visitor settings that do not depend on the protocol. The
+// drivers embed them in their visitor config.
+type Options struct {
+	// Enabled=false stops collecting the property without removing it (default: true).
+	Enabled *bool `json:"enabled"`
+	// ValueMap translates reported values, e.g. {"3": "person"};
+	// unknown values pass through unless ValueMapDefault is set.
+	ValueMap        map[string]string `json:"valueMap"`
+	ValueMapDefault string            `json:"valueMapDefault"`
+}
+
+// IsEnabled reports whether the property is collected. Properties are enabled
+// unless their visitor config sets "enabled": false.
+func (o Options) IsEnabled() bool {
+	return o.Enabled == nil || *o.Enabled
+}
+
+// MapValue translates value through ValueMap, e.g. a numeric class id "3" to "person".
+// Values without an entry are passed through, or replaced by ValueMapDefault when set.
+func (o Options) MapValue(value interface{}) interface{} {
+	if len(o.ValueMap) == 0 {
+		return value
+	}
+	if mapped, ok := o.ValueMap[fmt.Sprint(value)]; ok {
+		return mapped
+	}
+	if o.ValueMapDefault != "" {
+		return o.ValueMapDefault
+	}
+	return value
+}
@@ -1,59 +1,72 @@
-package main
-
-import (
-	"errors"
-	"k8s.io/klog/v2"
-
-	"github.com/kubeedge/mqtt/device"
-	"github.com/kubeedge/mapper-framework/pkg/common"
-	"github.com/kubeedge/mapper-framework/pkg/config"
-	"github.com/kubeedge/mapper-framework/pkg/grpcclient"
-	"github.com/kubeedge/mapper-framework/pkg/grpcserver"
-	"github.com/kubeedge/mapper-framework/pkg/httpserver"
-)
-
-func main() {
-	var err error
-	var c *config.Config
-
-	klog.InitFlags(nil)
-    defer klog.Flush()
-
-	if c, err = config.Parse(); err != nil {
-		klog.Fatal(err)
-	}
-	klog.Infof("config: %+v", c)
-
-	klog.Infoln("Mapper will register to edgecore")
-	deviceList, deviceModelList, err := grpcclient.RegisterMapper(true)
-	if err != nil {
-		klog.Fatal(err)
-	}
-	klog.Infoln("Mapper register finished")
-
-	panel := device.NewDevPanel()
-	err = panel.DevInit(deviceList, deviceModelList)
-	if err != nil && !errors.Is(err, device.ErrEmptyData) {
-		klog.Fatal(err)
-	}
-	klog.Infoln("devInit finished")
-	go panel.DevStart()
-
-	// start http server
-	httpServer := httpserver.NewRestServer(panel, c.Common.HTTPPort)
-	go httpServer.StartServer()
-
-	// start grpc server
-	grpcServer := grpcserver.NewServer(
-		grpcserver.Config{
-			SockPath: c.GrpcServer.SocketPath,
-			Protocol: common.ProtocolCustomized,
-		},
-		panel,
-	)
-	defer grpcServer.Stop()
-	if err = grpcServer.Start(); err != nil {
-		klog.Fatal(err)
-	}
-
-}
+package main
+
+import (
+	"errors"
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mqtt/device"
+	"github.com/kubeedge/mapper-common/pkg/logger"
+	"github.com/kubeedge/mapper-common/pkg/report"
+	"github.com/kubeedge/mapper-framework/pkg/common"
+	"github.com/kubeedge/mapper-framework/pkg/config"
+	"github.com/kubeedge/mapper-framework/pkg/grpcclient"
+	"github.com/kubeedge/mapper-framework/pkg/grpcserver"
+	"github.com/kubeedge/mapper-framework/pkg/httpserver"
+)
+
+func main() {
+	var err error
+	var c *config.Config
+
+	klog.InitFlags(nil)
+    defer klog.Flush()
+
+	var logFormat string
+	pflag.StringVar(&logFormat, "log-format", logger.FormatText, "log output format: text or json")
+	var reportFlags report.Flags
+	reportFlags.AddFlags(pflag.CommandLine)
+	if c, err = config.Parse(); err != nil {
+		klog.Fatal(err)
+	}
+	if err = logger.Setup(logFormat); err != nil {
+		klog.Fatal(err)
+	}
+	klog.Infof("config: %+v", c)
+	if err = reportFlags.Apply(); err != nil {
+		klog.Fatal(err)
+	}
+
+	klog.Infoln("Mapper will register to edgecore")
+	deviceList, deviceModelList, err := grpcclient.RegisterMapper(true)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	klog.Infoln("Mapper register finished")
+
+	panel := device.NewDevPanel()
+	err = panel.DevInit(deviceList, deviceModelList)
+	if err != nil && !errors.Is(err, device.ErrEmptyData) {
+		klog.Fatal(err)
+	}
+	klog.Infoln("devInit finished")
+	go panel.DevStart()
+
+	// start http server
+	httpServer := httpserver.NewRestServer(panel, c.Common.HTTPPort)
+	go httpServer.StartServer()
+
+	// start grpc server
+	grpcServer := grpcserver.NewServer(
+		grpcserver.Config{
+			SockPath: c.GrpcServer.SocketPath,
+			Protocol: common.ProtocolCustomized,
+		},
+		panel,
+	)
+	defer grpcServer.Stop()
+	if err = grpcServer.Start(); err != nil {
+		klog.Fatal(err)
+	}
+
+}
@@ -1,691 +1,800 @@
-package device
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"os"
-	"os/signal"
-	"strings"
-	"sync"
-	"time"
-
-	"k8s.io/klog/v2"
-
-	dbInflux "github.com/kubeedge/mqtt/data/dbmethod/influxdb2"
-	dbMysql "github.com/kubeedge/mqtt/data/dbmethod/mysql"
-	dbRedis "github.com/kubeedge/mqtt/data/dbmethod/redis"
-	dbTdengine "github.com/kubeedge/mqtt/data/dbmethod/tdengine"
-	httpMethod "github.com/kubeedge/mqtt/data/publish/http"
-	mqttMethod "github.com/kubeedge/mqtt/data/publish/mqtt"
-	otelMethod "github.com/kubeedge/mqtt/data/publish/otel"
-	"github.com/kubeedge/mqtt/data/stream"
-	"github.com/kubeedge/mqtt/driver"
-	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
-	"github.com/kubeedge/mapper-framework/pkg/common"
-	"github.com/kubeedge/mapper-framework/pkg/global"
-	"github.com/kubeedge/mapper-framework/pkg/util/parse"
-)
-
-type DevPanel struct {
-	deviceMuxs   map[string]context.CancelFunc
-	devices      map[string]*driver.CustomizedDev
-	models       map[string]common.DeviceModel
-	wg           sync.WaitGroup
-	serviceMutex sync.Mutex
-	quitChan     chan os.Signal
-}
-
-var (
-	devPanel *DevPanel
-	once     sync.Once
-)
-
-var ErrEmptyData = errors.New("device or device model list is empty")
-
-
-
-// NewDevPanel init and return devPanel
-func NewDevPanel() *DevPanel {
-	once.Do(func() {
-		devPanel = &DevPanel{
-			deviceMuxs:   make(map[string]context.CancelFunc),
-			devices:      make(map[string]*driver.CustomizedDev),
-			models:       make(map[string]common.DeviceModel),
-			wg:           sync.WaitGroup{},
-			serviceMutex: sync.Mutex{},
-			quitChan:     make(chan os.Signal),
-		}
-	})
-	return devPanel
-}
-
-// DevStart start all devices.
-func (d *DevPanel) DevStart() {
-	klog.Infof("DevStart called with %d devices", len(d.devices))
-	for id, dev := range d.devices {
-		klog.V(4).Info("Dev: ", id, dev)
-		klog.V(4).Info("Starting device %s", id)
-		ctx, cancel := context.WithCancel(context.Background())
-		d.deviceMuxs[id] = cancel
-		d.wg.Add(1)
-		klog.Infof("About to start goroutine for device %s", id)
-		go d.start(ctx, dev)
-	}
-	signal.Notify(d.quitChan, os.Interrupt)
-	go func() {
-		<-d.quitChan
-		for id, device := range d.devices {
-			err := device.CustomizedClient.StopDevice()
-			if err != nil {
-				klog.Errorf("Service has stopped but failed to stop %s:%v", id, err)
-			}
-		}
-		klog.V(1).Info("Exit mapper")
-		os.Exit(1)
-	}()
-	d.wg.Wait()
-}
-
-// start the device
-func (d *DevPanel) start(ctx context.Context, dev *driver.CustomizedDev) {
-	defer d.wg.Done()
-
-	var protocolConfig driver.ProtocolConfig
-	if err := json.Unmarshal(dev.Instance.PProtocol.ConfigData, &protocolConfig); err != nil {
-		klog.Errorf("Unmarshal ProtocolConfigs error: %v", err)
-		return
-	}
-	client, err := driver.NewClient(protocolConfig)
-	if err != nil {
-		klog.Errorf("Init dev %s error: %v", dev.Instance.Name, err)
-		return
-	}
-	dev.CustomizedClient = client
-	err = dev.CustomizedClient.InitDevice()
-	if err != nil {
-		klog.Errorf("Init device %s error: %v", dev.Instance.ID, err)
-		return
-	}
-	klog.Infof("Device %s initialization completed, starting dataHandler", dev.Instance.Name)
-	go dataHandler(ctx, dev)
-	klog.Infof("dataHandler goroutine started for device %s", dev.Instance.Name)
-	<-ctx.Done()
-}
-
-// dataHandler initialize the timer to handle data plane and devicetwin.
-func dataHandler(ctx context.Context, dev *driver.CustomizedDev) {
-	klog.Infof("dataHandler started for device %s with %d twins", dev.Instance.Name, len(dev.Instance.Twins))
-	
-	// handle device status report
-	getStates := &DeviceStates{
-		Client:          dev.CustomizedClient,
-		DeviceName:      dev.Instance.Name,
-		DeviceNamespace: dev.Instance.Namespace,
-		ReportToCloud:   dev.Instance.Status.ReportToCloud,
-		ReportCycle:     time.Millisecond * time.Duration(dev.Instance.Status.ReportCycle),
-	}
-	go getStates.Run(ctx)
-	
-	klog.Infof("Starting twin processing loop for %d twins", len(dev.Instance.Twins))
-	
-	// handle device twin report
-	for _, twin := range dev.Instance.Twins {
-		klog.Infof("Processing twin property: %s", twin.PropertyName)
-		
-		twin.Property.PProperty.DataType = strings.ToLower(twin.Property.PProperty.DataType)
-		var visitorConfig driver.VisitorConfig
-
-		err := json.Unmarshal(twin.Property.Visitors, &visitorConfig)
-		visitorConfig.VisitorConfigData.DataType = strings.ToLower(visitorConfig.VisitorConfigData.DataType)
-		if err != nil {
-			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
-			continue
-		}
-		
-		klog.Infof("Twin property %s - DataType: %s, ReportToCloud: %v", twin.PropertyName, twin.Property.PProperty.DataType, twin.Property.ReportToCloud)
-		
-		err = setVisitor(&visitorConfig, &twin, dev)
-		if err != nil {
-			klog.Error(err)
-			continue
-		}
-
-		// If the device property type is streaming, it will directly enter the streaming data processing function,
-		// such as saving frames or saving videos, and will no longer push it to the user database and application.
-		// If there are other needs for stream data processing, users can add functions in the mapper/data/stream directory.
-		if twin.Property.PProperty.DataType == "stream" {
-			klog.Infof("Property %s is stream type, skipping twin data collection", twin.PropertyName)
-			err = stream.StreamHandler(&twin, dev.CustomizedClient, &visitorConfig)
-			if err != nil {
-				klog.Errorf("processed streaming data by %s Error: %v", twin.PropertyName, err)
-			}
-			continue
-		}
-
-		klog.Infof("Creating TwinData for property %s", twin.PropertyName)
-
-		// handle twin
-		twinData := &TwinData{
-			DeviceName:      dev.Instance.Name,
-			DeviceNamespace: dev.Instance.Namespace,
-			Client:          dev.CustomizedClient,
-			Name:            twin.PropertyName,
-			Type:            twin.ObservedDesired.Metadata.Type,
-			ObservedDesired: twin.ObservedDesired,
-			VisitorConfig:   &visitorConfig,
-			Topic:           fmt.Sprintf(common.TopicTwinUpdate, dev.Instance.ID),
-			CollectCycle:    time.Millisecond * time.Duration(twin.Property.CollectCycle),
-			ReportToCloud:   twin.Property.ReportToCloud,
-		}
-		klog.Infof("Starting TwinData goroutine for property %s with CollectCycle %v, ReportToCloud %v", twin.PropertyName, twinData.CollectCycle, twinData.ReportToCloud)
-		go twinData.Run(ctx)
-
-		dataModel := common.NewDataModel(dev.Instance.Name, twin.Property.PropertyName, dev.Instance.Namespace, common.WithType(twin.ObservedDesired.Metadata.Type))
-		// handle push method
-		if twin.Property.PushMethod.MethodConfig != nil && twin.Property.PushMethod.MethodName != "" {
-			pushHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
-		}
-		// handle database
-		if twin.Property.PushMethod.DBMethod.DBMethodName != "" {
-			dbHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
-			switch twin.Property.PushMethod.DBMethod.DBMethodName {
-			// TODO add more database
-			case "influx":
-				dbInflux.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
-			case "redis":
-				dbRedis.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
-			case "tdengine":
-				dbTdengine.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
-			case "mysql":
-				dbMysql.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
-			}
-		}
-	}
-}
-
-// pushHandler start data panel work
-func pushHandler(ctx context.Context, twin *common.Twin, client *driver.CustomizedClient, visitorConfig *driver.VisitorConfig, dataModel *common.DataModel) {
-	if twin.Property.PushMethod.MethodName == common.PushMethodOTEL {
-		otelMethod.DataHandler(ctx, twin, client, visitorConfig, dataModel)
-		return
-	}
-
-	var dataPanel global.DataPanel
-	var err error
-	// initialization dataPanel
-	switch twin.Property.PushMethod.MethodName {
-	case common.PushMethodHTTP:
-		dataPanel, err = httpMethod.NewDataPanel(twin.Property.PushMethod.MethodConfig)
-	case common.PushMethodMQTT:
-		dataPanel, err = mqttMethod.NewDataPanel(twin.Property.PushMethod.MethodConfig)
-	default:
-		err = errors.New("custom protocols are not currently supported when push data")
-	}
-	if err != nil {
-		klog.Errorf("new data panel error: %v", err)
-		return
-	}
-	// initialization PushMethod
-	err = dataPanel.InitPushMethod()
-	if err != nil {
-		klog.Errorf("init publish method err: %v", err)
-		return
-	}
-	reportCycle := time.Millisecond * time.Duration(twin.Property.ReportCycle)
-	if reportCycle == 0 {
-		reportCycle = common.DefaultReportCycle
-	}
-	ticker := time.NewTicker(reportCycle)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				deviceData, err := client.GetDeviceData(visitorConfig)
-				if err != nil {
-					klog.Errorf("publish error: %v", err)
-					continue
-				}
-				sData, err := common.ConvertToString(deviceData)
-				if err != nil {
-					klog.Errorf("Failed to convert publish method data : %v", err)
-					continue
-				}
-				dataModel.SetValue(sData)
-				dataModel.SetTimeStamp()
-				dataPanel.Push(dataModel)
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
-}
-
-// dbHandler start db client to save data
-func dbHandler(ctx context.Context, twin *common.Twin, client *driver.CustomizedClient, visitorConfig *driver.VisitorConfig, dataModel *common.DataModel) {
-	switch twin.Property.PushMethod.DBMethod.DBMethodName {
-	// TODO add more database
-	case "influx":
-		dbInflux.DataHandler(ctx, twin, client, visitorConfig, dataModel)
-
-	case "redis":
-		dbRedis.DataHandler(ctx, twin, client, visitorConfig, dataModel)
-
-	case "tdengine":
-		dbTdengine.DataHandler(ctx, twin, client, visitorConfig, dataModel)
-
-	case "mysql":
-		dbMysql.DataHandler(ctx, twin, client, visitorConfig, dataModel)
-	}
-}
-
-// setVisitor check if visitor property is readonly, if not then set it.
-func setVisitor(visitorConfig *driver.VisitorConfig, twin *common.Twin, dev *driver.CustomizedDev) error {
-	if twin.Property.PProperty.AccessMode == "ReadOnly" {
-		klog.V(3).Infof("%s twin readonly property: %s", dev.Instance.Name, twin.PropertyName)
-		return nil
-	}
-	klog.V(2).Infof("Convert type: %s, value: %s ", twin.Property.PProperty.DataType, twin.ObservedDesired.Value)
-	var value interface{}
-	if twin.ObservedDesired.Value != "" {
-		convertedValue, err := common.Convert(twin.Property.PProperty.DataType, twin.ObservedDesired.Value)
-		if err != nil {
-			klog.Errorf("Failed to convert value as %s : %v", twin.Property.PProperty.DataType, err)
-			return err
-		}
-		value = convertedValue
-	} else {
-		value = twin.ObservedDesired.Value
-	}
-	err := dev.CustomizedClient.SetDeviceData(value, visitorConfig)
-	if err != nil {
-		return fmt.Errorf("%s set device data error: %v", twin.PropertyName, err)
-	}
-	return nil
-}
-
-// DevInit initialize the device
-func (d *DevPanel) DevInit(deviceList []*dmiapi.Device, deviceModelList []*dmiapi.DeviceModel) error {
-	if len(deviceList) == 0 || len(deviceModelList) == 0 {
-		return ErrEmptyData
-	}
-
-	for i := range deviceModelList {
-		model := deviceModelList[i]
-		cur := parse.GetDeviceModelFromGrpc(model)
-		modelID := parse.GetResourceID(model.Namespace, model.Name)
-		d.models[modelID] = cur
-	}
-
-	for i := range deviceList {
-		device := deviceList[i]
-		modelID := parse.GetResourceID(device.Namespace, device.Spec.DeviceModelReference)
-		commonModel := d.models[modelID]
-		protocol, err := parse.BuildProtocolFromGrpc(device)
-		if err != nil {
-			return err
-		}
-		instance, err := parse.GetDeviceFromGrpc(device, &commonModel)
-		if err != nil {
-			return err
-		}
-		instance.PProtocol = protocol
-
-		cur := new(driver.CustomizedDev)
-		cur.Instance = *instance
-		d.devices[instance.ID] = cur
-	}
-
-	return nil
-}
-
-// UpdateDev stop old device only if protocol config changed; otherwise update twins in place.
-func (d *DevPanel) UpdateDev(model *common.DeviceModel, newDev *common.DeviceInstance) {
-	klog.Infof("UpdateDevice")
-	klog.Infof("model: %+v", model)
-
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-
-	id := newDev.ID
-	old, ok := d.devices[id]
-	if !ok {
-		// New device, init and start
-		klog.Infof("Device %s not found, initializing new device", id)
-		d.devices[id] = &driver.CustomizedDev{
-			Instance:         *newDev, // Instance is a value type
-			CustomizedClient: nil,
-		}
-		ctx, cancel := context.WithCancel(context.Background())
-		d.deviceMuxs[id] = cancel
-		d.wg.Add(1)
-		go d.start(ctx, d.devices[id])
-		return
-	}
-
-	// Decide whether to restart based on protocol config diffs
-	if protocolConfigChanged(&old.Instance, newDev) {
-		klog.Infof("Protocol config changed for %s, restarting device", id)
-
-		// Stop old client and goroutines
-		if old.CustomizedClient != nil {
-			if err := old.CustomizedClient.StopDevice(); err != nil {
-				klog.Errorf("Failed to stop device %s: %v", id, err)
-			}
-		}
-		// Cancel old context
-		if cancel, ok := d.deviceMuxs[id]; ok {
-			cancel()
-			delete(d.deviceMuxs, id)
-		}
-
-		// Replace instance, start again
-		old.Instance = *newDev
-		ctx, cancel := context.WithCancel(context.Background())
-		d.deviceMuxs[id] = cancel
-		d.wg.Add(1)
-		go d.start(ctx, old)
-		return
-	}
-
-	// No protocol change: keep client, just update instance fields (twins, cycles, metadata)
-	klog.Infof("No protocol change for %s, skipping restart. Updating twins/status only.", id)
-	old.Instance.Twins = newDev.Twins
-	old.Instance.Status = newDev.Status
-	old.Instance.Name = newDev.Name
-	old.Instance.Namespace = newDev.Namespace
-	old.Instance.ID = newDev.ID
-}
-
-// protocolConfigChanged compares only the protocol-critical config to decide if a restart is needed.
-func protocolConfigChanged(oldInst, newInst *common.DeviceInstance) bool {
-	// Compare protocol name
-	if oldInst.PProtocol.ProtocolName != newInst.PProtocol.ProtocolName {
-		return true
-	}
-	// Compare raw protocol config bytes (JSON blobs)
-	return !bytes.Equal(oldInst.PProtocol.ConfigData, newInst.PProtocol.ConfigData)
-}
-
-// UpdateDev stop old device, then update and start new device
-/*func (d *DevPanel) UpdateDev(model *common.DeviceModel, device *common.DeviceInstance) {
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-
-	if oldDevice, ok := d.devices[device.ID]; ok {
-		err := d.stopDev(oldDevice, device.ID)
-		if err != nil {
-			klog.Error(err)
-		}
-	}
-	// start new device
-	d.devices[device.ID] = new(driver.CustomizedDev)
-	d.devices[device.ID].Instance = *device
-	d.models[model.ID] = *model
-
-	ctx, cancelFunc := context.WithCancel(context.Background())
-	d.deviceMuxs[device.ID] = cancelFunc
-	d.wg.Add(1)
-	go d.start(ctx, d.devices[device.ID])
-}*/
-
-// UpdateDevTwins update device's twins
-func (d *DevPanel) UpdateDevTwins(deviceID string, twins []common.Twin) error {
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	dev, ok := d.devices[deviceID]
-	if !ok {
-		return fmt.Errorf("device %s not found", deviceID)
-	}
-	dev.Instance.Twins = twins
-	model := d.models[dev.Instance.Model]
-	d.UpdateDev(&model, &dev.Instance)
-
-	return nil
-}
-
-// DealDeviceTwinGet get device's twin data
-func (d *DevPanel) DealDeviceTwinGet(deviceID string, twinName string) (interface{}, error) {
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	dev, ok := d.devices[deviceID]
-	if !ok {
-		return nil, fmt.Errorf("not found device %s", deviceID)
-	}
-	var res []parse.TwinResultResponse
-	for _, twin := range dev.Instance.Twins {
-		if twinName != "" && twin.PropertyName != twinName {
-			continue
-		}
-		payload, err := getTwinData(deviceID, twin, d.devices[deviceID])
-		if err != nil {
-			return nil, err
-		}
-		item := parse.TwinResultResponse{
-			PropertyName: twinName,
-			Payload:      payload,
-		}
-		res = append(res, item)
-	}
-	return json.Marshal(res)
-}
-
-// getTwinData get twin
-func getTwinData(deviceID string, twin common.Twin, dev *driver.CustomizedDev) ([]byte, error) {
-	var visitorConfig driver.VisitorConfig
-	err := json.Unmarshal(twin.Property.Visitors, &visitorConfig)
-	if err != nil {
-		return nil, err
-	}
-	err = setVisitor(&visitorConfig, &twin, dev)
-	if err != nil {
-		return nil, err
-	}
-	twinData := &TwinData{
-		DeviceName:    deviceID,
-		Client:        dev.CustomizedClient,
-		Name:          twin.PropertyName,
-		Type:          twin.ObservedDesired.Metadata.Type,
-		VisitorConfig: &visitorConfig,
-		Topic:         fmt.Sprintf(common.TopicTwinUpdate, deviceID),
-	}
-	return twinData.GetPayLoad()
-}
-
-// GetDevice get device instance
-func (d *DevPanel) GetDevice(deviceID string) (interface{}, error) {
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	found, ok := d.devices[deviceID]
-	if !ok || found == nil {
-		return nil, fmt.Errorf("device %s not found", deviceID)
-	}
-
-	// get the latest reported twin value
-	for i, twin := range found.Instance.Twins {
-		payload, err := getTwinData(deviceID, twin, found)
-		if err != nil {
-			return nil, err
-		}
-		found.Instance.Twins[i].Reported.Value = string(payload)
-	}
-	return found, nil
-}
-
-// RemoveDevice remove device instance
-func (d *DevPanel) RemoveDevice(deviceID string) error {
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	dev := d.devices[deviceID]
-	delete(d.devices, deviceID)
-	err := d.stopDev(dev, deviceID)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-// WriteDevice write value to the device
-func (d *DevPanel) WriteDevice(deviceMethodName, deviceID, propertyName, data string) error {
-	var dataType string
-	var deviceproperty common.DeviceProperty
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	dev, ok := d.devices[deviceID]
-	if !ok {
-		return fmt.Errorf("not found device %s", deviceID)
-	}
-
-	deviceMethodMap := make(map[string][]string)
-
-	// get all deviceMethod of the device
-	for _, method := range dev.Instance.Methods {
-		deviceMethodMap[method.Name] = append(deviceMethodMap[method.Name], method.PropertyNames...)
-	}
-	// Determine whether the called device method exists
-	propertyNames, ok := deviceMethodMap[deviceMethodName]
-	if !ok {
-		return fmt.Errorf("deviceMethod name %s does not exist in device instance", deviceMethodName)
-	}
-	// Determine whether the device property to be written is in the list defined by the device method
-	flag := false
-	for _, name := range propertyNames {
-		if name == propertyName {
-			flag = true
-			break
-		}
-	}
-	if !flag {
-		return fmt.Errorf("deviceProperty %s to be written is not in the list defined by devicemethod", propertyName)
-	}
-	// Determine whether the device property to be written is in the device instance
-	flag = false
-	for _, property := range dev.Instance.Properties {
-		if property.PropertyName != propertyName {
-			continue
-		}
-		dataType = property.PProperty.DataType
-		deviceproperty = property
-		flag = true
-		break
-	}
-	if !flag {
-		return fmt.Errorf("can't find device propertyName %s in device instance", propertyName)
-	}
-	klog.V(2).Infof("start writing values %v to device %s property %s", data, deviceID, propertyName)
-	writeData, err := common.Convert(strings.ToLower(dataType), data)
-	if err != nil {
-		return fmt.Errorf("conversion data format failed, datatype is %s, data is %s", strings.ToLower(dataType), data)
-	}
-	var visitorConfig driver.VisitorConfig
-	err = json.Unmarshal(deviceproperty.Visitors, &visitorConfig)
-	if err != nil {
-		return err
-	}
-
-	err = dev.CustomizedClient.DeviceDataWrite(&visitorConfig, deviceMethodName, propertyName, writeData)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-// stopDev stop device and goroutine
-func (d *DevPanel) stopDev(dev *driver.CustomizedDev, id string) error {
-	cancelFunc, ok := d.deviceMuxs[id]
-	if !ok {
-		return fmt.Errorf("can not find device %s from device muxs", id)
-	}
-
-	err := dev.CustomizedClient.StopDevice()
-	if err != nil {
-		klog.Errorf("stop device %s error: %v", id, err)
-	}
-	cancelFunc()
-	return nil
-}
-
-// GetModel if the model exists, return device model
-func (d *DevPanel) GetModel(modelID string) (common.DeviceModel, error) {
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	if model, ok := d.models[modelID]; ok {
-		return model, nil
-	}
-	return common.DeviceModel{}, fmt.Errorf("deviceModel %s not found", modelID)
-}
-
-// UpdateModel update device model
-func (d *DevPanel) UpdateModel(model *common.DeviceModel) {
-	d.serviceMutex.Lock()
-	d.models[model.ID] = *model
-	d.serviceMutex.Unlock()
-}
-
-// RemoveModel remove device model
-func (d *DevPanel) RemoveModel(modelID string) {
-	d.serviceMutex.Lock()
-	delete(d.models, modelID)
-	d.serviceMutex.Unlock()
-}
-
-// GetTwinResult Get twin's value and data type
-func (d *DevPanel) GetTwinResult(deviceID string, twinName string) (string, string, error) {
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	dev, ok := d.devices[deviceID]
-	if !ok {
-		return "", "", fmt.Errorf("not found device %s", deviceID)
-	}
-	var res string
-	var dataType string
-	for _, twin := range dev.Instance.Twins {
-		if twinName != "" && twin.PropertyName != twinName {
-			continue
-		}
-		var visitorConfig driver.VisitorConfig
-		err := json.Unmarshal(twin.Property.Visitors, &visitorConfig)
-		if err != nil {
-			return "", "", err
-		}
-		err = setVisitor(&visitorConfig, &twin, dev)
-
-		data, err := dev.CustomizedClient.GetDeviceData(&visitorConfig)
-		if err != nil {
-			return "", "", fmt.Errorf("get device data failed: %v", err)
-		}
-		res, err = common.ConvertToString(data)
-		if err != nil {
-			return "", "", err
-		}
-		dataType = twin.Property.PProperty.DataType
-	}
-	return res, dataType, nil
-}
-
-// GetDeviceMethod get method and property dataType of device
-func (d *DevPanel) GetDeviceMethod(deviceID string) (map[string][]string, map[string]string, error) {
-	klog.V(2).Infof("starting get method and property dataType of device %s", deviceID)
-	d.serviceMutex.Lock()
-	defer d.serviceMutex.Unlock()
-	found, ok := d.devices[deviceID]
-	if !ok || found == nil {
-		return nil, nil, fmt.Errorf("device %s not found", deviceID)
-	}
-
-	deviceMethodMap := make(map[string][]string)
-	propertyTypeMap := make(map[string]string)
-
-	// get all deviceMethod of the device
-	for _, method := range found.Instance.Methods {
-		deviceMethodMap[method.Name] = append(deviceMethodMap[method.Name], method.PropertyNames...)
-	}
-
-	// get all deviceProperty type of the device
-	for _, property := range found.Instance.Properties {
-		propertyTypeMap[property.Name] = strings.ToLower(property.PProperty.DataType) // The original data type is an uppercase form such as INT FLOAT and needs to be converted.
-	}
-	return deviceMethodMap, propertyTypeMap, nil
-}
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	dbInflux "github.com/kubeedge/mqtt/data/dbmethod/influxdb2"
+	dbMysql "github.com/kubeedge/mqtt/data/dbmethod/mysql"
+	dbRedis "github.com/kubeedge/mqtt/data/dbmethod/redis"
+	dbTdengine "github.com/kubeedge/mqtt/data/dbmethod/tdengine"
+	httpMethod "github.com/kubeedge/mqtt/data/publish/http"
+	mqttMethod "github.com/kubeedge/mqtt/data/publish/mqtt"
+	otelMethod "github.com/kubeedge/mqtt/data/publish/otel"
+	"github.com/kubeedge/mqtt/data/stream"
+	"github.com/kubeedge/mqtt/driver"
+	"github.com/kubeedge/mapper-common/pkg/value"
+	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
+	"github.com/kubeedge/mapper-framework/pkg/common"
+	"github.com/kubeedge/mapper-framework/pkg/global"
+	"github.com/kubeedge/mapper-framework/pkg/util/parse"
+)
+
+type DevPanel struct {
+	deviceMuxs   map[string]context.CancelFunc
+	devices      map[string]*driver.CustomizedDev
+	models       map[string]common.DeviceModel
+	wg           sync.WaitGroup
+	serviceMutex sync.Mutex
+	quitChan     chan os.Signal
+}
+
+var (
+	devPanel *DevPanel
+	once     sync.Once
+)
+
+var ErrEmptyData = errors.New("device or device model list is empty")
+
+
+
+// NewDevPanel init and return devPanel
+func NewDevPanel() *DevPanel {
+	once.Do(func() {
+		devPanel = &DevPanel{
+			deviceMuxs:   make(map[string]context.CancelFunc),
+			devices:      make(map[string]*driver.CustomizedDev),
+			models:       make(map[string]common.DeviceModel),
+			wg:           sync.WaitGroup{},
+			serviceMutex: sync.Mutex{},
+			quitChan:     make(chan os.Signal),
+		}
+	})
+	return devPanel
+}
+
+// DevStart start all devices.
+func (d *DevPanel) DevStart() {
+	klog.Infof("DevStart called with %d devices", len(d.devices))
+	for id, dev := range d.devices {
+		klog.V(4).Info("Dev: ", id, dev)
+		klog.V(4).Infof("Starting device %s", id)
+		ctx, cancel := context.WithCancel(context.Background())
+		d.deviceMuxs[id] = cancel
+		d.wg.Add(1)
+		klog.Infof("About to start goroutine for device %s", id)
+		go d.start(ctx, dev)
+	}
+	signal.Notify(d.quitChan, os.Interrupt)
+	go func() {
+		<-d.quitChan
+		for id, device := range d.devices {
+			err := device.CustomizedClient.StopDevice()
+			if err != nil {
+				klog.Errorf("Service has stopped but failed to stop %s:%v", id, err)
+			}
+		}
+		klog.V(1).Info("Exit mapper")
+		os.Exit(1)
+	}()
+	d.wg.Wait()
+}
+
+// start the device
+func (d *DevPanel) start(ctx context.Context, dev *driver.CustomizedDev) {
+	defer d.wg.Done()
+
+	var protocolConfig driver.ProtocolConfig
+	if err := json.Unmarshal(dev.Instance.PProtocol.ConfigData, &protocolConfig); err != nil {
+		klog.Errorf("Unmarshal ProtocolConfigs error: %v", err)
+		return
+	}
+	client, err := driver.NewClient(protocolConfig)
+	if err != nil {
+		klog.Errorf("Init dev %s error: %v", dev.Instance.Name, err)
+		return
+	}
+	client.DisableProperties(disabledProperties(&dev.Instance))
+	client.SetDeviceName(dev.Instance.Name, dev.Instance.Namespace)
+	dev.CustomizedClient = client
+	err = dev.CustomizedClient.InitDevice()
+	if err != nil {
+		klog.Errorf("Init device %s error: %v", dev.Instance.ID, err)
+		return
+	}
+	klog.Infof("Device %s initialization completed, starting dataHandler", dev.Instance.Name)
+	go dataHandler(ctx, dev)
+	klog.Infof("dataHandler goroutine started for device %s", dev.Instance.Name)
+	<-ctx.Done()
+}
+
+// dataHandler initialize the timer to handle data plane and devicetwin.
+func dataHandler(ctx context.Context, dev *driver.CustomizedDev) {
+	klog.Infof("dataHandler started for device %s with %d twins", dev.Instance.Name, len(dev.Instance.Twins))
+	
+	// handle device status report
+	getStates := &DeviceStates{
+		Client:          dev.CustomizedClient,
+		DeviceName:      dev.Instance.Name,
+		DeviceNamespace: dev.Instance.Namespace,
+		ReportToCloud:   dev.Instance.Status.ReportToCloud,
+		ReportCycle:     time.Millisecond * time.Duration(dev.Instance.Status.ReportCycle),
+	}
+	go getStates.Run(ctx)
+	
+	klog.Infof("Starting twin processing loop for %d twins", len(dev.Instance.Twins))
+	
+	// handle device twin report
+	for _, twin := range dev.Instance.Twins {
+		klog.Infof("Processing twin property: %s", twin.PropertyName)
+		
+		twin.Property.PProperty.DataType = strings.ToLower(twin.Property.PProperty.DataType)
+		var visitorConfig driver.VisitorConfig
+
+		err := json.Unmarshal(twin.Property.Visitors, &visitorConfig)
+		visitorConfig.VisitorConfigData.DataType = strings.ToLower(visitorConfig.VisitorConfigData.DataType)
+		if err != nil {
+			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
+			continue
+		}
+		
+		klog.Infof("Twin property %s - DataType: %s, ReportToCloud: %v", twin.PropertyName, twin.Property.PProperty.DataType, twin.Property.ReportToCloud)
+		
+		if !visitorConfig.VisitorConfigData.IsEnabled() {
+			klog.Infof("Property %s is disabled, skipping", twin.PropertyName)
+			continue
+		}
+		if err = dev.CustomizedClient.RegisterVisitor(&visitorConfig); err != nil {
+			klog.Error(err)
+			continue
+		}
+		dev.CustomizedClient.RegisterModelProperty(visitorConfig.VisitorConfigData.PropertyName, twin.Property.PProperty)
+		err = setVisitor(&visitorConfig, &twin, dev)
+		if err != nil {
+			klog.Error(err)
+			continue
+		}
+
+		// If the device property type is streaming, it will directly enter the streaming data processing function,
+		// such as saving frames or saving videos, and will no longer push it to the user database and application.
+		// If there are other needs for stream data processing, users can add functions in the mapper/data/stream directory.
+		if twin.Property.PProperty.DataType == "stream" {
+			klog.Infof("Property %s is stream type, skipping twin data collection", twin.PropertyName)
+			err = stream.StreamHandler(&twin, dev.CustomizedClient, &visitorConfig)
+			if err != nil {
+				klog.Errorf("processed streaming data by %s Error: %v", twin.PropertyName, err)
+			}
+			continue
+		}
+
+		klog.Infof("Creating TwinData for property %s", twin.PropertyName)
+
+		// handle twin
+		cycle, err := collectCycle(&twin, &visitorConfig)
+		if err != nil {
+			klog.Errorf("Property %s: %v", twin.PropertyName, err)
+			continue
+		}
+		twinData := &TwinData{
+			DeviceName:      dev.Instance.Name,
+			DeviceNamespace: dev.Instance.Namespace,
+			Client:          dev.CustomizedClient,
+			Name:            twin.PropertyName,
+			Type:            twin.ObservedDesired.Metadata.Type,
+			ObservedDesired: twin.ObservedDesired,
+			Desired:         desiredLookup(dev.Instance.ID, twin.PropertyName, twin.ObservedDesired),
+			VisitorConfig:   &visitorConfig,
+			Topic:           fmt.Sprintf(common.TopicTwinUpdate, dev.Instance.ID),
+			CollectCycle:    cycle,
+			ReportToCloud:   twin.Property.ReportToCloud,
+
+			DeviceNameTemplate: visitorConfig.VisitorConfigData.DeviceNameTemplate,
+			NamespaceOverride:  visitorConfig.VisitorConfigData.NamespaceOverride,
+			ReportOnStart:      visitorConfig.VisitorConfigData.ReportOnStart,
+		}
+		klog.Infof("Starting TwinData goroutine for property %s with CollectCycle %v, ReportToCloud %v", twin.PropertyName, twinData.CollectCycle, twinData.ReportToCloud)
+		go twinData.Run(ctx)
+
+		dataModel := common.NewDataModel(dev.Instance.Name, twin.Property.PropertyName, dev.Instance.Namespace, common.WithType(twin.ObservedDesired.Metadata.Type))
+		// handle push method
+		if twin.Property.PushMethod.MethodConfig != nil && twin.Property.PushMethod.MethodName != "" {
+			pushHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
+		}
+		// handle database
+		if twin.Property.PushMethod.DBMethod.DBMethodName != "" {
+			dbHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
+			switch twin.Property.PushMethod.DBMethod.DBMethodName {
+			// TODO add more database
+			case "influx":
+				dbInflux.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
+			case "redis":
+				dbRedis.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
+			case "tdengine":
+				dbTdengine.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
+			case "mysql":
+				dbMysql.DataHandler(ctx, &twin, dev.CustomizedClient, &visitorConfig, dataModel)
+			}
+		}
+	}
+}
+
+// pushHandler start data panel work
+func pushHandler(ctx context.Context, twin *common.Twin, client *driver.CustomizedClient, visitorConfig *driver.VisitorConfig, dataModel *common.DataModel) {
+	if twin.Property.PushMethod.MethodName == common.PushMethodOTEL {
+		otelMethod.DataHandler(ctx, twin, client, visitorConfig, dataModel)
+		return
+	}
+
+	var dataPanel global.DataPanel
+	var err error
+	// initialization dataPanel
+	switch twin.Property.PushMethod.MethodName {
+	case common.PushMethodHTTP:
+		dataPanel, err = httpMethod.NewDataPanel(twin.Property.PushMethod.MethodConfig)
+	case common.PushMethodMQTT:
+		dataPanel, err = mqttMethod.NewDataPanel(twin.Property.PushMethod.MethodConfig)
+	default:
+		err = errors.New("custom protocols are not currently supported when push data")
+	}
+	if err != nil {
+		klog.Errorf("new data panel error: %v", err)
+		return
+	}
+	// initialization PushMethod
+	err = dataPanel.InitPushMethod()
+	if err != nil {
+		klog.Errorf("init publish method err: %v", err)
+		return
+	}
+	reportCycle := time.Millisecond * time.Duration(twin.Property.ReportCycle)
+	if reportCycle == 0 {
+		reportCycle = common.DefaultReportCycle
+	}
+	ticker := time.NewTicker(reportCycle)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				deviceData, err := client.GetDeviceData(visitorConfig)
+				if err != nil {
+					klog.Errorf("publish error: %v", err)
+					continue
+				}
+				sData, err := common.ConvertToString(deviceData)
+				if err != nil {
+					klog.Errorf("Failed to convert publish method data : %v", err)
+					continue
+				}
+				dataModel.SetValue(sData)
+				dataModel.SetTimeStamp()
+				dataPanel.Push(dataModel)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// dbHandler start db client to save data
+func dbHandler(ctx context.Context, twin *common.Twin, client *driver.CustomizedClient, visitorConfig *driver.VisitorConfig, dataModel *common.DataModel) {
+	switch twin.Property.PushMethod.DBMethod.DBMethodName {
+	// TODO add more database
+	case "influx":
+		dbInflux.DataHandler(ctx, twin, client, visitorConfig, dataModel)
+
+	case "redis":
+		dbRedis.DataHandler(ctx, twin, client, visitorConfig, dataModel)
+
+	case "tdengine":
+		dbTdengine.DataHandler(ctx, twin, client, visitorConfig, dataModel)
+
+	case "mysql":
+		dbMysql.DataHandler(ctx, twin, client, visitorConfig, dataModel)
+	}
+}
+
+// desiredLookup returns a function reading the current desired value of a twin, which
+// UpdateDev replaces in place when only the twins change.
+func desiredLookup(deviceID, propertyName string, initial common.TwinProperty) func() common.TwinProperty {
+	return func() common.TwinProperty {
+		if devPanel == nil {
+			return initial
+		}
+		devPanel.serviceMutex.Lock()
+		defer devPanel.serviceMutex.Unlock()
+		dev, ok := devPanel.devices[deviceID]
+		if !ok {
+			return initial
+		}
+		for _, twin := range dev.Instance.Twins {
+			if twin.PropertyName == propertyName {
+				return twin.ObservedDesired
+			}
+		}
+		return initial
+	}
+}
+
+// collectCycle returns how often the twin of a property is collected: the visitor's
+// collectInterval if set, else the model's collectCycle. Zero means the default,
+// common.DefaultCollectCycle, which TwinData.Run applies.
+func collectCycle(twin *common.Twin, visitorConfig *driver.VisitorConfig) (time.Duration, error) {
+	if s := visitorConfig.VisitorConfigData.CollectInterval; s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid collectInterval %q", s)
+		}
+		return d, nil
+	}
+	return time.Millisecond * time.Duration(twin.Property.CollectCycle), nil
+}
+
+// disabledProperties lists the properties whose visitor config sets "enabled": false.
+func disabledProperties(inst *common.DeviceInstance) []string {
+	var names []string
+	for _, property := range inst.Properties {
+		var visitorConfig driver.VisitorConfig
+		if err := json.Unmarshal(property.Visitors, &visitorConfig); err != nil {
+			continue
+		}
+		if !visitorConfig.VisitorConfigData.IsEnabled() {
+			names = append(names, property.PropertyName)
+		}
+	}
+	return names
+}
+
+// convertValue converts s to dataType, or to the type value.Infer finds when
+// dataType is empty and the visitor sets InferType.
+func convertValue(dataType string, visitorConfig *driver.VisitorConfig, s string) (interface{}, error) {
+	if dataType == "" && visitorConfig.VisitorConfigData.InferType {
+		v, _ := value.Infer(s)
+		return v, nil
+	}
+	v, err := value.ToType(dataType, s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", driver.ErrConversion, err)
+	}
+	return v, nil
+}
+
+// setVisitor check if visitor property is readonly, if not then set it.
+func setVisitor(visitorConfig *driver.VisitorConfig, twin *common.Twin, dev *driver.CustomizedDev) error {
+	if twin.Property.PProperty.AccessMode == "ReadOnly" {
+		klog.V(3).Infof("%s twin readonly property: %s", dev.Instance.Name, twin.PropertyName)
+		return nil
+	}
+	klog.V(2).Infof("Convert type: %s, value: %s ", twin.Property.PProperty.DataType, twin.ObservedDesired.Value)
+	var data interface{}
+	if twin.ObservedDesired.Value != "" {
+		convertedValue, err := convertValue(twin.Property.PProperty.DataType, visitorConfig, twin.ObservedDesired.Value)
+		if err != nil {
+			klog.Errorf("Failed to convert value as %s : %v", twin.Property.PProperty.DataType, err)
+			return err
+		}
+		data = convertedValue
+	} else {
+		data = twin.ObservedDesired.Value
+	}
+	err := dev.CustomizedClient.SetDeviceData(data, visitorConfig)
+	if err != nil {
+		return fmt.Errorf("%s set device data error: %v", twin.PropertyName, err)
+	}
+	return nil
+}
+
+// DevInit initialize the device
+func (d *DevPanel) DevInit(deviceList []*dmiapi.Device, deviceModelList []*dmiapi.DeviceModel) error {
+	if len(deviceList) == 0 || len(deviceModelList) == 0 {
+		return ErrEmptyData
+	}
+
+	for i := range deviceModelList {
+		model := deviceModelList[i]
+		cur := parse.GetDeviceModelFromGrpc(model)
+		modelID := parse.GetResourceID(model.Namespace, model.Name)
+		d.models[modelID] = cur
+	}
+
+	for i := range deviceList {
+		device := deviceList[i]
+		modelID := parse.GetResourceID(device.Namespace, device.Spec.DeviceModelReference)
+		commonModel := d.models[modelID]
+		protocol, err := parse.BuildProtocolFromGrpc(device)
+		if err != nil {
+			return err
+		}
+		instance, err := parse.GetDeviceFromGrpc(device, &commonModel)
+		if err != nil {
+			return err
+		}
+		instance.PProtocol = protocol
+
+		cur := new(driver.CustomizedDev)
+		cur.Instance = *instance
+		d.devices[instance.ID] = cur
+	}
+
+	return nil
+}
+
+// UpdateDev stop old device only if protocol config changed; otherwise update twins in place.
+func (d *DevPanel) UpdateDev(model *common.DeviceModel, newDev *common.DeviceInstance) {
+	klog.Infof("UpdateDevice")
+	klog.Infof("model: %+v", model)
+
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+
+	id := newDev.ID
+	old, ok := d.devices[id]
+	if !ok {
+		// New device, init and start
+		klog.Infof("Device %s not found, initializing new device", id)
+		d.devices[id] = &driver.CustomizedDev{
+			Instance:         *newDev, // Instance is a value type
+			CustomizedClient: nil,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		d.deviceMuxs[id] = cancel
+		d.wg.Add(1)
+		go d.start(ctx, d.devices[id])
+		return
+	}
+
+	// Decide whether to restart based on protocol config diffs
+	changed := protocolConfigChanged(&old.Instance, newDev)
+	sameProps := slices.Equal(disabledProperties(&old.Instance), disabledProperties(newDev))
+	if changed && sameProps && applyProtocolConfig(old, newDev) {
+		klog.Infof("Protocol config of %s applied without restart", id)
+		old.Instance.PProtocol = newDev.PProtocol
+		changed = false
+	}
+	if changed || !sameProps {
+		klog.Infof("Protocol config or enabled properties changed for %s, restarting device", id)
+
+		// Stop old client and goroutines
+		if old.CustomizedClient != nil {
+			if err := old.CustomizedClient.StopDevice(); err != nil {
+				klog.Errorf("Failed to stop device %s: %v", id, err)
+			}
+		}
+		// Cancel old context
+		if cancel, ok := d.deviceMuxs[id]; ok {
+			cancel()
+			delete(d.deviceMuxs, id)
+		}
+
+		// Replace instance, start again
+		old.Instance = *newDev
+		ctx, cancel := context.WithCancel(context.Background())
+		d.deviceMuxs[id] = cancel
+		d.wg.Add(1)
+		go d.start(ctx, old)
+		return
+	}
+
+	// No protocol change: keep client, just update instance fields (twins, cycles, metadata)
+	klog.Infof("No protocol change for %s, skipping restart. Updating twins/status only.", id)
+	old.Instance.Twins = newDev.Twins
+	old.Instance.Status = newDev.Status
+	old.Instance.Name = newDev.Name
+	old.Instance.Namespace = newDev.Namespace
+	old.Instance.ID = newDev.ID
+}
+
+// protocolConfigChanged compares only the protocol-critical config to decide if a restart is needed.
+func protocolConfigChanged(oldInst, newInst *common.DeviceInstance) bool {
+	// Compare protocol name
+	if oldInst.PProtocol.ProtocolName != newInst.PProtocol.ProtocolName {
+		return true
+	}
+	// Compare raw protocol config bytes (JSON blobs)
+	return !bytes.Equal(oldInst.PProtocol.ConfigData, newInst.PProtocol.ConfigData)
+}
+
+// applyProtocolConfig hands a changed protocol config to the running client of dev
+// with UpdateConfig. It returns false when the device has to be restarted instead.
+func applyProtocolConfig(dev *driver.CustomizedDev, newInst *common.DeviceInstance) bool {
+	if dev.CustomizedClient == nil || dev.Instance.PProtocol.ProtocolName != newInst.PProtocol.ProtocolName {
+		return false
+	}
+	var cfg driver.ProtocolConfig
+	if err := json.Unmarshal(newInst.PProtocol.ConfigData, &cfg); err != nil {
+		return false
+	}
+	if err := dev.CustomizedClient.UpdateConfig(cfg); err != nil {
+		klog.Infof("Protocol config of %s not applied in place: %v", dev.Instance.ID, err)
+		return false
+	}
+	return true
+}
+
+// UpdateDev stop old device, then update and start new device
+/*func (d *DevPanel) UpdateDev(model *common.DeviceModel, device *common.DeviceInstance) {
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+
+	if oldDevice, ok := d.devices[device.ID]; ok {
+		err := d.stopDev(oldDevice, device.ID)
+		if err != nil {
+			klog.Error(err)
+		}
+	}
+	// start new device
+	d.devices[device.ID] = new(driver.CustomizedDev)
+	d.devices[device.ID].Instance = *device
+	d.models[model.ID] = *model
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	d.deviceMuxs[device.ID] = cancelFunc
+	d.wg.Add(1)
+	go d.start(ctx, d.devices[device.ID])
+}*/
+
+// UpdateDevTwins update device's twins
+func (d *DevPanel) UpdateDevTwins(deviceID string, twins []common.Twin) error {
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	dev, ok := d.devices[deviceID]
+	if !ok {
+		return fmt.Errorf("device %s not found", deviceID)
+	}
+	dev.Instance.Twins = twins
+	model := d.models[dev.Instance.Model]
+	d.UpdateDev(&model, &dev.Instance)
+
+	return nil
+}
+
+// DealDeviceTwinGet get device's twin data
+func (d *DevPanel) DealDeviceTwinGet(deviceID string, twinName string) (interface{}, error) {
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	dev, ok := d.devices[deviceID]
+	if !ok {
+		return nil, fmt.Errorf("not found device %s", deviceID)
+	}
+	var res []parse.TwinResultResponse
+	for _, twin := range dev.Instance.Twins {
+		if twinName != "" && twin.PropertyName != twinName {
+			continue
+		}
+		payload, err := getTwinData(deviceID, twin, d.devices[deviceID])
+		if err != nil {
+			return nil, err
+		}
+		item := parse.TwinResultResponse{
+			PropertyName: twinName,
+			Payload:      payload,
+		}
+		res = append(res, item)
+	}
+	return json.Marshal(res)
+}
+
+// getTwinData get twin
+func getTwinData(deviceID string, twin common.Twin, dev *driver.CustomizedDev) ([]byte, error) {
+	var visitorConfig driver.VisitorConfig
+	err := json.Unmarshal(twin.Property.Visitors, &visitorConfig)
+	if err != nil {
+		return nil, err
+	}
+	err = setVisitor(&visitorConfig, &twin, dev)
+	if err != nil {
+		return nil, err
+	}
+	twinData := &TwinData{
+		DeviceName:    deviceID,
+		Client:        dev.CustomizedClient,
+		Name:          twin.PropertyName,
+		Type:          twin.ObservedDesired.Metadata.Type,
+		VisitorConfig: &visitorConfig,
+		Topic:         fmt.Sprintf(common.TopicTwinUpdate, deviceID),
+	}
+	return twinData.GetPayLoad()
+}
+
+// GetDevice get device instance
+func (d *DevPanel) GetDevice(deviceID string) (interface{}, error) {
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	found, ok := d.devices[deviceID]
+	if !ok || found == nil {
+		return nil, fmt.Errorf("device %s not found", deviceID)
+	}
+
+	// get the latest reported twin value
+	for i, twin := range found.Instance.Twins {
+		payload, err := getTwinData(deviceID, twin, found)
+		if err != nil {
+			return nil, err
+		}
+		found.Instance.Twins[i].Reported.Value = string(payload)
+	}
+	return found, nil
+}
+
+// RemoveDevice remove device instance
+func (d *DevPanel) RemoveDevice(deviceID string) error {
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	dev := d.devices[deviceID]
+	delete(d.devices, deviceID)
+	err := d.stopDev(dev, deviceID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteDevice write value to the device
+func (d *DevPanel) WriteDevice(deviceMethodName, deviceID, propertyName, data string) error {
+	var dataType string
+	var deviceproperty common.DeviceProperty
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	dev, ok := d.devices[deviceID]
+	if !ok {
+		return fmt.Errorf("not found device %s", deviceID)
+	}
+
+	deviceMethodMap := make(map[string][]string)
+
+	// get all deviceMethod of the device
+	for _, method := range dev.Instance.Methods {
+		deviceMethodMap[method.Name] = append(deviceMethodMap[method.Name], method.PropertyNames...)
+	}
+	// Determine whether the called device method exists
+	propertyNames, ok := deviceMethodMap[deviceMethodName]
+	if !ok {
+		return fmt.Errorf("deviceMethod name %s does not exist in device instance", deviceMethodName)
+	}
+	// Determine whether the device property to be written is in the list defined by the device method
+	flag := false
+	for _, name := range propertyNames {
+		if name == propertyName {
+			flag = true
+			break
+		}
+	}
+	if !flag {
+		return fmt.Errorf("deviceProperty %s to be written is not in the list defined by devicemethod", propertyName)
+	}
+	// Determine whether the device property to be written is in the device instance
+	flag = false
+	for _, property := range dev.Instance.Properties {
+		if property.PropertyName != propertyName {
+			continue
+		}
+		dataType = property.PProperty.DataType
+		deviceproperty = property
+		flag = true
+		break
+	}
+	if !flag {
+		return fmt.Errorf("can't find device propertyName %s in device instance", propertyName)
+	}
+	klog.V(2).Infof("start writing values %v to device %s property %s", data, deviceID, propertyName)
+	var visitorConfig driver.VisitorConfig
+	err := json.Unmarshal(deviceproperty.Visitors, &visitorConfig)
+	if err != nil {
+		return err
+	}
+	writeData, err := convertValue(strings.ToLower(dataType), &visitorConfig, data)
+	if err != nil {
+		return fmt.Errorf("conversion data format failed, datatype is %s, data is %s", strings.ToLower(dataType), data)
+	}
+
+	err = dev.CustomizedClient.DeviceDataWrite(&visitorConfig, deviceMethodName, propertyName, writeData)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// stopDev stop device and goroutine
+func (d *DevPanel) stopDev(dev *driver.CustomizedDev, id string) error {
+	cancelFunc, ok := d.deviceMuxs[id]
+	if !ok {
+		return fmt.Errorf("can not find device %s from device muxs", id)
+	}
+
+	err := dev.CustomizedClient.StopDevice()
+	if err != nil {
+		klog.Errorf("stop device %s error: %v", id, err)
+	}
+	cancelFunc()
+	return nil
+}
+
+// GetModel if the model exists, return device model
+func (d *DevPanel) GetModel(modelID string) (common.DeviceModel, error) {
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	if model, ok := d.models[modelID]; ok {
+		return model, nil
+	}
+	return common.DeviceModel{}, fmt.Errorf("deviceModel %s not found", modelID)
+}
+
+// UpdateModel update device model
+func (d *DevPanel) UpdateModel(model *common.DeviceModel) {
+	d.serviceMutex.Lock()
+	d.models[model.ID] = *model
+	d.serviceMutex.Unlock()
+}
+
+// RemoveModel remove device model
+func (d *DevPanel) RemoveModel(modelID string) {
+	d.serviceMutex.Lock()
+	delete(d.models, modelID)
+	d.serviceMutex.Unlock()
+}
+
+// GetTwinResult Get twin's value and data type
+func (d *DevPanel) GetTwinResult(deviceID string, twinName string) (string, string, error) {
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	dev, ok := d.devices[deviceID]
+	if !ok {
+		return "", "", fmt.Errorf("not found device %s", deviceID)
+	}
+	var res string
+	var dataType string
+	for _, twin := range dev.Instance.Twins {
+		if twinName != "" && twin.PropertyName != twinName {
+			continue
+		}
+		var visitorConfig driver.VisitorConfig
+		err := json.Unmarshal(twin.Property.Visitors, &visitorConfig)
+		if err != nil {
+			return "", "", err
+		}
+		err = setVisitor(&visitorConfig, &twin, dev)
+
+		data, err := dev.CustomizedClient.GetDeviceData(&visitorConfig)
+		if err != nil {
+			return "", "", fmt.Errorf("get device data failed: %v", err)
+		}
+		res = value.Stringify(data)
+		dataType = twin.Property.PProperty.DataType
+	}
+	return res, dataType, nil
+}
+
+// GetDeviceMethod get method and property dataType of device
+func (d *DevPanel) GetDeviceMethod(deviceID string) (map[string][]string, map[string]string, error) {
+	klog.V(2).Infof("starting get method and property dataType of device %s", deviceID)
+	d.serviceMutex.Lock()
+	defer d.serviceMutex.Unlock()
+	found, ok := d.devices[deviceID]
+	if !ok || found == nil {
+		return nil, nil, fmt.Errorf("device %s not found", deviceID)
+	}
+
+	deviceMethodMap := make(map[string][]string)
+	propertyTypeMap := make(map[string]string)
+
+	// get all deviceMethod of the device
+	for _, method := range found.Instance.Methods {
+		deviceMethodMap[method.Name] = append(deviceMethodMap[method.Name], method.PropertyNames...)
+	}
+
+	// get all deviceProperty type of the device
+	for _, property := range found.Instance.Properties {
+		propertyTypeMap[property.Name] = strings.ToLower(property.PProperty.DataType) // The original data type is an uppercase form such as INT FLOAT and needs to be converted.
+	}
+	return deviceMethodMap, propertyTypeMap, nil
+}
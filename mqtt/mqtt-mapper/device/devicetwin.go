@@ -1,127 +1,314 @@
-package device
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"strings"
-	"time"
-
-	"k8s.io/klog/v2"
-
-	"github.com/kubeedge/mqtt/driver"
-	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
-	"github.com/kubeedge/mapper-framework/pkg/common"
-	"github.com/kubeedge/mapper-framework/pkg/grpcclient"
-	"github.com/kubeedge/mapper-framework/pkg/util/parse"
-)
-
-type TwinData struct {
-	DeviceName      string
-	DeviceNamespace string
-	Client          *driver.CustomizedClient
-	Name            string
-	Type            string
-	ObservedDesired common.TwinProperty
-	VisitorConfig   *driver.VisitorConfig
-	Topic           string
-	Results         interface{}
-	CollectCycle    time.Duration
-	ReportToCloud   bool
-}
-
-func (td *TwinData) GetPayLoad() ([]byte, error) {
-	var err error
-	td.VisitorConfig.VisitorConfigData.DataType = strings.ToLower(td.VisitorConfig.VisitorConfigData.DataType)
-	
-	klog.V(2).Infof("GetPayLoad calling GetDeviceData for property %s", td.Name)
-	td.Results, err = td.Client.GetDeviceData(td.VisitorConfig)
-	if err != nil {
-		return nil, fmt.Errorf("get device data failed: %v", err)
-	}
-	
-	klog.V(2).Infof("GetDeviceData returned for property %s: %v", td.Name, td.Results)
-	
-	sData, err := common.ConvertToString(td.Results)
-	if err != nil {
-		klog.Errorf("Failed to convert %s %s value as string : %v", td.DeviceName, td.Name, err)
-		return nil, err
-	}
-	if len(sData) > 30 {
-		klog.V(4).Infof("Get %s : %s ,value is %s......", td.DeviceName, td.Name, sData[:30])
-	} else {
-		klog.V(2).Infof("Get %s : %s ,value is %s", td.DeviceName, td.Name, sData)
-	}
-	var payload []byte
-	if strings.Contains(td.Topic, "$hw") {
-		if payload, err = common.CreateMessageTwinUpdate(td.Name, td.Type, sData, td.ObservedDesired.Value); err != nil {
-			return nil, fmt.Errorf("create message twin update failed: %v", err)
-		}
-	} else {
-		if payload, err = common.CreateMessageData(td.Name, td.Type, sData); err != nil {
-			return nil, fmt.Errorf("create message data failed: %v", err)
-		}
-	}
-	return payload, nil
-}
-
-func (td *TwinData) PushToEdgeCore() {
-	klog.V(2).Infof("PushToEdgeCore called for property %s", td.Name)
-	payload, err := td.GetPayLoad()
-	if err != nil {
-		klog.Errorf("twindata %s getPayLoad failed, err: %s", td.Name, err)
-		return
-	}
-
-	klog.V(2).Infof("Generated payload for property %s: %s", td.Name, string(payload))
-
-	var msg common.DeviceTwinUpdate
-	if err = json.Unmarshal(payload, &msg); err != nil {
-		klog.Errorf("twindata %s unmarshal failed, err: %s", td.Name, err)
-		return
-	}
-
-	twins := parse.ConvMsgTwinToGrpc(msg.Twin)
-
-	var rdsr = &dmiapi.ReportDeviceStatusRequest{
-		DeviceName:      td.DeviceName,
-		DeviceNamespace: td.DeviceNamespace,
-		ReportedDevice: &dmiapi.DeviceStatus{
-			Twins: twins,
-			//State: "OK",
-		},
-	}
-
-	klog.Infof("Reporting device status for %s/%s property %s with value: %v", td.DeviceNamespace, td.DeviceName, td.Name, msg.Twin)
-	if err := grpcclient.ReportDeviceStatus(rdsr); err != nil {
-		klog.Errorf("fail to report device status of %s with err: %+v", rdsr.DeviceName, err)
-	} else {
-		klog.V(2).Infof("Successfully reported device status for %s property %s", td.DeviceName, td.Name)
-	}
-}
-
-func (td *TwinData) Run(ctx context.Context) {
-	klog.Infof("TwinData.Run starting for property %s, ReportToCloud: %v, CollectCycle: %v", td.Name, td.ReportToCloud, td.CollectCycle)
-	
-	if !td.ReportToCloud {
-		klog.Infof("TwinData.Run exiting early - ReportToCloud is false for property %s", td.Name)
-		return
-	}
-	if td.CollectCycle == 0 {
-		td.CollectCycle = common.DefaultCollectCycle
-		klog.Infof("TwinData.Run using default CollectCycle %v for property %s", td.CollectCycle, td.Name)
-	}
-	
-	klog.Infof("TwinData.Run starting ticker with cycle %v for property %s", td.CollectCycle, td.Name)
-	ticker := time.NewTicker(td.CollectCycle)
-	for {
-		select {
-		case <-ticker.C:
-			klog.V(3).Infof("TwinData.Run ticker fired for property %s, calling PushToEdgeCore", td.Name)
-			td.PushToEdgeCore()
-		case <-ctx.Done():
-			klog.Infof("TwinData.Run context cancelled for property %s", td.Name)
-			return
-		}
-	}
-}
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mqtt/driver"
+	"github.com/kubeedge/mapper-common/pkg/report"
+	"github.com/kubeedge/mapper-common/pkg/value"
+	dmiapi "github.com/kubeedge/api/apis/dmi/v1beta1"
+	"github.com/kubeedge/mapper-framework/pkg/common"
+	"github.com/kubeedge/mapper-framework/pkg/grpcclient"
+	"github.com/kubeedge/mapper-framework/pkg/util/parse"
+)
+
+type TwinData struct {
+	DeviceName      string
+	DeviceNamespace string
+	Client          *driver.CustomizedClient
+	Name            string
+	Type            string
+	ObservedDesired common.TwinProperty
+	// Desired returns the current desired value when set, so changes made after
+	// the twin was started are picked up. ObservedDesired is used otherwise.
+	Desired         func() common.TwinProperty
+	VisitorConfig   *driver.VisitorConfig
+	Topic           string
+	Results         interface{}
+	CollectCycle    time.Duration
+	ReportToCloud   bool
+	// last numeric value reported, the reference for Deadband
+	lastReported    *float64
+	// DeviceNameTemplate and NamespaceOverride file reports under another device,
+	// see report.Target. Empty values report under DeviceName/DeviceNamespace.
+	DeviceNameTemplate string
+	NamespaceOverride  string
+	// Reporter receives the twin reports instead of EdgeCore when set.
+	Reporter report.Reporter
+	// ReportOnStart pushes once as soon as Run starts instead of waiting a full
+	// CollectCycle, so the cloud twin fills in right after a mapper restart.
+	ReportOnStart bool
+}
+
+// edgeCoreReporter reports to EdgeCore, the default Reporter.
+var edgeCoreReporter report.Reporter = report.ReporterFunc(grpcclient.ReportDeviceStatus)
+
+// reporter returns the Reporter of the twin, EdgeCore by default.
+func (td *TwinData) reporter() report.Reporter {
+	if td.Reporter != nil {
+		return td.Reporter
+	}
+	return edgeCoreReporter
+}
+
+func (td *TwinData) GetPayLoad() ([]byte, error) {
+	return td.getPayload(context.Background())
+}
+
+// getPayload is GetPayLoad with the read traced as a child of the span in ctx.
+func (td *TwinData) getPayload(ctx context.Context) ([]byte, error) {
+	var err error
+	td.VisitorConfig.VisitorConfigData.DataType = strings.ToLower(td.VisitorConfig.VisitorConfigData.DataType)
+	
+	td.Client.V(driver.LogReport, 2).Infof("GetPayLoad calling GetDeviceData for property %s", td.Name)
+	td.Results, err = td.readValue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get device data failed: %v", err)
+	}
+	
+	td.Client.V(driver.LogReport, 2).Infof("GetDeviceData returned for property %s: %v", td.Name, td.Results)
+	
+	return td.payloadFor(ctx, td.Results)
+}
+
+// payloadFor builds the twin message carrying v, traced as a child of the span in ctx.
+func (td *TwinData) payloadFor(ctx context.Context, v interface{}) ([]byte, error) {
+	var err error
+	_, span := td.startSpan(ctx, "convert")
+	defer span.End()
+	sData := value.Stringify(v)
+	if len(sData) > 30 {
+		td.Client.V(driver.LogReport, 4).Infof("Get %s : %s ,value is %s......", td.DeviceName, td.Name, sData[:30])
+	} else {
+		td.Client.V(driver.LogReport, 2).Infof("Get %s : %s ,value is %s", td.DeviceName, td.Name, sData)
+	}
+	var payload []byte
+	if strings.Contains(td.Topic, "$hw") {
+		if payload, err = common.CreateMessageTwinUpdate(td.Name, td.Type, sData, td.ObservedDesired.Value); err != nil {
+			return nil, fmt.Errorf("create message twin update failed: %v", err)
+		}
+	} else {
+		if payload, err = common.CreateMessageData(td.Name, td.Type, sData); err != nil {
+			return nil, fmt.Errorf("create message data failed: %v", err)
+		}
+	}
+	return payload, nil
+}
+
+// readValue reads the value of the twin: its own property, or with Aggregate the
+// listed properties assembled into one JSON object keyed by property name. The
+// driver reads are traced as children of the span in ctx.
+func (td *TwinData) readValue(ctx context.Context) (interface{}, error) {
+	props := td.VisitorConfig.VisitorConfigData.Aggregate
+	if len(props) == 0 {
+		return td.Client.GetDeviceDataContext(ctx, td.VisitorConfig)
+	}
+	obj := make(map[string]interface{}, len(props))
+	for _, prop := range props {
+		v, err := td.Client.GetPropertyContext(ctx, prop)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate %s: %v", prop, err)
+		}
+		obj[prop] = v
+	}
+	return obj, nil
+}
+
+// PushToEdgeCore reads the twin and reports it. The collect, convert and report
+// steps are traced under one span, a child of the span in ctx.
+func (td *TwinData) PushToEdgeCore(ctx context.Context) {
+	td.Client.V(driver.LogReport, 2).Infof("PushToEdgeCore called for property %s", td.Name)
+	ctx, span := td.startSpan(ctx, "PushToEdgeCore")
+	payload, err := td.getPayload(ctx)
+	if err != nil {
+		endSpan(span, err)
+		klog.Errorf("twindata %s getPayLoad failed, err: %s", td.Name, err)
+		return
+	}
+	endSpan(span, td.report(ctx, payload))
+}
+
+// pushValue reports v, a value of the twin already read, like PushToEdgeCore.
+func (td *TwinData) pushValue(ctx context.Context, v interface{}) {
+	ctx, span := td.startSpan(ctx, "PushToEdgeCore")
+	td.Results = v
+	payload, err := td.payloadFor(ctx, v)
+	if err != nil {
+		endSpan(span, err)
+		klog.Errorf("twindata %s build payload failed, err: %s", td.Name, err)
+		return
+	}
+	endSpan(span, td.report(ctx, payload))
+}
+
+// PushTransitionsToEdgeCore reports every value buffered by the driver since the last
+// cycle, oldest first, and falls back to the current value when nothing changed.
+func (td *TwinData) PushTransitionsToEdgeCore(ctx context.Context) {
+	values := td.Client.DrainTransitions(td.VisitorConfig.VisitorConfigData.PropertyName)
+	if len(values) == 0 {
+		td.PushToEdgeCore(ctx)
+		return
+	}
+	td.Client.V(driver.LogReport, 2).Infof("Reporting %d buffered transitions for property %s", len(values), td.Name)
+	td.reportTransitions(ctx, values)
+}
+
+// reportTransitions converts and reports each of values in order. They are not
+// coalesced when throttled, so every transition reaches edgecore.
+func (td *TwinData) reportTransitions(ctx context.Context, values []interface{}) {
+	for _, v := range values {
+		v = td.Client.ConvertValue(td.VisitorConfig, v)
+		td.Results = v
+		spanCtx, span := td.startSpan(ctx, "PushToEdgeCore")
+		payload, err := td.payloadFor(spanCtx, v)
+		if err != nil {
+			endSpan(span, err)
+			klog.Errorf("twindata %s build payload failed, err: %s", td.Name, err)
+			continue
+		}
+		endSpan(span, td.send(spanCtx, payload, report.Append))
+	}
+}
+
+// syncDesired writes the desired value to the device when it differs from the value
+// last reported. The driver does not publish the same desired value twice.
+func (td *TwinData) syncDesired() {
+	desired := td.ObservedDesired
+	if td.Desired != nil {
+		desired = td.Desired()
+	}
+	if desired.Value == "" || td.Results == nil {
+		return
+	}
+	if value.Stringify(td.Results) == desired.Value {
+		return
+	}
+	v, err := value.ToType(desired.Metadata.Type, desired.Value)
+	if err != nil {
+		klog.Errorf("twindata %s convert desired value %s as %s failed, err: %s", td.Name, desired.Value, desired.Metadata.Type, err)
+		return
+	}
+	if err := td.Client.SetDeviceData(v, td.VisitorConfig); err != nil {
+		klog.ErrorS(err, "Failed to write desired value", "device", td.DeviceName, "property", td.Name, "value", desired.Value)
+	}
+}
+
+// report sends one twin payload to edgecore, traced as a child of the span in
+// ctx, and returns why it could not. A throttled report replaces the one queued
+// for the same property.
+func (td *TwinData) report(ctx context.Context, payload []byte) error {
+	return td.send(ctx, payload, report.Submit)
+}
+
+// send is report with the throttled reports queued by submit.
+func (td *TwinData) send(ctx context.Context, payload []byte, submit func(string, *dmiapi.ReportDeviceStatusRequest, report.Reporter) (bool, error)) error {
+	var err error
+	if td.withinDeadband(td.Results) {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("deadband", true))
+		return nil
+	}
+	td.Client.V(driver.LogReport, 2).Infof("Generated payload for property %s: %s", td.Name, string(payload))
+
+	var msg common.DeviceTwinUpdate
+	if err = json.Unmarshal(payload, &msg); err != nil {
+		klog.Errorf("twindata %s unmarshal failed, err: %s", td.Name, err)
+		return err
+	}
+
+	twins := parse.ConvMsgTwinToGrpc(msg.Twin)
+
+	deviceName, namespace := report.Target(td.DeviceName, td.DeviceNamespace, td.Name, td.DeviceNameTemplate, td.NamespaceOverride)
+	var rdsr = &dmiapi.ReportDeviceStatusRequest{
+		DeviceName:      deviceName,
+		DeviceNamespace: namespace,
+		ReportedDevice: &dmiapi.DeviceStatus{
+			Twins: twins,
+			//State: "OK",
+		},
+	}
+
+	td.Client.V(driver.LogReport, 0).InfoS("Reporting twin", "device", deviceName, "namespace", namespace, "property", td.Name, "value", msg.Twin)
+	// the mirror is best effort and does not depend on the report reaching edgecore
+	if err := td.Client.Mirror(td.Name, td.Results); err != nil {
+		klog.ErrorS(err, "Failed to mirror twin", "device", td.DeviceName, "property", td.Name)
+	}
+	key := namespace + "/" + deviceName + "/" + td.Name
+	_, reportSpan := td.startSpan(ctx, "ReportDeviceStatus")
+	queued, err := submit(key, rdsr, td.reporter())
+	endSpan(reportSpan, err)
+	switch {
+	case err != nil:
+		klog.ErrorS(err, "Failed to report twin", "device", deviceName, "namespace", namespace, "property", td.Name)
+	case queued:
+		td.Client.V(driver.LogReport, 2).Infof("Report of device status for %s property %s throttled, queued", deviceName, td.Name)
+	default:
+		td.Client.V(driver.LogReport, 2).Infof("Successfully reported device status for %s property %s", deviceName, td.Name)
+	}
+	return err
+}
+
+func (td *TwinData) Run(ctx context.Context) {
+	td.Client.V(driver.LogReport, 0).Infof("TwinData.Run starting for property %s, ReportToCloud: %v, CollectCycle: %v", td.Name, td.ReportToCloud, td.CollectCycle)
+	
+	if !td.ReportToCloud {
+		td.Client.V(driver.LogReport, 0).Infof("TwinData.Run exiting early - ReportToCloud is false for property %s", td.Name)
+		return
+	}
+	if td.CollectCycle == 0 {
+		td.CollectCycle = common.DefaultCollectCycle
+		td.Client.V(driver.LogReport, 0).Infof("TwinData.Run using default CollectCycle %v for property %s", td.CollectCycle, td.Name)
+	}
+	
+	td.Client.V(driver.LogReport, 0).Infof("TwinData.Run starting ticker with cycle %v for property %s", td.CollectCycle, td.Name)
+	ticker := time.NewTicker(td.CollectCycle)
+	if td.ReportOnStart {
+		td.pushOnStart(ctx)
+	}
+	for {
+		select {
+		case <-ticker.C:
+			td.Client.V(driver.LogReport, 3).Infof("TwinData.Run ticker fired for property %s, calling PushToEdgeCore", td.Name)
+			if td.VisitorConfig.VisitorConfigData.ReportTransitions {
+				td.PushTransitionsToEdgeCore(ctx)
+			} else {
+				td.PushToEdgeCore(ctx)
+			}
+			td.syncDesired()
+		case <-ctx.Done():
+			td.Client.V(driver.LogReport, 0).Infof("TwinData.Run context cancelled for property %s", td.Name)
+			return
+		}
+	}
+}
+
+// reportOnStartRetry is how often Run retries the first read for ReportOnStart
+// while the property cannot be read yet, e.g. before the connection is up.
+const reportOnStartRetry = time.Second
+
+// pushOnStart makes the first report of ReportOnStart as soon as a quick read of
+// the property succeeds, retrying every reportOnStartRetry until the first
+// collect cycle is due.
+func (td *TwinData) pushOnStart(ctx context.Context) {
+	due := time.After(td.CollectCycle)
+	for {
+		if v, err := td.readValue(ctx); err == nil {
+			td.pushValue(ctx, v)
+			return
+		}
+		select {
+		case <-time.After(reportOnStartRetry):
+		case <-due:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
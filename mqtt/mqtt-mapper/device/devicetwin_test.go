@@ -0,0 +1,114 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubeedge/mapper-common/pkg/report"
+	"github.com/kubeedge/mapper-common/pkg/report/reporttest"
+
+	"github.com/kubeedge/mqtt/driver"
+)
+
+// newTestTwin returns a string twin of property prop reporting to r.
+func newTestTwin(t *testing.T, prop string, r report.Reporter) *TwinData {
+	t.Helper()
+	c, err := driver.NewClient(driver.ProtocolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TwinData{
+		DeviceName:      "camera",
+		DeviceNamespace: "default",
+		Client:          c,
+		Name:            prop,
+		Type:            "string",
+		VisitorConfig:   &driver.VisitorConfig{VisitorConfigData: driver.VisitorConfigData{PropertyName: prop}},
+		Topic:           "$hw/events/device/camera/twin/update",
+		Reporter:        r,
+	}
+}
+
+// reportValue reports v as the value of td.
+func reportValue(t *testing.T, td *TwinData, v string) {
+	t.Helper()
+	td.Results = v
+	payload, err := td.payloadFor(context.Background(), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := td.report(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setRateLimit limits the twin reports for the test.
+func setRateLimit(t *testing.T, rate float64, burst int) {
+	t.Helper()
+	if err := report.SetRateLimit(rate, burst); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = report.SetRateLimit(0, 1) })
+}
+
+func TestReporter(t *testing.T) {
+	r := &reporttest.Recorder{}
+	td := newTestTwin(t, "class", r)
+	td.DeviceNameTemplate = "{deviceName}-{property}"
+	reportValue(t, td, "person")
+
+	reqs := r.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("%d requests, want 1", len(reqs))
+	}
+	if reqs[0].DeviceName != "camera-class" || reqs[0].DeviceNamespace != "default" {
+		t.Errorf("reported to %s/%s, want default/camera-class", reqs[0].DeviceNamespace, reqs[0].DeviceName)
+	}
+	if got := fmt.Sprint(r.Twins()); got != "[class=person]" {
+		t.Errorf("reported %s, want [class=person]", got)
+	}
+}
+
+// TestReporterThrottled checks that twin reports beyond the burst are queued,
+// that a queued report is replaced by a newer one of the same property and that
+// the queue is flushed oldest property first.
+func TestReporterThrottled(t *testing.T) {
+	setRateLimit(t, 5, 1)
+	r := &reporttest.Recorder{}
+	class, motion := newTestTwin(t, "class", r), newTestTwin(t, "motion", r)
+
+	reportValue(t, class, "person")
+	reportValue(t, class, "car")
+	reportValue(t, motion, "true")
+	reportValue(t, class, "dog")
+	if got := fmt.Sprint(r.Twins()); got != "[class=person]" {
+		t.Fatalf("sent %s before the flush, want [class=person]", got)
+	}
+
+	want := "[class=person class=dog motion=true]"
+	if got := fmt.Sprint(r.WaitTwins(t, 3)); got != want {
+		t.Fatalf("sent %s, want %s", got, want)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := fmt.Sprint(r.Twins()); got != want {
+		t.Fatalf("sent %s after the flush, want %s", got, want)
+	}
+}
+
+// TestReportTransitionsThrottled checks that throttled transitions are not
+// coalesced: every one of them is reported, in order, after being converted.
+func TestReportTransitionsThrottled(t *testing.T) {
+	setRateLimit(t, 1, 1)
+	r := &reporttest.Recorder{}
+	td := newTestTwin(t, "class", r)
+	td.VisitorConfig.VisitorConfigData.ValueMap = map[string]string{"0": "person"}
+
+	td.reportTransitions(context.Background(), []interface{}{"0", "car", "dog"})
+
+	want := "[class=person class=car class=dog]"
+	if got := fmt.Sprint(r.WaitTwins(t, 3)); got != want {
+		t.Fatalf("sent %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/klog/v2"
+)
+
+// WriteBatch publishes several property values as one JSON object to BatchTopic, e.g.
+// {"class": "person", "motion": true}. The returned map holds an error for every
+// property that was not applied and is nil when all were. Unknown or disabled
+// properties are rejected individually; a failed publish fails all the others.
+// With QoS 1 and 2 the broker acknowledgment is awaited.
+func (c *CustomizedClient) WriteBatch(values map[string]interface{}) map[string]error {
+	errs := make(map[string]error)
+	failAll := func(err error) map[string]error {
+		for prop := range values {
+			if _, ok := errs[prop]; !ok {
+				errs[prop] = err
+			}
+		}
+		return errs
+	}
+	c.deviceMutex.Lock()
+	topic, qos := c.ProtocolConfig.BatchTopic, byte(c.ProtocolConfig.QoS)
+	client := c.mqttClient
+	c.deviceMutex.Unlock()
+	if topic == "" {
+		return failAll(fmt.Errorf("no batchTopic configured"))
+	}
+
+	doc := make(map[string]interface{}, len(values))
+	for prop, v := range values {
+		switch {
+		case prop != "motion" && prop != "last_detection" && prop != "class":
+			errs[prop] = c.propertyError(ErrUnknownProperty, prop, nil)
+		case !c.propertyEnabled(prop):
+			errs[prop] = fmt.Errorf("property %s is disabled", prop)
+		default:
+			doc[prop] = v
+		}
+	}
+	if len(doc) == 0 {
+		return errs
+	}
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return failAll(fmt.Errorf("marshal batch: %v", err))
+	}
+	if client == nil || !client.IsConnected() {
+		return failAll(ErrNotConnected)
+	}
+	token := client.Publish(topic, qos, false, payload)
+	if qos > 0 && token.Wait() && token.Error() != nil {
+		return failAll(fmt.Errorf("publish batch to %s: %v", topic, token.Error()))
+	}
+	klog.V(2).Infof("MQTT batch write %s published to %s", payload, topic)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
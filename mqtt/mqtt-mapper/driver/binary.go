@@ -0,0 +1,25 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// decodePayload turns a raw payload into the value of prop: the integer it encodes
+// when the registered visitor sets a BinaryType, the value its Codec decodes when
+// it sets one, the normalized text otherwise. Caller must hold deviceMutex.
+func (c *CustomizedClient) decodePayload(prop string, payload []byte) (string, error) {
+	cfg := c.visitors[prop].VisitorConfigData
+	if cfg.Codec != "" {
+		return c.decodeCodec(cfg, payload)
+	}
+	if cfg.BinaryType == "" {
+		return c.normalizers.Apply(prop, string(payload)), nil
+	}
+	n, err := value.DecodeBinary(cfg.BinaryType, cfg.Endianness, payload)
+	if err != nil {
+		return "", fmt.Errorf("decode binary payload: %v", err)
+	}
+	return value.Stringify(n), nil
+}
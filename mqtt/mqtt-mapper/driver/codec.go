@@ -0,0 +1,40 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/kubeedge/mapper-common/pkg/codec"
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// decodeCodec decodes payload with the Codec of cfg.
+func (c *CustomizedClient) decodeCodec(cfg VisitorConfigData, payload []byte) (string, error) {
+	cd, err := codec.Lookup(cfg.Codec)
+	if err != nil {
+		return "", err
+	}
+	v, err := cd.Decode(payload, cfg.DataType)
+	if err != nil {
+		return "", err
+	}
+	return value.Stringify(v), nil
+}
+
+// encodePayload turns data into the payload published for prop: encoded by the
+// Codec of the registered visitor, or as plain text without one.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) encodePayload(prop string, data interface{}) (string, error) {
+	cfg := c.visitors[prop].VisitorConfigData
+	if cfg.Codec == "" {
+		return value.Stringify(data), nil
+	}
+	cd, err := codec.Lookup(cfg.Codec)
+	if err != nil {
+		return "", err
+	}
+	payload, err := cd.Encode(data, cfg.DataType)
+	if err != nil {
+		return "", fmt.Errorf("encode with codec %s: %v", cfg.Codec, err)
+	}
+	return string(payload), nil
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// maxDedupWindow caps DedupWindow. Deduplication is meant to absorb the retained
+// copy, the live message and QoS 1 redeliveries arriving back to back; a longer
+// window would swallow a genuine re-detection with the same payload.
+const maxDedupWindow = 5 * time.Second
+
+// lastMessage is the hash and arrival of the last message handled for a property.
+type lastMessage struct {
+	hash uint64
+	at   time.Time
+}
+
+// parseDedupWindow validates DedupWindow. Unset disables deduplication.
+func (c *CustomizedClient) parseDedupWindow() error {
+	if c.ProtocolConfig.DedupWindow == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(c.ProtocolConfig.DedupWindow)
+	if err != nil || d <= 0 || d > maxDedupWindow {
+		return fmt.Errorf("invalid dedupWindow %q, must be a duration up to %v", c.ProtocolConfig.DedupWindow, maxDedupWindow)
+	}
+	c.dedupWindow = d
+	return nil
+}
+
+// duplicate reports whether payload repeats the last message handled for prop
+// within the dedup window. The window runs from that handled message, so a
+// stream of identical payloads is still handled once per window.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) duplicate(prop string, payload []byte) bool {
+	if c.dedupWindow == 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write(payload)
+	sum, now := h.Sum64(), time.Now()
+	if last, ok := c.lastMessages[prop]; ok && last.hash == sum && now.Sub(last.at) < c.dedupWindow {
+		c.V(LogObserve, 2).InfoS("MQTT duplicate message skipped", "property", prop)
+		return true
+	}
+	if c.lastMessages == nil {
+		c.lastMessages = make(map[string]lastMessage)
+	}
+	c.lastMessages[prop] = lastMessage{hash: sum, at: now}
+	return false
+}
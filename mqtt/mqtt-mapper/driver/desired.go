@@ -0,0 +1,97 @@
+package driver
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+)
+
+// writeTopic returns the topic values of the visited property are published to:
+// the desiredTopic of the visitor, else the WriteTopic of a property added with
+// AddProperty. It is empty for a read-only property.
+func (c *CustomizedClient) writeTopic(visitor *VisitorConfig) string {
+	if topic := visitor.VisitorConfigData.DesiredTopic; topic != "" {
+		return topic
+	}
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return c.dynamic[visitor.VisitorConfigData.PropertyName].WriteTopic
+}
+
+// publishDesired sends the desired value of a property to its desiredTopic.
+// A payload equal to the last one published for the property is not sent again.
+func (c *CustomizedClient) publishDesired(prop, topic string, data interface{}) error {
+	c.deviceMutex.Lock()
+	payload, err := c.encodePayload(prop, data)
+	if err != nil {
+		c.deviceMutex.Unlock()
+		return c.propertyError(ErrConversion, prop, err)
+	}
+	if last, ok := c.desired[prop]; ok && last == payload {
+		c.deviceMutex.Unlock()
+		return nil
+	}
+	client := c.mqttClient
+	qos := byte(c.ProtocolConfig.QoS)
+	c.deviceMutex.Unlock()
+
+	if c.ProtocolConfig.Simulate {
+		klog.V(2).Infof("Simulation mode, not publishing desired %s=%s to %s", prop, payload, topic)
+	} else {
+		if client == nil || !client.IsConnected() {
+			return c.propertyError(ErrNotConnected, prop, fmt.Errorf("cannot publish desired value"))
+		}
+		token := client.Publish(topic, qos, false, payload)
+		if token.Wait() && token.Error() != nil {
+			return fmt.Errorf("publish desired %s to %s: %v", prop, topic, token.Error())
+		}
+		klog.InfoS("MQTT desired value published", "topic", topic, "property", prop, "value", payload)
+	}
+
+	c.deviceMutex.Lock()
+	c.desired[prop] = payload
+	c.deviceMutex.Unlock()
+	return nil
+}
+
+// WriteResult is the broker's acknowledgment of a write.
+type WriteResult struct {
+	// Acknowledged is true when the publish used QoS 1 or 2 and the broker confirmed it.
+	// QoS 0 publishes are fire-and-forget and never acknowledged.
+	Acknowledged bool
+}
+
+// WriteProperty publishes data to the write topic of the visited property (see
+// writeTopic) and, for QoS 1 and 2, waits for the broker to acknowledge it.
+func (c *CustomizedClient) WriteProperty(visitor *VisitorConfig, data interface{}) (WriteResult, error) {
+	prop := visitor.VisitorConfigData.PropertyName
+	topic := c.writeTopic(visitor)
+	if topic == "" {
+		return WriteResult{}, fmt.Errorf("property %s is read-only: no desiredTopic or writeTopic configured", prop)
+	}
+	topic, err := c.publishTopic(topic, prop)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	c.deviceMutex.Lock()
+	payload, err := c.encodePayload(prop, data)
+	client := c.mqttClient
+	qos := byte(c.ProtocolConfig.QoS)
+	c.deviceMutex.Unlock()
+	if err != nil {
+		return WriteResult{}, c.propertyError(ErrConversion, prop, err)
+	}
+	if client == nil || !client.IsConnected() {
+		return WriteResult{}, c.propertyError(ErrNotConnected, prop, nil)
+	}
+
+	token := client.Publish(topic, qos, false, payload)
+	if qos == 0 {
+		return WriteResult{}, nil
+	}
+	if token.Wait() && token.Error() != nil {
+		return WriteResult{}, fmt.Errorf("publish %s to %s: %v", prop, topic, token.Error())
+	}
+	klog.V(2).Infof("MQTT write %s=%s acknowledged on %s", prop, payload, topic)
+	return WriteResult{Acknowledged: true}, nil
+}
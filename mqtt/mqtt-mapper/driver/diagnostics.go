@@ -0,0 +1,173 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// Diagnostics is a snapshot of the client's connection and subscription state.
+type Diagnostics struct {
+	Connected bool
+	// Subscriptions maps every subscribed topic to "active" or the subscribe error.
+	Subscriptions map[string]string
+	// FailedTopics lists the topics whose subscription is not active, sorted.
+	FailedTopics []string
+	// Broker holds the latest $SYS values, e.g. "$SYS/broker/uptime": "3600 seconds",
+	// when MonitorSysTopics is set. BrokerUpdated is when one last changed; a stale
+	// value while Connected points at a restarting or overloaded broker.
+	Broker        map[string]string
+	BrokerUpdated time.Time
+	// LastDisconnect is why the last session ended or the last connect failed,
+	// zero if neither happened.
+	LastDisconnect DisconnectReason
+	// Paused lists the properties paused with PauseProperty, sorted.
+	Paused []string
+	// Messages on the property topics: received by a handler, processed by it,
+	// and in flight in between, e.g. waiting for the device lock or a blocking
+	// subscriber. A growing MessagesInFlight means the mapper cannot keep up;
+	// MessagesReceived standing still while Connected means the broker is silent.
+	MessagesReceived  uint64
+	MessagesProcessed uint64
+	MessagesInFlight  uint64
+	// MessagesOversized counts the messages dropped for exceeding MaxPayloadBytes.
+	// They are included in MessagesReceived and MessagesProcessed.
+	MessagesOversized uint64
+}
+
+// counted wraps a property message handler to maintain the message counters
+// reported by Diagnostics and to drop oversized messages before the handler.
+func (c *CustomizedClient) counted(handler mqtt.MessageHandler) mqtt.MessageHandler {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		c.messagesReceived.Add(1)
+		defer c.messagesProcessed.Add(1)
+		if c.oversized(msg) {
+			return
+		}
+		handler(client, msg)
+	}
+}
+
+// setSubscription records the outcome of subscribing to topic. Caller must not hold deviceMutex.
+func (c *CustomizedClient) setSubscription(topic string, err error) {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	c.subscriptions[topic] = err
+}
+
+// requiredTopics lists the topics of the enabled properties that are not paused.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) requiredTopics() []string {
+	var topics []string
+	for prop, topic := range map[string]string{
+		"motion":         c.ProtocolConfig.MotionTopic,
+		"last_detection": c.ProtocolConfig.LastDetectionTopic,
+		"class":          c.ProtocolConfig.ClassTopic,
+	} {
+		if c.propertyEnabled(prop) && !c.paused[prop] {
+			topics = append(topics, topic)
+		}
+	}
+	for _, sub := range c.dynamicSubs() {
+		if !c.paused[sub.prop] {
+			topics = append(topics, sub.topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// failedTopics returns the required topics without an active subscription.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) failedTopics() []string {
+	var failed []string
+	for _, topic := range c.requiredTopics() {
+		if err, ok := c.subscriptions[topic]; !ok || err != nil {
+			failed = append(failed, topic)
+		}
+	}
+	return failed
+}
+
+// Diagnostics reports which subscriptions are active, to tell a broker-side
+// rejection (e.g. an ACL denying a topic) apart from a lost connection.
+func (c *CustomizedClient) Diagnostics() Diagnostics {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	d := Diagnostics{
+		Connected:      c.isConnected,
+		Subscriptions:  make(map[string]string, len(c.subscriptions)),
+		LastDisconnect: c.lastDisconnect,
+		Paused:         c.pausedProperties(),
+	}
+	// processed first: a message counted there is already counted as received
+	d.MessagesProcessed = c.messagesProcessed.Load()
+	d.MessagesReceived = c.messagesReceived.Load()
+	d.MessagesInFlight = d.MessagesReceived - d.MessagesProcessed
+	d.MessagesOversized = c.messagesOversized.Load()
+	for topic, err := range c.subscriptions {
+		if err != nil {
+			d.Subscriptions[topic] = err.Error()
+		} else {
+			d.Subscriptions[topic] = "active"
+		}
+	}
+	if !c.ProtocolConfig.Simulate {
+		d.FailedTopics = c.failedTopics()
+	}
+	if c.ProtocolConfig.MonitorSysTopics {
+		d.Broker = make(map[string]string, len(c.brokerStats))
+		for topic, v := range c.brokerStats {
+			d.Broker[topic] = v
+		}
+		d.BrokerUpdated = c.brokerStatsAt
+	}
+	return d
+}
+
+// subscribeFailure is the SUBACK return code of a refused subscription.
+const subscribeFailure = 0x80
+
+// subscribeError returns the error of a completed subscribe token, including a
+// subscription the broker refused in its SUBACK, which paho does not report as an error.
+func subscribeError(token mqtt.Token, topic string) error {
+	if err := token.Error(); err != nil {
+		return err
+	}
+	if st, ok := token.(*mqtt.SubscribeToken); ok {
+		if code, ok := st.Result()[topic]; ok && code == subscribeFailure {
+			return fmt.Errorf("broker refused subscription to %s", topic)
+		}
+	}
+	return nil
+}
+
+// subscribe subscribes to the topic of prop, unless the property is disabled or
+// paused, and records the outcome for Diagnostics and GetDeviceStates.
+func (c *CustomizedClient) subscribe(client mqtt.Client, prop, topic string, handler mqtt.MessageHandler) error {
+	if !c.propertyEnabled(prop) {
+		c.V(LogObserve, 0).Infof("Property %s is disabled, not subscribing to %s", prop, topic)
+		return nil
+	}
+	c.deviceMutex.Lock()
+	paused := c.paused[prop]
+	qos := c.subscribeQoS(prop)
+	c.deviceMutex.Unlock()
+	if paused {
+		c.V(LogObserve, 0).Infof("Property %s is paused, not subscribing to %s", prop, topic)
+		return nil
+	}
+	token := client.Subscribe(topic, qos, handler)
+	token.Wait()
+	err := subscribeError(token, topic)
+	c.setSubscription(topic, err)
+	if err != nil {
+		klog.Errorf("Failed to subscribe to %s topic: %v", prop, err)
+		return fmt.Errorf("subscribe %s: %v", topic, err)
+	}
+	c.V(LogObserve, 0).Infof("Successfully subscribed to %s topic: %s", prop, topic)
+	return nil
+}
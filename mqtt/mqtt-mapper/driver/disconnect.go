@@ -0,0 +1,48 @@
+package driver
+
+import (
+	"strings"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// DisconnectReason tells why the client last lost, or failed to get, its broker session.
+//
+// paho v1.2.0 speaks MQTT 3.1.1, where the broker cannot send a DISCONNECT with a
+// reason code: a session it ends shows up as a lost connection, and a rejected
+// CONNECT as a CONNACK return code.
+type DisconnectReason struct {
+	Reason string
+	Time   time.Time
+	// Refused is set when the broker rejected the CONNECT (bad credentials, not
+	// authorized, ...) rather than the connection dropping. Retrying does not help
+	// until the configuration changes.
+	Refused bool
+}
+
+// refusedCodes are the CONNACK return codes a retry with the same configuration
+// gets again. ErrRefusedServerUnavailable is transient and not listed.
+var refusedCodes = []byte{
+	packets.ErrRefusedBadProtocolVersion,
+	packets.ErrRefusedIDRejected,
+	packets.ErrRefusedBadUsernameOrPassword,
+	packets.ErrRefusedNotAuthorised,
+}
+
+// isRefused reports whether err is a CONNACK refusal from refusedCodes. paho
+// returns the packets.ConnErrors value, sometimes followed by a detail.
+func isRefused(err error) bool {
+	for _, rc := range refusedCodes {
+		if strings.HasPrefix(err.Error(), packets.ConnErrors[rc].Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDisconnect stores err as the last disconnect reason. Caller must hold deviceMutex.
+func (c *CustomizedClient) recordDisconnect(err error) DisconnectReason {
+	c.lastDisconnect = DisconnectReason{Reason: err.Error(), Time: time.Now(), Refused: isRefused(err)}
+	return c.lastDisconnect
+}
@@ -1,221 +1,559 @@
-package driver
-
-import (
-	"strings"
-        "fmt"
-        "sync"
-        "time"
-        mqtt "github.com/eclipse/paho.mqtt.golang"
-        "k8s.io/klog/v2"
-        "github.com/kubeedge/mapper-framework/pkg/common"
-)
-
-func NewClient(protocol ProtocolConfig) (*CustomizedClient, error) {
-        client := &CustomizedClient{
-                ProtocolConfig: protocol,
-                deviceMutex:    sync.Mutex{},
-                motionStatus:   false,
-                isConnected:    false,
-        }
-        return client, nil
-}
-
-func (c *CustomizedClient) InitDevice() error {
-    klog.Infof("Initializing motion detection device with broker: %s",
-        c.ProtocolConfig.BrokerURL)
-
-    // Validate required configuration
-    if c.ProtocolConfig.BrokerURL == "" {
-        return fmt.Errorf("brokerURL is required in protocol config")
-    }
-
-    // Defaults
-    if c.ProtocolConfig.ClientID == "" {
-        c.ProtocolConfig.ClientID = fmt.Sprintf("motion-mapper-%d", time.Now().Unix())
-    }
-    if c.ProtocolConfig.MotionTopic == "" {
-        return fmt.Errorf("Motion topic is required in protocol config")
-    }
-    if c.ProtocolConfig.LastDetectionTopic == "" {
-	return fmt.Errorf("Last Detection topic is required in protocol config")
-    }
-    if c.ProtocolConfig.ClassTopic == "" {
-        return fmt.Errorf("Class topic is required in protocol config")
-    }
-    // MQTT client options
-    opts := mqtt.NewClientOptions()
-    opts.AddBroker(c.ProtocolConfig.BrokerURL)
-    opts.SetClientID(c.ProtocolConfig.ClientID)
-    opts.SetCleanSession(true)
-    opts.SetAutoReconnect(true)
-    opts.SetKeepAlive(30 * time.Second)
-    opts.SetPingTimeout(10 * time.Second)
-    opts.SetConnectTimeout(30 * time.Second)
-    opts.SetMaxReconnectInterval(5 * time.Second)
-
-    if c.ProtocolConfig.Username != "" {
-        opts.SetUsername(c.ProtocolConfig.Username)
-    }
-    if c.ProtocolConfig.Password != "" {
-        opts.SetPassword(c.ProtocolConfig.Password)
-    }
-
-    // Handlers
-    opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
-        klog.Errorf("MQTT connection lost: %v", err)
-        c.deviceMutex.Lock()
-        c.isConnected = false
-        c.deviceMutex.Unlock()
-    })
-
-    opts.SetOnConnectHandler(func(client mqtt.Client) {
-        klog.Infof("MQTT connected successfully")
-        c.deviceMutex.Lock()
-        c.isConnected = true
-        c.deviceMutex.Unlock()
-
-        qos := byte(c.ProtocolConfig.QoS)
-        if token := client.Subscribe(c.ProtocolConfig.MotionTopic, qos, c.onMotionMessage); token.Wait() && token.Error() != nil {
-            klog.Errorf("Failed to subscribe to motion topic: %v", token.Error())
-        } else {
-            klog.Infof("Successfully subscribed to motion topic: %s", c.ProtocolConfig.MotionTopic)
-        }
-
-        if token := client.Subscribe(c.ProtocolConfig.LastDetectionTopic, qos, c.onLastDetectionMessage); token.Wait() && token.Error() != nil {
-            klog.Errorf("Failed to subscribe to motion topic: %v", token.Error())
-        } else {
-            klog.Infof("Successfully subscribed to last detection topic: %s", c.ProtocolConfig.LastDetectionTopic)
-        }
-
-	if token := client.Subscribe(c.ProtocolConfig.ClassTopic, qos, c.onClassMessage); token.Wait() && token.Error() != nil {
-	    klog.Errorf("Failed to subscribe to motion topic: %v", token.Error())
-	} else {
-	    klog.Infof("successfully subscribed to class topic: %s", c.ProtocolConfig.ClassTopic)
-	}
-
-    })
-
-    // Connect
-    c.mqttClient = mqtt.NewClient(opts)
-    if token := c.mqttClient.Connect(); token.Wait() && token.Error() != nil {
-        return fmt.Errorf("failed to connect to MQTT broker: %v", token.Error())
-    }
-
-
-    klog.Infof("Motion detection device initialized successfully with status: %s", c.motionStatus)
-    return nil
-}
-
-func (c *CustomizedClient) GetDeviceData(visitor *VisitorConfig) (interface{}, error) {
-        c.deviceMutex.Lock()
-        defer c.deviceMutex.Unlock()
-        
-        klog.V(2).Infof("GetDeviceData called for property: %s", visitor.VisitorConfigData.PropertyName)
-        
-        switch visitor.VisitorConfigData.PropertyName {
-        case "motion":
-                return c.motionStatus, nil
-	case "last_detection":
-		return c.lastDetection, nil
-	case "class":
-		return c.classLabel, nil
-        default:
-                return nil, fmt.Errorf("unknown property: %s", visitor.VisitorConfigData.PropertyName)
-        }
-}
-
-func (c *CustomizedClient) DeviceDataWrite(visitor *VisitorConfig, deviceMethodName string, propertyName string, data interface{}) error {
-        // Motion detection is typically read-only, but we can implement this for completeness
-        klog.V(3).Infof("DeviceDataWrite called for property: %s with data: %v", propertyName, data)
-        return nil
-}
-
-func (c *CustomizedClient) SetDeviceData(data interface{}, visitor *VisitorConfig) error {
-        // Motion detection is typically read-only from the device perspective
-        klog.V(3).Infof("SetDeviceData called with data: %v", data)
-        return nil
-}
-
-func (c *CustomizedClient) StopDevice() error {
-        klog.Infof("Stopping motion detection device")
-        
-        c.deviceMutex.Lock()
-        defer c.deviceMutex.Unlock()
-        
-        if c.mqttClient != nil && c.mqttClient.IsConnected() {
-                // Unsubscribe from motion topic
-                if token := c.mqttClient.Unsubscribe(c.ConfigData.MotionTopic); token.Wait() && token.Error() != nil {
-                        klog.Errorf("Failed to unsubscribe from motion topic: %v", token.Error())
-                }
-                
-                // Disconnect MQTT client
-                c.mqttClient.Disconnect(250)
-                klog.Infof("MQTT client disconnected")
-        }
-        
-        c.isConnected = false
-        return nil
-}
-
-func (c *CustomizedClient) GetDeviceStates() (string, error) {
-        c.deviceMutex.Lock()
-        defer c.deviceMutex.Unlock()
-        
-        if c.isConnected && c.mqttClient != nil && c.mqttClient.IsConnected() {
-                return common.DeviceStatusOK, nil
-        }
-        return common.DeviceStatusDisCONN, nil
-}
-
-// MQTT message callback for motion detection
-func (c *CustomizedClient) onMotionMessage(client mqtt.Client, msg mqtt.Message) {
-        klog.V(2).Infof("Motion message received on topic %s: %s", msg.Topic(), string(msg.Payload()))
-        
-        c.deviceMutex.Lock()
-        defer c.deviceMutex.Unlock()
-        
-        // Update motion status based on message content
-        oldStatus := c.motionStatus
-        c.motionStatus = strings.TrimSpace(string(msg.Payload())) == "true"
-        
-        if oldStatus != c.motionStatus {
-                klog.Infof("Motion status changed from '%s' to '%s' - twin will be updated on next collection cycle", oldStatus, c.motionStatus)
-        } else {
-                klog.V(2).Infof("Motion status unchanged: '%s'", c.motionStatus)
-        }
-}
-
-func (c *CustomizedClient) onLastDetectionMessage(client mqtt.Client, msg mqtt.Message) {
-        klog.V(2).Infof("Motion message received on topic %s: %s", msg.Topic(), string(msg.Payload()))
-        
-        c.deviceMutex.Lock()
-        defer c.deviceMutex.Unlock()
-        
-        // Update last detection status based on message content
-        oldStatus := c.lastDetection
-        c.lastDetection = strings.TrimSpace(string(msg.Payload()))
-        
-        if oldStatus != c.lastDetection {
-                klog.Infof("Last detectionn status changed from '%s' to '%s' - twin will be updated on next collection cycle", oldStatus, c.lastDetection)
-        } else {
-                klog.V(2).Infof("Last detection status unchanged: '%s'", c.lastDetection)
-        }
-}
-
-
-func (c *CustomizedClient) onClassMessage(client mqtt.Client, msg mqtt.Message) {
-        klog.V(2).Infof("Motion message received on topic %s: %s", msg.Topic(), string(msg.Payload()))
-        
-        c.deviceMutex.Lock()
-        defer c.deviceMutex.Unlock()
-        
-        // Update Class status based on message content
-        oldStatus := c.classLabel
-        c.classLabel  = strings.TrimSpace(string(msg.Payload()))
-        
-        if oldStatus != c.classLabel {
-                klog.Infof("Class status changed from '%s' to '%s' - twin will be updated on next collection cycle", oldStatus, c.classLabel)
-        } else {
-                klog.V(2).Infof("Class status unchanged: '%s'", c.classLabel)
-        }
-}
+package driver
+
+import (
+	"context"
+        "fmt"
+        "sync"
+        "time"
+        mqtt "github.com/eclipse/paho.mqtt.golang"
+        "k8s.io/klog/v2"
+        "github.com/kubeedge/mapper-framework/pkg/common"
+        "github.com/kubeedge/mapper-common/pkg/events"
+        "github.com/kubeedge/mapper-common/pkg/state"
+        "github.com/kubeedge/mapper-common/pkg/value"
+)
+
+const (
+        // defaultSubscribeTimeout bounds how long InitDevice waits for the initial subscriptions.
+        defaultSubscribeTimeout = 10 * time.Second
+        // defaultShutdownTimeout is the quiesce period StopDevice gives paho's Disconnect.
+        defaultShutdownTimeout = 250 * time.Millisecond
+)
+
+func NewClient(protocol ProtocolConfig) (*CustomizedClient, error) {
+        client := &CustomizedClient{
+                ProtocolConfig: protocol,
+                deviceMutex:    sync.Mutex{},
+                motionStatus:   false,
+                isConnected:    false,
+                transitions:    make(map[string]*transitionBuffer),
+                desired:        make(map[string]string),
+                subscriptions:  make(map[string]error),
+                brokerStats:    make(map[string]string),
+                received:       make(map[string]bool),
+                boolTokens:     value.DefaultBoolTokens,
+        }
+        return client, nil
+}
+
+func (c *CustomizedClient) InitDevice() error {
+    klog.Infof("Initializing motion detection device with broker: %s",
+        c.ProtocolConfig.BrokerURL)
+    c.spec = cloneConfig(c.ProtocolConfig)
+
+    if c.ProtocolConfig.ShutdownTimeout != "" {
+        d, err := time.ParseDuration(c.ProtocolConfig.ShutdownTimeout)
+        if err != nil || d <= 0 {
+            return fmt.Errorf("invalid shutdownTimeout %q", c.ProtocolConfig.ShutdownTimeout)
+        }
+        c.shutdownTimeout = d
+    }
+    if err := c.validateMirror(); err != nil {
+        return err
+    }
+    if err := c.validateParseErrorModes(); err != nil {
+        return err
+    }
+    if err := events.Validate(c.ProtocolConfig.EventBuffer, c.ProtocolConfig.EventOverflow); err != nil {
+        return err
+    }
+    if err := c.validateLogLevels(); err != nil {
+        return err
+    }
+    c.setLogLevels(c.ProtocolConfig.LogLevels)
+    if err := state.ValidateMapping(c.ProtocolConfig.StateMapping); err != nil {
+        return err
+    }
+    if err := c.parseBoolTokens(); err != nil {
+        return err
+    }
+    if err := c.parseWatchdog(); err != nil {
+        return err
+    }
+    if err := c.parseDedupWindow(); err != nil {
+        return err
+    }
+    if err := c.parseMaxPayload(); err != nil {
+        return err
+    }
+
+    if c.ProtocolConfig.Simulate {
+        interval, err := simulateInterval(c.ProtocolConfig.SimulateInterval)
+        if err != nil {
+            return err
+        }
+        ctx, cancel := context.WithCancel(context.Background())
+        c.cancel = cancel
+        klog.Infof("Motion detection device running in simulation mode, interval=%v", interval)
+        go c.runSimulation(ctx, interval)
+        return nil
+    }
+
+    // Validate required configuration
+    if c.ProtocolConfig.BrokerURL == "" {
+        return fmt.Errorf("brokerURL is required in protocol config")
+    }
+
+    // Defaults
+    cleanSession := c.ProtocolConfig.CleanSession == nil || *c.ProtocolConfig.CleanSession
+    if c.ProtocolConfig.ClientID == "" {
+        c.ProtocolConfig.ClientID = fmt.Sprintf("motion-mapper-%d", time.Now().Unix())
+        if !cleanSession {
+            klog.Warningf("cleanSession=false with a generated clientID %s: the broker session will not be resumed after a restart", c.ProtocolConfig.ClientID)
+        }
+    }
+    if err := c.parseTopics(); err != nil {
+        return err
+    }
+    if err := c.parseNormalizers(); err != nil {
+        return err
+    }
+    if err := c.validateQoS(); err != nil {
+        return err
+    }
+    if err := c.validatePresence(); err != nil {
+        return err
+    }
+    if err := c.validateSnapshot(); err != nil {
+        return err
+    }
+    if err := c.parseProxy(); err != nil {
+        return err
+    }
+    // MQTT client options
+    opts := mqtt.NewClientOptions()
+    broker := c.ProtocolConfig.BrokerURL
+    if c.proxyURL != nil {
+        var err error
+        if broker, err = c.startProxyTunnel(opts); err != nil {
+            return err
+        }
+    }
+    opts.AddBroker(broker)
+    opts.SetClientID(c.ProtocolConfig.ClientID)
+    opts.SetCleanSession(cleanSession)
+    if !cleanSession {
+        opts.SetResumeSubs(true)
+    }
+    if c.ProtocolConfig.StoreDir != "" {
+        store, err := newFileStore(c.ProtocolConfig.StoreDir)
+        if err != nil {
+            return err
+        }
+        opts.SetStore(store)
+    }
+    opts.SetAutoReconnect(true)
+    opts.SetKeepAlive(30 * time.Second)
+    opts.SetPingTimeout(10 * time.Second)
+    opts.SetConnectTimeout(30 * time.Second)
+    opts.SetMaxReconnectInterval(5 * time.Second)
+    if c.ProtocolConfig.OrderMatters != nil {
+        opts.SetOrderMatters(*c.ProtocolConfig.OrderMatters)
+    }
+    if c.ProtocolConfig.MessageChannelDepth > 0 {
+        opts.SetMessageChannelDepth(c.ProtocolConfig.MessageChannelDepth)
+    }
+
+    if err := applyExtraOptions(opts, c.ProtocolConfig.ExtraOptions); err != nil {
+        return err
+    }
+
+    if c.ProtocolConfig.Username != "" {
+        opts.SetUsername(c.ProtocolConfig.Username)
+    }
+    if c.ProtocolConfig.Password != "" {
+        opts.SetPassword(c.ProtocolConfig.Password)
+    }
+    c.setWill(opts)
+
+    subscribeTimeout := defaultSubscribeTimeout
+    if c.ProtocolConfig.SubscribeTimeout != "" {
+        d, err := time.ParseDuration(c.ProtocolConfig.SubscribeTimeout)
+        if err != nil || d <= 0 {
+            return fmt.Errorf("invalid subscribeTimeout %q", c.ProtocolConfig.SubscribeTimeout)
+        }
+        subscribeTimeout = d
+    }
+    // result of the first round of subscriptions
+    subscribed := make(chan error, 1)
+
+    // Handlers
+    opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+        klog.ErrorS(err, "MQTT connection lost", "broker", c.ProtocolConfig.BrokerURL)
+        c.deviceMutex.Lock()
+        c.isConnected = false
+        c.recordDisconnect(err)
+        // subscriptions are gone with the connection, OnConnect records them again
+        c.subscriptions = make(map[string]error)
+        c.deviceMutex.Unlock()
+    })
+
+    opts.SetOnConnectHandler(func(client mqtt.Client) {
+        c.V(LogConnection, 0).InfoS("MQTT connected", "broker", c.ProtocolConfig.BrokerURL, "clientID", c.ProtocolConfig.ClientID)
+        c.deviceMutex.Lock()
+        c.isConnected = true
+        c.deviceMutex.Unlock()
+
+        var subErr error
+        c.deviceMutex.Lock()
+        subs := c.allSubs()
+        republish, qos := c.ProtocolConfig.RepublishOnConnect, byte(c.ProtocolConfig.QoS)
+        c.deviceMutex.Unlock()
+        for _, sub := range subs {
+                if err := c.subscribe(client, sub.prop, sub.topic, sub.handler); err != nil {
+                        subErr = err
+                }
+        }
+
+        if c.ProtocolConfig.MonitorSysTopics {
+                c.subscribeSysTopics(client)
+        }
+        if c.watchdogTimeout > 0 {
+                c.subscribeWatchdog(client)
+        }
+        if c.ProtocolConfig.SnapshotTopic != "" {
+                c.warmFromSnapshot(client)
+        }
+        if republish {
+                c.republishSnapshot()
+        }
+        c.publishPresence(client, c.ProtocolConfig.BirthTopic, c.ProtocolConfig.BirthPayloadTemplate, qos, subscribeTimeout)
+
+        // only InitDevice listens, later reconnects find the channel full and move on
+        select {
+        case subscribed <- subErr:
+        default:
+        }
+    })
+
+    // Connect
+    c.mqttClient = mqtt.NewClient(opts)
+    if token := c.mqttClient.Connect(); token.Wait() && token.Error() != nil {
+        c.deviceMutex.Lock()
+        reason := c.recordDisconnect(token.Error())
+        c.deviceMutex.Unlock()
+        c.closeProxyTunnel()
+        if reason.Refused {
+            return fmt.Errorf("MQTT broker refused the connection, fix the configuration: %v", token.Error())
+        }
+        return c.connectError(token.Error())
+    }
+
+    // don't report the device ready before its topics are subscribed
+    select {
+    case err := <-subscribed:
+        if err != nil {
+            c.mqttClient.Disconnect(250)
+            c.closeProxyTunnel()
+            return fmt.Errorf("failed to subscribe: %v", err)
+        }
+    case <-time.After(subscribeTimeout):
+        c.mqttClient.Disconnect(250)
+        c.closeProxyTunnel()
+        return fmt.Errorf("%w: subscriptions not confirmed within %v", ErrTimeout, subscribeTimeout)
+    }
+
+    if c.watchdogTimeout > 0 {
+        ctx, cancel := context.WithCancel(context.Background())
+        c.cancel = cancel
+        go c.runWatchdog(ctx)
+    }
+
+    klog.Infof("Motion detection device initialized successfully with status: %v", c.motionStatus)
+    return nil
+}
+
+func (c *CustomizedClient) GetDeviceData(visitor *VisitorConfig) (interface{}, error) {
+        return c.GetDeviceDataContext(context.Background(), visitor)
+}
+
+// GetDeviceDataContext is GetDeviceData traced as a child of the span in ctx.
+func (c *CustomizedClient) GetDeviceDataContext(ctx context.Context, visitor *VisitorConfig) (v interface{}, err error) {
+        _, span := c.startSpan(ctx, "GetDeviceData", visitor.VisitorConfigData.PropertyName)
+        defer func() { endSpan(span, err) }()
+        c.deviceMutex.Lock()
+        defer c.deviceMutex.Unlock()
+        
+        klog.V(2).Infof("GetDeviceData called for property: %s", visitor.VisitorConfigData.PropertyName)
+        return c.readProperty(visitor)
+}
+
+// readProperty is GetDeviceData without the locking. Caller must hold deviceMutex.
+func (c *CustomizedClient) readProperty(visitor *VisitorConfig) (interface{}, error) {
+        if !c.propertyEnabled(visitor.VisitorConfigData.PropertyName) {
+                return nil, fmt.Errorf("property %s is disabled", visitor.VisitorConfigData.PropertyName)
+        }
+        if err := c.parseErrs[visitor.VisitorConfigData.PropertyName]; err != nil {
+                return nil, c.propertyError(ErrConversion, visitor.VisitorConfigData.PropertyName, err)
+        }
+
+        cfg := visitor.VisitorConfigData
+        switch cfg.PropertyName {
+        case "motion":
+                return c.convertValue(cfg, c.motionStatus), nil
+	case "last_detection":
+		return c.convertValue(cfg, c.lastDetection), nil
+	case "class":
+		return c.convertValue(cfg, c.classLabel), nil
+        default:
+                if _, ok := c.dynamic[cfg.PropertyName]; ok {
+                        return c.convertValue(cfg, c.values[cfg.PropertyName]), nil
+                }
+                return nil, c.propertyError(ErrUnknownProperty, visitor.VisitorConfigData.PropertyName, nil)
+        }
+}
+
+// ConvertValue converts v, a value the device sent for the property of visitor,
+// the way GetDeviceData converts the current value, e.g. for buffered transitions.
+func (c *CustomizedClient) ConvertValue(visitor *VisitorConfig, v interface{}) interface{} {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return c.convertValue(visitor.VisitorConfigData, v)
+}
+
+// convertValue maps and infers the type of v. Values are already decoded and
+// smoothed when received. Caller must hold deviceMutex.
+func (c *CustomizedClient) convertValue(cfg VisitorConfigData, v interface{}) interface{} {
+	return c.inferValue(cfg, cfg.MapValue(v))
+}
+
+func (c *CustomizedClient) DeviceDataWrite(visitor *VisitorConfig, deviceMethodName string, propertyName string, data interface{}) error {
+        klog.V(3).Infof("DeviceDataWrite called for property: %s with data: %v", propertyName, data)
+        _, err := c.WriteProperty(visitor, data)
+        return err
+}
+
+func (c *CustomizedClient) SetDeviceData(data interface{}, visitor *VisitorConfig) error {
+        klog.V(3).Infof("SetDeviceData called with data: %v", data)
+        // Properties without a desiredTopic or writeTopic are read-only from the device perspective
+        topic := c.writeTopic(visitor)
+        if topic == "" || data == nil || data == "" {
+                return nil
+        }
+        topic, err := c.publishTopic(topic, visitor.VisitorConfigData.PropertyName)
+        if err != nil {
+                return err
+        }
+        return c.publishDesired(visitor.VisitorConfigData.PropertyName, topic, data)
+}
+
+func (c *CustomizedClient) StopDevice() error {
+        klog.Infof("Stopping motion detection device")
+        
+        c.deviceMutex.Lock()
+        if c.cancel != nil {
+                c.cancel()
+        }
+        // the network waits below run without deviceMutex, so reads and the
+        // message handlers are not blocked for up to the shutdown timeout
+        client := c.mqttClient
+        motionTopic := c.ConfigData.MotionTopic
+        willTopic, willTmpl, qos := c.ProtocolConfig.WillTopic, c.ProtocolConfig.WillPayloadTemplate, byte(c.ProtocolConfig.QoS)
+        broker := c.ProtocolConfig.BrokerURL
+        timeout := c.shutdownTimeout
+        if timeout == 0 {
+                timeout = defaultShutdownTimeout
+        }
+        c.deviceMutex.Unlock()
+
+        if client != nil && client.IsConnected() {
+                // Unsubscribe from motion topic
+                if token := client.Unsubscribe(motionTopic); !token.WaitTimeout(timeout) {
+                        klog.Errorf("Unsubscribe from motion topic not confirmed within %v", timeout)
+                } else if token.Error() != nil {
+                        klog.Errorf("Failed to unsubscribe from motion topic: %v", token.Error())
+                }
+                
+                // a clean disconnect discards the will, so announce going offline ourselves.
+                // The announcement and the disconnect share one shutdown timeout.
+                deadline := time.Now().Add(timeout)
+                c.publishPresence(client, willTopic, willTmpl, qos, timeout)
+
+                // Disconnect MQTT client, letting in-flight work finish for what is left of the shutdown timeout
+                quiesce := time.Until(deadline)
+                if quiesce < 0 {
+                        quiesce = 0
+                }
+                client.Disconnect(uint(quiesce.Milliseconds()))
+                c.V(LogConnection, 0).InfoS("MQTT client disconnected", "broker", broker)
+        }
+        
+        c.deviceMutex.Lock()
+        c.isConnected = false
+        c.closeProxyTunnel()
+        c.deviceMutex.Unlock()
+        c.events.Close()
+        return nil
+}
+
+// ForceReconnect disconnects from the broker and connects again right away instead of
+// waiting for the keepalive to notice a dead link. It is safe to call concurrently and
+// returns immediately if a forced reconnect is already in progress.
+func (c *CustomizedClient) ForceReconnect() error {
+        c.deviceMutex.Lock()
+        if c.reconnecting || c.mqttClient == nil {
+                c.deviceMutex.Unlock()
+                return nil
+        }
+        // retrying a refused CONNECT only gets refused again
+        if c.lastDisconnect.Refused && !c.isConnected {
+                reason := c.lastDisconnect.Reason
+                c.deviceMutex.Unlock()
+                return fmt.Errorf("not reconnecting, the broker refused the last connection: %s", reason)
+        }
+        c.reconnecting = true
+        client := c.mqttClient
+        c.deviceMutex.Unlock()
+
+        defer func() {
+                c.deviceMutex.Lock()
+                c.reconnecting = false
+                c.deviceMutex.Unlock()
+        }()
+
+        c.V(LogConnection, 0).InfoS("MQTT reconnect requested", "broker", c.ProtocolConfig.BrokerURL)
+        if client.IsConnected() {
+                client.Disconnect(250)
+        }
+        c.deviceMutex.Lock()
+        c.isConnected = false
+        c.deviceMutex.Unlock()
+        // subscriptions are restored by the OnConnect handler
+        if token := client.Connect(); token.Wait() && token.Error() != nil {
+                c.deviceMutex.Lock()
+                c.recordDisconnect(token.Error())
+                c.deviceMutex.Unlock()
+                return fmt.Errorf("failed to reconnect to MQTT broker: %v", token.Error())
+        }
+        return nil
+}
+
+func (c *CustomizedClient) GetDeviceStates() (string, error) {
+        c.deviceMutex.Lock()
+        defer c.deviceMutex.Unlock()
+        
+        if c.ProtocolConfig.Simulate && c.isConnected {
+                return common.DeviceStatusOK, nil
+        }
+        if c.isConnected && c.mqttClient != nil && c.mqttClient.IsConnected() {
+                // connected but receiving nothing on a topic the broker refused
+                if failed := c.failedTopics(); len(failed) > 0 {
+                        c.V(LogConnection, 2).Infof("MQTT subscriptions not active: %v", failed)
+                        return common.DeviceStatusUnhealthy, nil
+                }
+                return common.DeviceStatusOK, nil
+        }
+        if c.lastDisconnect.Reason != "" {
+                c.V(LogConnection, 2).Infof("MQTT disconnected since %s: %s (refused: %v)", c.lastDisconnect.Time.Format(time.RFC3339), c.lastDisconnect.Reason, c.lastDisconnect.Refused)
+        }
+        return common.DeviceStatusDisCONN, nil
+}
+
+// GetDMIState returns the device state as the cloud expects it: the state of
+// GetDeviceStates, unknown when that fails, translated with StateMapping.
+// States without a mapping are reported as they are.
+func (c *CustomizedClient) GetDMIState() string {
+	s, err := c.GetDeviceStates()
+	if err != nil {
+		klog.Errorf("GetDeviceStates failed: %v", err)
+		s = common.DeviceStatusUnknown
+	}
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return state.Translate(c.ProtocolConfig.StateMapping, s)
+}
+
+// MQTT message callback for motion detection
+func (c *CustomizedClient) onMotionMessage(client mqtt.Client, msg mqtt.Message) {
+        c.V(LogObserve, 2).Infof("Motion message received on topic %s: %s", msg.Topic(), string(msg.Payload()))
+        
+        // a change is published once deviceMutex is released, see publishEvent
+        var changed interface{}
+        defer func() {
+                if changed != nil {
+                        c.publishEvent("motion", changed)
+                }
+        }()
+        c.deviceMutex.Lock()
+        defer c.deviceMutex.Unlock()
+        
+        // Update motion status based on message content
+        v, ok := c.parsePayload("motion", msg.Payload())
+        if !ok {
+                return
+        }
+        oldStatus := c.motionStatus
+        c.motionStatus = c.boolTokens.IsTrue(v)
+        
+        if oldStatus != c.motionStatus {
+                c.recordTransition("motion", c.motionStatus)
+                c.recordHistory("motion", c.motionStatus)
+                changed = c.motionStatus
+                c.V(LogObserve, 0).InfoS("MQTT value changed", "topic", msg.Topic(), "property", "motion", "old", oldStatus, "new", c.motionStatus)
+        } else {
+                c.V(LogObserve, 2).Infof("Motion status unchanged: '%v'", c.motionStatus)
+        }
+}
+
+func (c *CustomizedClient) onLastDetectionMessage(client mqtt.Client, msg mqtt.Message) {
+        c.V(LogObserve, 2).Infof("Motion message received on topic %s: %s", msg.Topic(), string(msg.Payload()))
+        
+        // a change is published once deviceMutex is released, see publishEvent
+        var changed interface{}
+        defer func() {
+                if changed != nil {
+                        c.publishEvent("last_detection", changed)
+                }
+        }()
+        c.deviceMutex.Lock()
+        defer c.deviceMutex.Unlock()
+        
+        // Update last detection status based on message content
+        v, ok := c.parsePayload("last_detection", msg.Payload())
+        if !ok {
+                return
+        }
+        oldStatus := c.lastDetection
+        c.lastDetection = v
+        
+        if oldStatus != c.lastDetection {
+                c.recordTransition("last_detection", c.lastDetection)
+                c.recordHistory("last_detection", c.lastDetection)
+                changed = c.lastDetection
+                c.V(LogObserve, 0).InfoS("MQTT value changed", "topic", msg.Topic(), "property", "last_detection", "old", oldStatus, "new", c.lastDetection)
+        } else {
+                c.V(LogObserve, 2).Infof("Last detection status unchanged: '%s'", c.lastDetection)
+        }
+}
+
+
+func (c *CustomizedClient) onClassMessage(client mqtt.Client, msg mqtt.Message) {
+        c.V(LogObserve, 2).Infof("Motion message received on topic %s: %s", msg.Topic(), string(msg.Payload()))
+        
+        // a change is published once deviceMutex is released, see publishEvent
+        var changed interface{}
+        defer func() {
+                if changed != nil {
+                        c.publishEvent("class", changed)
+                }
+        }()
+        c.deviceMutex.Lock()
+        defer c.deviceMutex.Unlock()
+        
+        // Update Class status based on message content
+        v, ok := c.parsePayload("class", msg.Payload())
+        if !ok {
+                return
+        }
+        oldStatus := c.classLabel
+        c.classLabel  = v
+        
+        if oldStatus != c.classLabel {
+                c.recordTransition("class", c.classLabel)
+                c.recordHistory("class", c.classLabel)
+                changed = c.classLabel
+                c.V(LogObserve, 0).InfoS("MQTT value changed", "topic", msg.Topic(), "property", "class", "old", oldStatus, "new", c.classLabel)
+        } else {
+                c.V(LogObserve, 2).Infof("Class status unchanged: '%s'", c.classLabel)
+        }
+}
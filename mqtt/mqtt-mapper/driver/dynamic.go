@@ -0,0 +1,173 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// PropertySpec describes a property added at runtime with AddProperty.
+type PropertySpec struct {
+	Name string
+	// ReadTopic is subscribed to for the property's state, WriteTopic takes the
+	// values written with SetDeviceData, DeviceDataWrite and SetProperty, e.g. the
+	// state and command topics of an actuator. {deviceName}, {namespace} and
+	// {property} are substituted as in topicTemplate; wildcards are allowed in
+	// ReadTopic only. Without WriteTopic the property is read-only unless its
+	// visitor has a desiredTopic, which takes precedence.
+	ReadTopic  string
+	WriteTopic string
+	// QoS of the subscription, the protocol qos when nil.
+	QoS *int
+}
+
+// AddProperty adds a property to the running client: it is subscribed on the
+// live connection right away and again after every reconnect, and read like
+// the configured properties (GetProperty, ReadAll, Subscribe); with a
+// WriteTopic it is written like them too. Values are kept as strings; register
+// a visitor for conversion and mapping. Adds, removals and UpdateConfig are
+// serialized.
+func (c *CustomizedClient) AddProperty(spec PropertySpec) error {
+	if spec.Name == "" {
+		return fmt.Errorf("property needs a name")
+	}
+	if subscribedProperties[spec.Name] {
+		return fmt.Errorf("property %s is built in", spec.Name)
+	}
+	if spec.QoS != nil && (*spec.QoS < 0 || *spec.QoS > 2) {
+		return fmt.Errorf("property %s: invalid qos %d, must be 0, 1 or 2", spec.Name, *spec.QoS)
+	}
+	spec.ReadTopic = c.renderTopic(spec.ReadTopic, spec.Name)
+	if err := validateTopic(spec.ReadTopic, true); err != nil {
+		return fmt.Errorf("property %s: read topic: %v", spec.Name, err)
+	}
+	if spec.WriteTopic != "" {
+		topic, err := c.publishTopic(spec.WriteTopic, spec.Name)
+		if err != nil {
+			return fmt.Errorf("property %s: write %v", spec.Name, err)
+		}
+		spec.WriteTopic = topic
+	}
+
+	c.propertyMutex.Lock()
+	defer c.propertyMutex.Unlock()
+	c.deviceMutex.Lock()
+	if _, ok := c.dynamic[spec.Name]; ok {
+		c.deviceMutex.Unlock()
+		return fmt.Errorf("property %s already exists", spec.Name)
+	}
+	if c.dynamic == nil {
+		c.dynamic = make(map[string]PropertySpec)
+	}
+	c.dynamic[spec.Name] = spec
+	client := c.mqttClient
+	c.deviceMutex.Unlock()
+
+	if client == nil || !client.IsConnected() {
+		// the next connect subscribes it
+		return nil
+	}
+	if err := c.subscribe(client, spec.Name, spec.ReadTopic, c.counted(c.onDynamicMessage(spec.Name))); err != nil {
+		c.deviceMutex.Lock()
+		delete(c.dynamic, spec.Name)
+		delete(c.subscriptions, spec.ReadTopic)
+		c.deviceMutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RemoveProperty removes a property added with AddProperty, unsubscribing its
+// topic unless another property uses it, and drops its value.
+func (c *CustomizedClient) RemoveProperty(name string) error {
+	c.propertyMutex.Lock()
+	defer c.propertyMutex.Unlock()
+	c.deviceMutex.Lock()
+	spec, ok := c.dynamic[name]
+	if !ok {
+		c.deviceMutex.Unlock()
+		return c.propertyError(ErrUnknownProperty, name, fmt.Errorf("not added with AddProperty"))
+	}
+	delete(c.dynamic, name)
+	delete(c.values, name)
+	delete(c.received, name)
+	delete(c.parseErrs, name)
+	shared := subscribedTopic(c.allSubs(), spec.ReadTopic)
+	if !shared {
+		delete(c.subscriptions, spec.ReadTopic)
+	}
+	client := c.mqttClient
+	c.deviceMutex.Unlock()
+
+	if shared || client == nil || !client.IsConnected() {
+		return nil
+	}
+	token := client.Unsubscribe(spec.ReadTopic)
+	if !token.WaitTimeout(defaultSubscribeTimeout) {
+		return fmt.Errorf("%w: unsubscribe %s not confirmed within %v", ErrTimeout, spec.ReadTopic, defaultSubscribeTimeout)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("unsubscribe %s: %v", spec.ReadTopic, err)
+	}
+	c.V(LogObserve, 0).Infof("Property %s removed, unsubscribed from %s", name, spec.ReadTopic)
+	return nil
+}
+
+// dynamicSubs lists the subscriptions of the properties added with AddProperty,
+// sorted by name. Caller must hold deviceMutex.
+func (c *CustomizedClient) dynamicSubs() []propertySub {
+	subs := make([]propertySub, 0, len(c.dynamic))
+	for name, spec := range c.dynamic {
+		subs = append(subs, propertySub{name, spec.ReadTopic, c.counted(c.onDynamicMessage(name))})
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].prop < subs[j].prop })
+	return subs
+}
+
+// onDynamicMessage returns the message handler of a property added with AddProperty.
+func (c *CustomizedClient) onDynamicMessage(name string) mqtt.MessageHandler {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		c.V(LogObserve, 2).Infof("Message for %s received on topic %s: %s", name, msg.Topic(), string(msg.Payload()))
+
+		// a change is published once deviceMutex is released, see publishEvent
+		var changed interface{}
+		defer func() {
+			if changed != nil {
+				c.publishEvent(name, changed)
+			}
+		}()
+		c.deviceMutex.Lock()
+		defer c.deviceMutex.Unlock()
+		if _, ok := c.dynamic[name]; !ok {
+			// removed while the message was in flight
+			return
+		}
+		v, ok := c.parsePayload(name, msg.Payload())
+		if !ok {
+			return
+		}
+		old, seen := c.values[name]
+		if c.values == nil {
+			c.values = make(map[string]string)
+		}
+		c.values[name] = v
+		if !seen || old != v {
+			c.recordTransition(name, v)
+			c.recordHistory(name, v)
+			changed = v
+			c.V(LogObserve, 0).InfoS("MQTT value changed", "topic", msg.Topic(), "property", name, "old", old, "new", v)
+		}
+	}
+}
+
+// dynamicNames returns the names of the properties added with AddProperty,
+// sorted. Caller must hold deviceMutex.
+func (c *CustomizedClient) dynamicNames() []string {
+	names := make([]string, 0, len(c.dynamic))
+	for name := range c.dynamic {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
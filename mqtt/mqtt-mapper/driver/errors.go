@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of driver failures. The errors of GetDeviceData, the writes and
+// InitDevice wrap one of them where it applies, so callers can tell with
+// errors.Is whether a retry may help (ErrNotConnected, ErrTimeout) or not
+// (ErrUnknownProperty, ErrConversion). errors.As with *PropertyError gives the
+// device and property.
+var (
+	ErrNotConnected    = errors.New("not connected to broker")
+	ErrUnknownProperty = errors.New("unknown property")
+	ErrConversion      = errors.New("conversion failed")
+	ErrTimeout         = errors.New("timed out")
+)
+
+// PropertyError is a failure of one property of a device.
+type PropertyError struct {
+	Device   string // device name, or the broker URL before SetDeviceName
+	Property string
+	Kind     error // one of the Err values above
+	Err      error // underlying cause, nil when Kind says it all
+}
+
+func (e *PropertyError) Error() string {
+	msg := fmt.Sprintf("%s: property %s: %v", e.Device, e.Property, e.Kind)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns Kind and the cause, for errors.Is and errors.As.
+func (e *PropertyError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// propertyError returns a PropertyError of kind for prop of this device.
+func (c *CustomizedClient) propertyError(kind error, prop string, cause error) error {
+	return &PropertyError{Device: c.deviceID(), Property: prop, Kind: kind, Err: cause}
+}
+
+// deviceID names the device in errors and traces: its name, or the broker URL
+// before InitDevice learned the name.
+func (c *CustomizedClient) deviceID() string {
+	if c.deviceName != "" {
+		return c.deviceName
+	}
+	return c.ProtocolConfig.BrokerURL
+}
@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+)
+
+// Subsystems whose verbosity can be set with LogLevels.
+const (
+	// LogConnection covers the broker session: connects, disconnects and reconnects.
+	LogConnection = "connection"
+	// LogObserve covers topic subscriptions and the messages received on them.
+	LogObserve = "observe"
+	// LogReport covers the twin values read and reported to the cloud.
+	LogReport = "report"
+)
+
+// validateLogLevels rejects LogLevels entries for unknown subsystems.
+func (c *CustomizedClient) validateLogLevels() error {
+	for subsystem := range c.ProtocolConfig.LogLevels {
+		switch subsystem {
+		case LogConnection, LogObserve, LogReport:
+		default:
+			return fmt.Errorf("logLevels: unknown subsystem %q, must be %s, %s or %s", subsystem, LogConnection, LogObserve, LogReport)
+		}
+	}
+	return nil
+}
+
+// setLogLevels makes levels the LogLevels that V applies.
+func (c *CustomizedClient) setLogLevels(levels map[string]int) {
+	c.logLevels.Store(&levels)
+}
+
+// V is klog.V for a message of subsystem. When LogLevels sets a verbosity for
+// the subsystem it decides instead of the global -v flag, in both directions: a
+// message is logged if level is at most the configured verbosity, and a negative
+// verbosity silences even level 0. Warnings and errors are not gated. V does
+// not take deviceMutex, so it can be used with or without holding it.
+func (c *CustomizedClient) V(subsystem string, level klog.Level) klog.Verbose {
+	var verbosity int
+	ok := false
+	if levels := c.logLevels.Load(); levels != nil {
+		verbosity, ok = (*levels)[subsystem]
+	}
+	if !ok {
+		return klog.V(level)
+	}
+	if int(level) <= verbosity {
+		return klog.V(0)
+	}
+	return klog.Verbose{}
+}
@@ -0,0 +1,137 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// Payload formats for MirrorFormat.
+const (
+	MirrorFormatRaw  = "raw"
+	MirrorFormatJSON = "json"
+)
+
+// mirrorTimeout bounds the wait for a QoS 1/2 mirror publish so a slow broker
+// does not stall the twin's collect cycle.
+const mirrorTimeout = 5 * time.Second
+
+// validateMirror checks the mirror options, defaulting MirrorFormat to raw.
+func (c *CustomizedClient) validateMirror() error {
+	if c.ProtocolConfig.MirrorTopic == "" {
+		if c.ProtocolConfig.RepublishOnConnect {
+			return fmt.Errorf("republishOnConnect requires a mirrorTopic")
+		}
+		return nil
+	}
+	if c.ProtocolConfig.MirrorQoS < 0 || c.ProtocolConfig.MirrorQoS > 2 {
+		return fmt.Errorf("invalid mirrorQoS %d, must be 0, 1 or 2", c.ProtocolConfig.MirrorQoS)
+	}
+	switch c.ProtocolConfig.MirrorFormat {
+	case "":
+		c.ProtocolConfig.MirrorFormat = MirrorFormatRaw
+	case MirrorFormatRaw, MirrorFormatJSON:
+	default:
+		return fmt.Errorf("invalid mirrorFormat %q", c.ProtocolConfig.MirrorFormat)
+	}
+	return nil
+}
+
+// mirrorMessage is the payload published in MirrorFormatJSON.
+type mirrorMessage struct {
+	Property  string      `json:"property"`
+	Value     interface{} `json:"value"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// Mirror publishes a reported value of prop to MirrorTopic, where "{property}",
+// "{deviceName}" and "{namespace}" are substituted. It is a no-op without a MirrorTopic.
+func (c *CustomizedClient) Mirror(prop string, v interface{}) error {
+	return c.mirror(prop, v, false)
+}
+
+// mirror is Mirror, publishing retained regardless of MirrorRetained with
+// retained set.
+func (c *CustomizedClient) mirror(prop string, v interface{}, retained bool) error {
+	c.deviceMutex.Lock()
+	tmpl, format := c.ProtocolConfig.MirrorTopic, c.ProtocolConfig.MirrorFormat
+	qos := byte(c.ProtocolConfig.MirrorQoS)
+	retained = retained || c.ProtocolConfig.MirrorRetained
+	client := c.mqttClient
+	c.deviceMutex.Unlock()
+	if tmpl == "" {
+		return nil
+	}
+	topic, err := c.publishTopic(tmpl, prop)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	if format == MirrorFormatJSON {
+		b, err := json.Marshal(mirrorMessage{Property: prop, Value: v, Timestamp: time.Now().UnixMilli()})
+		if err != nil {
+			return fmt.Errorf("marshal mirror value of %s: %v", prop, err)
+		}
+		payload = b
+	} else {
+		payload = []byte(value.Stringify(v))
+	}
+
+	if c.ProtocolConfig.Simulate {
+		klog.V(2).Infof("Simulation mode, not mirroring %s=%s to %s", prop, payload, topic)
+		return nil
+	}
+	if client == nil || !client.IsConnected() {
+		return c.propertyError(ErrNotConnected, prop, fmt.Errorf("cannot mirror"))
+	}
+
+	token := client.Publish(topic, qos, retained, payload)
+	if qos == 0 {
+		return nil
+	}
+	if !token.WaitTimeout(mirrorTimeout) {
+		return c.propertyError(ErrTimeout, prop, fmt.Errorf("mirror to %s not acknowledged within %v", topic, mirrorTimeout))
+	}
+	if token.Error() != nil {
+		return fmt.Errorf("mirror %s to %s: %v", prop, topic, token.Error())
+	}
+	klog.V(4).Infof("MQTT mirrored %s=%s to %s", prop, payload, topic)
+	return nil
+}
+
+// republishSnapshot publishes the last received value of every enabled property
+// as a retained message to MirrorTopic, so subscribers joining after a (re)connect
+// get the current state at once. Properties without a value yet are skipped.
+func (c *CustomizedClient) republishSnapshot() {
+	c.deviceMutex.Lock()
+	snapshot := make(map[string]interface{})
+	for prop, v := range map[string]interface{}{
+		"motion":         c.motionStatus,
+		"last_detection": c.lastDetection,
+		"class":          c.classLabel,
+	} {
+		if c.received[prop] && c.propertyEnabled(prop) {
+			snapshot[prop] = v
+		}
+	}
+	for _, name := range c.dynamicNames() {
+		if c.received[name] {
+			snapshot[name] = c.values[name]
+		}
+	}
+	topic := c.ProtocolConfig.MirrorTopic
+	c.deviceMutex.Unlock()
+
+	for prop, v := range snapshot {
+		v = c.visitorFor(prop).VisitorConfigData.MapValue(v)
+		if err := c.mirror(prop, v, true); err != nil {
+			klog.ErrorS(err, "Failed to republish value", "property", prop)
+		}
+	}
+	klog.V(2).Infof("MQTT republished %d values to %s", len(snapshot), topic)
+}
@@ -0,0 +1,74 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// extraOptions are the paho options that can be set through ConfigData.ExtraOptions.
+// Each setter validates the raw string value before applying it.
+var extraOptions = map[string]func(opts *mqtt.ClientOptions, v string) error{
+	"writeTimeout":         durationOption((*mqtt.ClientOptions).SetWriteTimeout),
+	"keepAlive":            durationOption((*mqtt.ClientOptions).SetKeepAlive),
+	"pingTimeout":          durationOption((*mqtt.ClientOptions).SetPingTimeout),
+	"connectTimeout":       durationOption((*mqtt.ClientOptions).SetConnectTimeout),
+	"maxReconnectInterval": durationOption((*mqtt.ClientOptions).SetMaxReconnectInterval),
+	"resumeSubs":           boolOption((*mqtt.ClientOptions).SetResumeSubs),
+	"autoReconnect":        boolOption((*mqtt.ClientOptions).SetAutoReconnect),
+	"protocolVersion": func(opts *mqtt.ClientOptions, v string) error {
+		pv, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || (pv != 3 && pv != 4) {
+			return fmt.Errorf("must be 3 (MQTT 3.1) or 4 (MQTT 3.1.1)")
+		}
+		opts.SetProtocolVersion(uint(pv))
+		return nil
+	},
+}
+
+func durationOption(set func(*mqtt.ClientOptions, time.Duration) *mqtt.ClientOptions) func(*mqtt.ClientOptions, string) error {
+	return func(opts *mqtt.ClientOptions, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("must be a non-negative duration such as \"10s\"")
+		}
+		set(opts, d)
+		return nil
+	}
+}
+
+func boolOption(set func(*mqtt.ClientOptions, bool) *mqtt.ClientOptions) func(*mqtt.ClientOptions, string) error {
+	return func(opts *mqtt.ClientOptions, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		set(opts, b)
+		return nil
+	}
+}
+
+// applyExtraOptions sets ConfigData.ExtraOptions on opts, overriding the values
+// chosen by InitDevice. Unknown keys and invalid values are rejected.
+func applyExtraOptions(opts *mqtt.ClientOptions, extra map[string]string) error {
+	for key, value := range extra {
+		set, ok := extraOptions[key]
+		if !ok {
+			supported := make([]string, 0, len(extraOptions))
+			for k := range extraOptions {
+				supported = append(supported, k)
+			}
+			sort.Strings(supported)
+			return fmt.Errorf("unsupported extraOptions key %q, supported: %v", key, supported)
+		}
+		if err := set(opts, value); err != nil {
+			return fmt.Errorf("invalid extraOptions %s=%q: %v", key, value, err)
+		}
+		klog.V(2).Infof("MQTT option %s set to %s", key, value)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// Reactions to an unusable payload, see OnParseError.
+const (
+	// ParseKeepLast leaves the last good value in place (default).
+	ParseKeepLast = "keepLast"
+	// ParseSetDefault resets the property to its zero value (false or "").
+	ParseSetDefault = "setDefault"
+	// ParseReportError makes GetDeviceData fail until a good payload arrives.
+	ParseReportError = "reportError"
+)
+
+// validateParseErrorModes checks the OnParseError entries.
+func (c *CustomizedClient) validateParseErrorModes() error {
+	for prop, mode := range c.ProtocolConfig.OnParseError {
+		switch mode {
+		case ParseKeepLast, ParseSetDefault, ParseReportError:
+		default:
+			return fmt.Errorf("onParseError: invalid mode %q for %s", mode, prop)
+		}
+	}
+	c.parseErrs = make(map[string]error)
+	return nil
+}
+
+// payloadError reports why a normalized payload cannot be used as the value of
+// prop: it is empty, looks like JSON but does not parse (e.g. truncated), or is
+// not a recognized boolean for motion.
+func (c *CustomizedClient) payloadError(prop, v string) error {
+	if v == "" {
+		return errors.New("empty payload")
+	}
+	if v[0] == '{' || v[0] == '[' || v[0] == '"' {
+		if !json.Valid([]byte(v)) {
+			return fmt.Errorf("invalid or truncated JSON %q", v)
+		}
+		return nil
+	}
+	if prop == "motion" {
+		if _, ok := c.boolTokens.Parse(v); !ok {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+	}
+	return nil
+}
+
+// handleParseError applies the OnParseError mode of prop to a failed payload.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) handleParseError(prop string, err error) {
+	mode := c.ProtocolConfig.OnParseError[prop]
+	if mode == "" {
+		mode = ParseKeepLast
+	}
+	klog.Warningf("MQTT %s: unusable payload: %v (onParseError=%s)", prop, err, mode)
+	switch mode {
+	case ParseSetDefault:
+		switch prop {
+		case "motion":
+			c.motionStatus = false
+		case "last_detection":
+			c.lastDetection = ""
+		case "class":
+			c.classLabel = ""
+		default:
+			if _, ok := c.dynamic[prop]; ok {
+				c.values[prop] = ""
+			}
+		}
+	case ParseReportError:
+		c.parseErrs[prop] = err
+	}
+}
+
+// parsePayload normalizes a message for prop, checks it with payloadError,
+// canonicalizes timestamps and smooths numbers, handling a failure per
+// OnParseError. ok is false when the caller must not update the value, also for
+// a message of a paused property still in flight or a duplicate within
+// DedupWindow. Caller must hold deviceMutex.
+func (c *CustomizedClient) parsePayload(prop string, payload []byte) (v string, ok bool) {
+	c.lastUpdate = time.Now()
+	if c.paused[prop] || c.duplicate(prop, payload) {
+		return "", false
+	}
+	v, err := c.decodePayload(prop, payload)
+	if err == nil {
+		err = c.payloadError(prop, v)
+	}
+	// TimestampFormat rewrites a timestamp in the canonical form
+	if format := c.visitors[prop].VisitorConfigData.TimestampFormat; err == nil && format != "" {
+		v, err = value.CanonicalTimestamp(v, format)
+	}
+	if err != nil {
+		c.handleParseError(prop, err)
+		return "", false
+	}
+	delete(c.parseErrs, prop)
+	c.received[prop] = true
+	return c.smooth(prop, v), true
+}
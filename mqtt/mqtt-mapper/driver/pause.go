@@ -0,0 +1,114 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// propertySub is the subscription backing one property.
+type propertySub struct {
+	prop, topic string
+	handler     mqtt.MessageHandler
+}
+
+// propertySubs lists the subscriptions of the properties, paused or not.
+func (c *CustomizedClient) propertySubs() []propertySub {
+	return []propertySub{
+		{"motion", c.ProtocolConfig.MotionTopic, c.counted(c.onMotionMessage)},
+		{"last_detection", c.ProtocolConfig.LastDetectionTopic, c.counted(c.onLastDetectionMessage)},
+		{"class", c.ProtocolConfig.ClassTopic, c.counted(c.onClassMessage)},
+	}
+}
+
+// allSubs lists the subscriptions of the configured properties followed by the
+// ones added with AddProperty. Caller must hold deviceMutex.
+func (c *CustomizedClient) allSubs() []propertySub {
+	return append(c.propertySubs(), c.dynamicSubs()...)
+}
+
+// propertySubFor returns the subscription of prop.
+func (c *CustomizedClient) propertySubFor(prop string) (propertySub, error) {
+	c.deviceMutex.Lock()
+	subs := c.allSubs()
+	c.deviceMutex.Unlock()
+	for _, sub := range subs {
+		if sub.prop == prop {
+			if !c.propertyEnabled(prop) {
+				return propertySub{}, fmt.Errorf("property %s is disabled", prop)
+			}
+			return sub, nil
+		}
+	}
+	return propertySub{}, c.propertyError(ErrUnknownProperty, prop, nil)
+}
+
+// PauseProperty stops ingesting prop at runtime by unsubscribing from its topic
+// on the live client; the connection and the other subscriptions stay. The last
+// value is kept and reconnects do not subscribe the topic again until
+// ResumeProperty. Messages still in flight when it returns are dropped.
+func (c *CustomizedClient) PauseProperty(prop string) error {
+	sub, err := c.propertySubFor(prop)
+	if err != nil {
+		return err
+	}
+	c.deviceMutex.Lock()
+	if c.paused[prop] {
+		c.deviceMutex.Unlock()
+		return nil
+	}
+	if c.paused == nil {
+		c.paused = make(map[string]bool)
+	}
+	c.paused[prop] = true
+	delete(c.subscriptions, sub.topic)
+	client := c.mqttClient
+	c.deviceMutex.Unlock()
+
+	if client == nil || !client.IsConnected() {
+		return nil
+	}
+	token := client.Unsubscribe(sub.topic)
+	if !token.WaitTimeout(defaultSubscribeTimeout) {
+		return fmt.Errorf("%w: unsubscribe %s not confirmed within %v", ErrTimeout, sub.topic, defaultSubscribeTimeout)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("unsubscribe %s: %v", sub.topic, err)
+	}
+	c.V(LogObserve, 0).Infof("Property %s paused, unsubscribed from %s", prop, sub.topic)
+	return nil
+}
+
+// ResumeProperty subscribes to the topic of a property paused with PauseProperty
+// again. If the client is not connected the subscription happens on the next
+// connect.
+func (c *CustomizedClient) ResumeProperty(prop string) error {
+	sub, err := c.propertySubFor(prop)
+	if err != nil {
+		return err
+	}
+	c.deviceMutex.Lock()
+	if !c.paused[prop] {
+		c.deviceMutex.Unlock()
+		return nil
+	}
+	delete(c.paused, prop)
+	client := c.mqttClient
+	c.deviceMutex.Unlock()
+
+	if client == nil || !client.IsConnected() {
+		return nil
+	}
+	return c.subscribe(client, sub.prop, sub.topic, sub.handler)
+}
+
+// pausedProperties returns the paused properties, sorted. Caller must hold deviceMutex.
+func (c *CustomizedClient) pausedProperties() []string {
+	var props []string
+	for prop := range c.paused {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	return props
+}
@@ -0,0 +1,31 @@
+package driver
+
+import (
+	"fmt"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// parseMaxPayload validates MaxPayloadBytes. 0 leaves payloads unlimited.
+func (c *CustomizedClient) parseMaxPayload() error {
+	if c.ProtocolConfig.MaxPayloadBytes < 0 {
+		return fmt.Errorf("invalid maxPayloadBytes %d, must not be negative", c.ProtocolConfig.MaxPayloadBytes)
+	}
+	c.maxPayload.Store(int64(c.ProtocolConfig.MaxPayloadBytes))
+	return nil
+}
+
+// oversized reports whether msg exceeds MaxPayloadBytes, logging and counting it
+// when it does. It runs before the property handler, so an oversized payload is
+// never stringified, parsed or stored.
+func (c *CustomizedClient) oversized(msg mqtt.Message) bool {
+	limit := c.maxPayload.Load()
+	size := len(msg.Payload())
+	if limit == 0 || int64(size) <= limit {
+		return false
+	}
+	c.messagesOversized.Add(1)
+	klog.Warningf("MQTT message on %s dropped: payload of %d bytes exceeds maxPayloadBytes %d", msg.Topic(), size, limit)
+	return true
+}
@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// Presence payloads used when BirthTopic or WillTopic is set without a template.
+const (
+	defaultBirthPayload = `{"device":"{deviceName}","namespace":"{namespace}","status":"online","timestamp":"{timestamp}"}`
+	defaultWillPayload  = `{"device":"{deviceName}","namespace":"{namespace}","status":"offline","timestamp":"{timestamp}"}`
+)
+
+// renderPresence substitutes {deviceName}, {namespace} and {timestamp} (RFC 3339,
+// UTC) in a presence payload template.
+func (c *CustomizedClient) renderPresence(tmpl string) string {
+	return strings.NewReplacer(
+		"{deviceName}", c.deviceName,
+		"{namespace}", c.deviceNamespace,
+		"{timestamp}", time.Now().UTC().Format(time.RFC3339),
+	).Replace(tmpl)
+}
+
+// validatePresence renders BirthTopic and WillTopic with the device identity and
+// fills in the default payload templates.
+func (c *CustomizedClient) validatePresence() error {
+	for _, p := range []struct {
+		name        string
+		topic, tmpl *string
+		defaultTmpl string
+	}{
+		{"birthTopic", &c.ProtocolConfig.BirthTopic, &c.ProtocolConfig.BirthPayloadTemplate, defaultBirthPayload},
+		{"willTopic", &c.ProtocolConfig.WillTopic, &c.ProtocolConfig.WillPayloadTemplate, defaultWillPayload},
+	} {
+		if *p.topic == "" {
+			continue
+		}
+		rendered := c.renderTopic(*p.topic, "")
+		if err := validateTopic(rendered, false); err != nil {
+			return fmt.Errorf("%s %q: %v", p.name, *p.topic, err)
+		}
+		*p.topic = rendered
+		if *p.tmpl == "" {
+			*p.tmpl = p.defaultTmpl
+		}
+	}
+	return nil
+}
+
+// setWill registers the will message with the broker. The broker publishes it,
+// retained, when the session ends without a DISCONNECT. It is rendered once, so
+// its {timestamp} is the time InitDevice ran, not the time the device went away.
+func (c *CustomizedClient) setWill(opts *mqtt.ClientOptions) {
+	if c.ProtocolConfig.WillTopic == "" {
+		return
+	}
+	opts.SetWill(c.ProtocolConfig.WillTopic, c.renderPresence(c.ProtocolConfig.WillPayloadTemplate), byte(c.ProtocolConfig.QoS), true)
+}
+
+// publishPresence publishes a rendered presence template retained to topic with
+// qos and waits for the acknowledgment up to timeout with QoS 1 and 2.
+func (c *CustomizedClient) publishPresence(client mqtt.Client, topic, tmpl string, qos byte, timeout time.Duration) {
+	if topic == "" {
+		return
+	}
+	payload := c.renderPresence(tmpl)
+	token := client.Publish(topic, qos, true, payload)
+	if qos > 0 {
+		if !token.WaitTimeout(timeout) {
+			klog.Errorf("MQTT presence on %s not acknowledged within %v", topic, timeout)
+			return
+		}
+		if token.Error() != nil {
+			klog.Errorf("Failed to publish presence to %s: %v", topic, token.Error())
+			return
+		}
+	}
+	c.V(LogConnection, 2).Infof("MQTT presence %s published to %s", payload, topic)
+}
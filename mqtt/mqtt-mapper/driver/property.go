@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kubeedge/mapper-common/pkg/codec"
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// RegisterVisitor records the visitor config of a property so GetProperty and
+// SetProperty apply the same settings (ValueMap, DesiredTopic, ...) as the twin.
+// It rejects an invalid BinaryType, Endianness, TimestampFormat or SmoothingAlpha
+// and an unknown Codec.
+func (c *CustomizedClient) RegisterVisitor(visitor *VisitorConfig) error {
+	if cfg := visitor.VisitorConfigData; cfg.BinaryType != "" || cfg.Endianness != "" {
+		if err := value.ValidateBinary(cfg.BinaryType, cfg.Endianness); err != nil {
+			return fmt.Errorf("property %s: %v", cfg.PropertyName, err)
+		}
+	}
+	if cfg := visitor.VisitorConfigData; cfg.TimestampFormat != "" {
+		if cfg.PropertyName == "motion" {
+			return fmt.Errorf("property motion: timestampFormat does not apply to a boolean")
+		}
+		if err := value.ValidateTimestampFormat(cfg.TimestampFormat); err != nil {
+			return fmt.Errorf("property %s: %v", cfg.PropertyName, err)
+		}
+	}
+	if cfg := visitor.VisitorConfigData; cfg.Codec != "" {
+		if cfg.BinaryType != "" {
+			return fmt.Errorf("property %s: binaryType and codec are exclusive", cfg.PropertyName)
+		}
+		if _, err := codec.Lookup(cfg.Codec); err != nil {
+			return fmt.Errorf("property %s: %v", cfg.PropertyName, err)
+		}
+	}
+	if err := validateSmoothing(visitor.VisitorConfigData); err != nil {
+		return fmt.Errorf("property %s: %v", visitor.VisitorConfigData.PropertyName, err)
+	}
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	if c.visitors == nil {
+		c.visitors = make(map[string]VisitorConfig)
+	}
+	c.visitors[visitor.VisitorConfigData.PropertyName] = *visitor
+	return nil
+}
+
+// visitorFor returns the registered visitor of prop, or a bare one naming it.
+func (c *CustomizedClient) visitorFor(prop string) *VisitorConfig {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return c.registeredVisitor(prop)
+}
+
+// registeredVisitor is visitorFor without the locking. Caller must hold deviceMutex.
+func (c *CustomizedClient) registeredVisitor(prop string) *VisitorConfig {
+	if v, ok := c.visitors[prop]; ok {
+		return &v
+	}
+	return &VisitorConfig{ProtocolName: c.ProtocolConfig.ProtocolName, VisitorConfigData: VisitorConfigData{PropertyName: prop}}
+}
+
+// GetProperty reads a property by name without a VisitorConfig, through the same
+// path as GetDeviceData.
+func (c *CustomizedClient) GetProperty(name string) (interface{}, error) {
+	return c.GetPropertyContext(context.Background(), name)
+}
+
+// GetPropertyContext is GetProperty traced as a child of the span in ctx.
+func (c *CustomizedClient) GetPropertyContext(ctx context.Context, name string) (interface{}, error) {
+	return c.GetDeviceDataContext(ctx, c.visitorFor(name))
+}
+
+// DisableProperties marks properties that must not be observed, subscribed or read.
+// It must be called before InitDevice; changing the set requires a restart of the device.
+func (c *CustomizedClient) DisableProperties(names []string) {
+	c.disabled = make(map[string]bool, len(names))
+	for _, name := range names {
+		c.disabled[name] = true
+	}
+}
+
+// propertyEnabled reports whether prop was not disabled with DisableProperties.
+func (c *CustomizedClient) propertyEnabled(prop string) bool {
+	return !c.disabled[prop]
+}
+
+// parseNormalizers compiles ProtocolConfig.Normalize.
+func (c *CustomizedClient) parseNormalizers() error {
+	n, err := value.CompileNormalizers(c.ProtocolConfig.Normalize)
+	if err != nil {
+		return err
+	}
+	c.normalizers = n
+	return nil
+}
+
+// parseBoolTokens installs BoolTokens, or the shared defaults when unset.
+func (c *CustomizedClient) parseBoolTokens() error {
+	t, err := value.BoolTokensOrDefault(c.ProtocolConfig.BoolTokens)
+	if err != nil {
+		return err
+	}
+	c.boolTokens = t
+	return nil
+}
+
+// inferValue converts v to the type value.Infer finds when the visitor leaves
+// DataType empty and sets InferType. The type is logged the first time it is
+// inferred for a property. Caller must hold deviceMutex.
+func (c *CustomizedClient) inferValue(cfg VisitorConfigData, v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || !cfg.InferType || cfg.DataType != "" {
+		return v
+	}
+	inferred, typ, first := c.inferredTypes.Infer(cfg.PropertyName, s)
+	if first {
+		c.V(LogReport, 0).InfoS("Inferred property type", "property", cfg.PropertyName, "type", typ)
+	}
+	return inferred
+}
+
+// SetProperty writes a property by name without a VisitorConfig, through the same
+// path as DeviceDataWrite. Only properties registered with a desiredTopic or added
+// with a WriteTopic are writable.
+func (c *CustomizedClient) SetProperty(name string, v interface{}) error {
+	return c.DeviceDataWrite(c.visitorFor(name), "", name, v)
+}
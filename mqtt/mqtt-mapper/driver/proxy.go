@@ -0,0 +1,193 @@
+package driver
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"golang.org/x/net/proxy"
+	"k8s.io/klog/v2"
+)
+
+// proxyDialTimeout bounds connecting to the proxy and opening the tunnel.
+const proxyDialTimeout = 30 * time.Second
+
+// defaultBrokerPorts are used for a brokerURL without a port.
+var defaultBrokerPorts = map[string]string{
+	"tcp": "1883", "ssl": "8883", "tls": "8883", "tcps": "8883", "ws": "80", "wss": "443",
+}
+
+// parseProxy validates ProxyURL, an http:// (CONNECT) or socks5:// proxy with
+// optional user:password, and checks the broker is reached over a network.
+func (c *CustomizedClient) parseProxy() error {
+	if c.ProtocolConfig.ProxyURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.ProtocolConfig.ProxyURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxyURL, must look like http://host:port or socks5://host:port")
+	}
+	if u.Scheme != "http" && u.Scheme != "socks5" {
+		return fmt.Errorf("invalid proxyURL scheme %q, must be http or socks5", u.Scheme)
+	}
+	broker, err := url.Parse(c.ProtocolConfig.BrokerURL)
+	if err != nil {
+		return fmt.Errorf("invalid brokerURL: %v", err)
+	}
+	if _, ok := defaultBrokerPorts[broker.Scheme]; !ok {
+		return fmt.Errorf("proxyURL does not apply to a %s broker", broker.Scheme)
+	}
+	c.proxyURL = u
+	return nil
+}
+
+// startProxyTunnel routes the broker connection through ProxyURL and returns
+// the broker URL to hand to paho. paho 1.2 has no dialer hook (it only honours
+// the all_proxy environment variable, process-wide), so a listener on the
+// loopback interface forwards every connection paho opens through the proxy to
+// the broker. TLS keeps verifying the broker's name; over ws and wss the HTTP
+// Host header names the loopback address, which brokers generally ignore.
+func (c *CustomizedClient) startProxyTunnel(opts *mqtt.ClientOptions) (string, error) {
+	broker, _ := url.Parse(c.ProtocolConfig.BrokerURL)
+	target := broker.Host
+	if broker.Port() == "" {
+		target = net.JoinHostPort(broker.Hostname(), defaultBrokerPorts[broker.Scheme])
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("proxy tunnel: %v", err)
+	}
+	c.tunnel = l
+	go c.runProxyTunnel(l, target)
+
+	switch broker.Scheme {
+	case "ssl", "tls", "tcps", "wss":
+		cfg := &tls.Config{}
+		if opts.TLSConfig != nil {
+			cfg = opts.TLSConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = broker.Hostname()
+		}
+		opts.SetTLSConfig(cfg)
+	}
+	local := *broker
+	local.Host = l.Addr().String()
+	c.V(LogConnection, 0).InfoS("MQTT connecting through proxy", "broker", c.ProtocolConfig.BrokerURL, "proxy", c.proxyURL.Redacted())
+	return local.String(), nil
+}
+
+// runProxyTunnel forwards the connections accepted on l to target through the
+// proxy until l is closed. A failure to open the tunnel is logged and kept for
+// the connect error, as paho only sees the connection close.
+func (c *CustomizedClient) runProxyTunnel(l net.Listener, target string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			remote, err := dialProxy(c.proxyURL, target)
+			c.deviceMutex.Lock()
+			c.proxyErr = err
+			c.deviceMutex.Unlock()
+			if err != nil {
+				klog.ErrorS(err, "MQTT proxy tunnel failed", "broker", target, "proxy", c.proxyURL.Redacted())
+				return
+			}
+			defer remote.Close()
+			go func() {
+				_, _ = io.Copy(remote, conn)
+				remote.Close()
+			}()
+			_, _ = io.Copy(conn, remote)
+		}()
+	}
+}
+
+// closeProxyTunnel stops forwarding new connections. Caller must hold deviceMutex
+// or be the only user of the client.
+func (c *CustomizedClient) closeProxyTunnel() {
+	if c.tunnel != nil {
+		c.tunnel.Close()
+		c.tunnel = nil
+	}
+}
+
+// connectError describes a failed connect, naming the proxy when the tunnel
+// through it could not be opened.
+func (c *CustomizedClient) connectError(err error) error {
+	c.deviceMutex.Lock()
+	proxyErr := c.proxyErr
+	c.deviceMutex.Unlock()
+	if proxyErr != nil {
+		return fmt.Errorf("%w: failed to connect through proxy %s: %v", ErrNotConnected, c.proxyURL.Redacted(), proxyErr)
+	}
+	return fmt.Errorf("%w: failed to connect: %v", ErrNotConnected, err)
+}
+
+// dialProxy opens a connection to addr through the proxy at u.
+func dialProxy(u *url.URL, addr string) (net.Conn, error) {
+	if u.Scheme == "socks5" {
+		d, err := proxy.FromURL(u, &net.Dialer{Timeout: proxyDialTimeout})
+		if err != nil {
+			return nil, err
+		}
+		return d.Dial("tcp", addr)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, proxyDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	conn.SetDeadline(time.Now().Add(proxyDialTimeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	if br.Buffered() > 0 {
+		// the broker never speaks first in MQTT, but keep what was read anyway
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
@@ -0,0 +1,44 @@
+package driver
+
+import (
+	"fmt"
+)
+
+// subscribedProperties are the properties InitDevice subscribes a topic for.
+var subscribedProperties = map[string]bool{"motion": true, "last_detection": true, "class": true}
+
+// validateQoS checks the global QoS and the per-property SubscribeQoS overrides.
+func (c *CustomizedClient) validateQoS() error {
+	if c.ProtocolConfig.QoS < 0 || c.ProtocolConfig.QoS > 2 {
+		return fmt.Errorf("invalid qos %d, must be 0, 1 or 2", c.ProtocolConfig.QoS)
+	}
+	for prop, qos := range c.ProtocolConfig.SubscribeQoS {
+		if !subscribedProperties[prop] {
+			return fmt.Errorf("subscribeQoS: unknown property %q", prop)
+		}
+		if qos < 0 || qos > 2 {
+			return fmt.Errorf("subscribeQoS: invalid qos %d for %s, must be 0, 1 or 2", qos, prop)
+		}
+	}
+	return nil
+}
+
+// publishQoS returns the QoS of the values, batches and presence messages
+// published. Caller must not hold deviceMutex.
+func (c *CustomizedClient) publishQoS() byte {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	return byte(c.ProtocolConfig.QoS)
+}
+
+// subscribeQoS returns the QoS to subscribe to the topic of prop with,
+// defaulting to the global QoS. Caller must hold deviceMutex.
+func (c *CustomizedClient) subscribeQoS(prop string) byte {
+	if spec, ok := c.dynamic[prop]; ok && spec.QoS != nil {
+		return byte(*spec.QoS)
+	}
+	if qos, ok := c.ProtocolConfig.SubscribeQoS[prop]; ok {
+		return byte(qos)
+	}
+	return byte(c.ProtocolConfig.QoS)
+}
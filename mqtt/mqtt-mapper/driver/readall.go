@@ -0,0 +1,32 @@
+package driver
+
+import "errors"
+
+// ReadAll returns the current value of every enabled property, including the
+// ones added with AddProperty, read under a
+// single deviceMutex acquisition so the values form one consistent snapshot.
+// The values are the ones last received on the subscribed topics, mapped
+// through each property's registered visitor like GetDeviceData does; nothing
+// is requested from the device.
+//
+// A property that cannot be read is left out of the map and its error is joined
+// into the returned error.
+func (c *CustomizedClient) ReadAll() (map[string]interface{}, error) {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+
+	values := make(map[string]interface{}, 3)
+	var errs []error
+	for _, prop := range append([]string{"motion", "last_detection", "class"}, c.dynamicNames()...) {
+		if !c.propertyEnabled(prop) {
+			continue
+		}
+		v, err := c.readProperty(c.registeredVisitor(prop))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values[prop] = v
+	}
+	return values, errors.Join(errs...)
+}
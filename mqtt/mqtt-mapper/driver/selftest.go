@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mapper-framework/pkg/common"
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// selfTestPoll is how often SelfTest looks whether the connection is up.
+const selfTestPoll = 100 * time.Millisecond
+
+// RegisterModelProperty records the model of prop, whose DataType, Minimum and
+// Maximum SelfTest checks the value against.
+func (c *CustomizedClient) RegisterModelProperty(prop string, model common.ModelProperty) {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	if c.models == nil {
+		c.models = make(map[string]common.ModelProperty)
+	}
+	c.models[prop] = model
+}
+
+// SelfTest checks the device mapping end to end, e.g. right after provisioning:
+// it waits for the broker connection until ctx is done, reads every enabled
+// property once through ReadAll and checks each value against the DataType and
+// bounds of its model, or the DataType of its visitor without one. A property
+// that has not received a message yet is reported too, as its topic is likely
+// wrong (retained values arrive right after subscribing). Every problem found is
+// joined into the returned error; the values that pass are logged.
+func (c *CustomizedClient) SelfTest(ctx context.Context) error {
+	if err := c.waitConnected(ctx); err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	values, err := c.ReadAll()
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	props := make([]string, 0, len(values))
+	for prop := range values {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	for _, prop := range props {
+		if !c.received[prop] && !c.paused[prop] && !c.ProtocolConfig.Simulate {
+			var topic string
+			for _, sub := range c.allSubs() {
+				if sub.prop == prop {
+					topic = sub.topic
+				}
+			}
+			errs = append(errs, fmt.Errorf("property %s: no message received on %s yet", prop, topic))
+			continue
+		}
+		model, ok := c.models[prop]
+		if !ok {
+			model.DataType = c.registeredVisitor(prop).VisitorConfigData.DataType
+		}
+		if err := value.Check(values[prop], model.DataType, model.Minimum, model.Maximum); err != nil {
+			errs = append(errs, fmt.Errorf("property %s: %v", prop, err))
+			continue
+		}
+		klog.InfoS("Self-test read", "broker", c.ProtocolConfig.BrokerURL, "property", prop, "value", values[prop])
+	}
+	return errors.Join(errs...)
+}
+
+// waitConnected returns once the broker connection is up, or an error when ctx
+// ends first. In simulation mode there is no broker to wait for.
+func (c *CustomizedClient) waitConnected(ctx context.Context) error {
+	ticker := time.NewTicker(selfTestPoll)
+	defer ticker.Stop()
+	for {
+		c.deviceMutex.Lock()
+		connected := c.ProtocolConfig.Simulate || c.mqttClient != nil && c.mqttClient.IsConnected()
+		c.deviceMutex.Unlock()
+		if connected {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%w %s: %v", ErrNotConnected, c.ProtocolConfig.BrokerURL, ctx.Err())
+		}
+	}
+}
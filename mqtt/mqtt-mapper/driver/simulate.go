@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const defaultSimulateInterval = 5 * time.Second
+
+var defaultSimulateClasses = []string{"person", "cat", "unknown"}
+
+// simulateInterval parses the configured generator period, falling back to the default.
+func simulateInterval(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultSimulateInterval, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid simulateInterval %q", raw)
+	}
+	return d, nil
+}
+
+// runSimulation drives the cached values from a simple generator instead of the broker:
+// motion toggles every interval, lastDetection is stamped whenever motion turns on and
+// classLabel cycles through SimulateClasses.
+func (c *CustomizedClient) runSimulation(ctx context.Context, interval time.Duration) {
+	classes := c.ProtocolConfig.SimulateClasses
+	if len(classes) == 0 {
+		classes = defaultSimulateClasses
+	}
+
+	c.deviceMutex.Lock()
+	c.isConnected = true
+	c.deviceMutex.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	next := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.deviceMutex.Lock()
+			c.motionStatus = !c.motionStatus
+			if c.motionStatus {
+				c.lastDetection = time.Now().UTC().Format(time.RFC3339)
+				c.classLabel = classes[next%len(classes)]
+				next++
+			}
+			klog.V(2).Infof("Simulated motion=%v last_detection=%s class=%s", c.motionStatus, c.lastDetection, c.classLabel)
+			c.deviceMutex.Unlock()
+		}
+	}
+}
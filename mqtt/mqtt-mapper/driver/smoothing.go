@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kubeedge/mapper-common/pkg/value"
+)
+
+// validateSmoothing checks the SmoothingAlpha of a visitor.
+func validateSmoothing(cfg VisitorConfigData) error {
+	if cfg.SmoothingAlpha == 0 {
+		return nil
+	}
+	if cfg.SmoothingAlpha < 0 || cfg.SmoothingAlpha > 1 {
+		return fmt.Errorf("invalid smoothingAlpha %v, must be between 0 and 1", cfg.SmoothingAlpha)
+	}
+	if cfg.PropertyName == "motion" {
+		return fmt.Errorf("smoothingAlpha does not apply to a boolean")
+	}
+	return nil
+}
+
+// smooth folds v, when it is a number, into the exponential moving average of
+// prop and returns the average; the first sample starts it. Anything else is
+// returned unchanged and leaves the average alone. Properties whose visitor
+// sets no SmoothingAlpha are not smoothed. Caller must hold deviceMutex.
+func (c *CustomizedClient) smooth(prop, v string) string {
+	alpha := c.visitors[prop].VisitorConfigData.SmoothingAlpha
+	if alpha == 0 {
+		return v
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+		return v
+	}
+	if avg, ok := c.averages[prop]; ok {
+		x = alpha*x + (1-alpha)*avg
+	}
+	if c.averages == nil {
+		c.averages = make(map[string]float64)
+	}
+	c.averages[prop] = x
+	return value.Stringify(x)
+}
@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// snapshotWait bounds the wait for the message on SnapshotTopic. A broker sends
+// a retained message right after the subscription, so nothing arriving by then
+// means there is no snapshot.
+const snapshotWait = 2 * time.Second
+
+// validateSnapshot renders SnapshotTopic with the device identity.
+func (c *CustomizedClient) validateSnapshot() error {
+	if c.ProtocolConfig.SnapshotTopic == "" {
+		return nil
+	}
+	rendered := c.renderTopic(c.ProtocolConfig.SnapshotTopic, "")
+	if err := validateTopic(rendered, false); err != nil {
+		return fmt.Errorf("snapshotTopic %q: %v", c.ProtocolConfig.SnapshotTopic, err)
+	}
+	c.ProtocolConfig.SnapshotTopic = rendered
+	return nil
+}
+
+// warmFromSnapshot subscribes to SnapshotTopic until its retained message
+// arrives, a JSON object of property values such as
+// {"motion": true, "class": "person"}, and fills the cache from it. Only
+// properties without a value yet are filled, so a live message that arrived
+// first wins. The values go through the same parsing as a message on the
+// property topic but do not count as changes for transitions, history or
+// Subscribe.
+func (c *CustomizedClient) warmFromSnapshot(client mqtt.Client) {
+	topic := c.ProtocolConfig.SnapshotTopic
+	payloads := make(chan []byte, 1)
+	token := client.Subscribe(topic, c.publishQoS(), func(_ mqtt.Client, msg mqtt.Message) {
+		select {
+		case payloads <- msg.Payload():
+		default:
+		}
+	})
+	if !token.WaitTimeout(snapshotWait) || subscribeError(token, topic) != nil {
+		klog.Warningf("MQTT snapshot: subscribe to %s failed: %v", topic, token.Error())
+		return
+	}
+	defer func() {
+		if token := client.Unsubscribe(topic); !token.WaitTimeout(snapshotWait) || token.Error() != nil {
+			klog.Warningf("MQTT snapshot: unsubscribe from %s failed: %v", topic, token.Error())
+		}
+	}()
+
+	var payload []byte
+	select {
+	case payload = <-payloads:
+	case <-time.After(snapshotWait):
+		c.V(LogConnection, 0).Infof("MQTT snapshot: nothing retained on %s", topic)
+		return
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		klog.Warningf("MQTT snapshot on %s is not a JSON object: %v", topic, err)
+		return
+	}
+
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	var warmed []string
+	for prop, raw := range doc {
+		if c.received[prop] || !c.snapshotProperty(prop) {
+			continue
+		}
+		v, ok := c.parsePayload(prop, []byte(snapshotValue(raw)))
+		if !ok {
+			continue
+		}
+		switch prop {
+		case "motion":
+			c.motionStatus = c.boolTokens.IsTrue(v)
+		case "last_detection":
+			c.lastDetection = v
+		case "class":
+			c.classLabel = v
+		default:
+			if c.values == nil {
+				c.values = make(map[string]string)
+			}
+			c.values[prop] = v
+		}
+		warmed = append(warmed, prop)
+	}
+	sort.Strings(warmed)
+	c.V(LogConnection, 0).Infof("MQTT snapshot on %s filled %v", topic, warmed)
+}
+
+// snapshotProperty reports whether prop is an enabled property that a snapshot
+// may fill. Caller must hold deviceMutex.
+func (c *CustomizedClient) snapshotProperty(prop string) bool {
+	switch prop {
+	case "motion", "last_detection", "class":
+		return c.propertyEnabled(prop)
+	}
+	_, ok := c.dynamic[prop]
+	return ok
+}
+
+// snapshotValue returns a snapshot value as the payload it would have on its
+// property topic: strings unquoted, anything else as its JSON text.
+func snapshotValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
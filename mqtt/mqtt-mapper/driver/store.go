@@ -0,0 +1,25 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// newFileStore prepares dir and returns a paho file store rooted there, so inflight
+// QoS 1/2 messages survive a mapper restart. The directory is created if missing and
+// probed for write access up front, since paho only reports store errors at runtime.
+func newFileStore(dir string) (mqtt.Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create MQTT store directory %s: %w", dir, err)
+	}
+	probe, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return nil, fmt.Errorf("MQTT store directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	_ = os.Remove(probe.Name())
+	return mqtt.NewFileStore(filepath.Clean(dir)), nil
+}
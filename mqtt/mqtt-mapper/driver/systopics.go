@@ -0,0 +1,38 @@
+package driver
+
+import (
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// sysTopics are the mosquitto $SYS topics watched with MonitorSysTopics.
+var sysTopics = []string{
+	"$SYS/broker/uptime",
+	"$SYS/broker/version",
+	"$SYS/broker/clients/connected",
+}
+
+// subscribeSysTopics subscribes to sysTopics. Brokers without $SYS support or
+// with ACLs hiding it only lose the extra diagnostics, so failures are just logged.
+func (c *CustomizedClient) subscribeSysTopics(client mqtt.Client) {
+	for _, topic := range sysTopics {
+		token := client.Subscribe(topic, 0, c.onSysMessage)
+		token.Wait()
+		err := subscribeError(token, topic)
+		c.setSubscription(topic, err)
+		if err != nil {
+			klog.Warningf("Failed to subscribe to broker topic %s: %v", topic, err)
+		}
+	}
+}
+
+// onSysMessage records the latest value of a $SYS topic.
+func (c *CustomizedClient) onSysMessage(client mqtt.Client, msg mqtt.Message) {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	c.brokerStats[msg.Topic()] = strings.TrimSpace(string(msg.Payload()))
+	c.brokerStatsAt = time.Now()
+}
@@ -0,0 +1,104 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTopicLength is the longest topic MQTT can encode.
+const maxTopicLength = 65535
+
+// SetDeviceName sets the device name and namespace substituted in topic templates.
+// It must be called before InitDevice.
+func (c *CustomizedClient) SetDeviceName(name, namespace string) {
+	c.deviceName = name
+	c.deviceNamespace = namespace
+}
+
+// renderTopic substitutes {deviceName}, {namespace} and {property} in topic.
+func (c *CustomizedClient) renderTopic(topic, prop string) string {
+	return strings.NewReplacer(
+		"{deviceName}", c.deviceName,
+		"{namespace}", c.deviceNamespace,
+		"{property}", prop,
+	).Replace(topic)
+}
+
+// applyTopicTemplate fills the subscribe topics left empty from TopicTemplate.
+// Topics set explicitly are kept as they are.
+func (c *CustomizedClient) applyTopicTemplate() error {
+	if c.ProtocolConfig.TopicTemplate == "" {
+		return nil
+	}
+	for prop, topic := range map[string]*string{
+		"motion":         &c.ProtocolConfig.MotionTopic,
+		"last_detection": &c.ProtocolConfig.LastDetectionTopic,
+		"class":          &c.ProtocolConfig.ClassTopic,
+	} {
+		if *topic != "" {
+			continue
+		}
+		rendered := c.renderTopic(c.ProtocolConfig.TopicTemplate, prop)
+		if err := validateTopic(rendered, true); err != nil {
+			return fmt.Errorf("topicTemplate %q for %s: %v", c.ProtocolConfig.TopicTemplate, prop, err)
+		}
+		*topic = rendered
+	}
+	return nil
+}
+
+// parseTopics fills in the subscribe topics from TopicTemplate and checks that
+// every property has one.
+func (c *CustomizedClient) parseTopics() error {
+	if err := c.applyTopicTemplate(); err != nil {
+		return err
+	}
+	if c.ProtocolConfig.MotionTopic == "" {
+		return fmt.Errorf("Motion topic is required in protocol config")
+	}
+	if c.ProtocolConfig.LastDetectionTopic == "" {
+		return fmt.Errorf("Last Detection topic is required in protocol config")
+	}
+	if c.ProtocolConfig.ClassTopic == "" {
+		return fmt.Errorf("Class topic is required in protocol config")
+	}
+	return nil
+}
+
+// publishTopic renders a topic prop is published to and checks that it is valid.
+func (c *CustomizedClient) publishTopic(topic, prop string) (string, error) {
+	rendered := c.renderTopic(topic, prop)
+	if err := validateTopic(rendered, false); err != nil {
+		return "", fmt.Errorf("topic %q for %s: %v", topic, prop, err)
+	}
+	return rendered, nil
+}
+
+// validateTopic checks topic against the MQTT topic rules. Wildcards are only
+// accepted in topics that are subscribed to.
+func validateTopic(topic string, wildcards bool) error {
+	switch {
+	case topic == "":
+		return fmt.Errorf("topic is empty")
+	case len(topic) > maxTopicLength:
+		return fmt.Errorf("topic is longer than %d bytes", maxTopicLength)
+	case !utf8.ValidString(topic) || strings.ContainsRune(topic, 0):
+		return fmt.Errorf("topic is not valid UTF-8")
+	case strings.Contains(topic, "{") || strings.Contains(topic, "}"):
+		return fmt.Errorf("topic has an unknown placeholder")
+	}
+	levels := strings.Split(topic, "/")
+	for i, level := range levels {
+		if !strings.ContainsAny(level, "+#") {
+			continue
+		}
+		if !wildcards {
+			return fmt.Errorf("wildcards are not allowed in a publish topic")
+		}
+		if len(level) != 1 || (level == "#" && i != len(levels)-1) {
+			return fmt.Errorf("wildcard %q must be a whole level, and # the last one", level)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package driver
+
+import (
+	"k8s.io/klog/v2"
+)
+
+// transitionBuffer is a bounded ring buffer of property values recorded between
+// collect cycles. When full, the oldest value is overwritten and counted as dropped.
+type transitionBuffer struct {
+	values  []interface{}
+	start   int
+	count   int
+	dropped uint64
+}
+
+func newTransitionBuffer(size int) *transitionBuffer {
+	return &transitionBuffer{values: make([]interface{}, size)}
+}
+
+// push appends v and reports whether the oldest value had to be dropped.
+func (b *transitionBuffer) push(v interface{}) bool {
+	if b.count < len(b.values) {
+		b.values[(b.start+b.count)%len(b.values)] = v
+		b.count++
+		return false
+	}
+	b.values[b.start] = v
+	b.start = (b.start + 1) % len(b.values)
+	b.dropped++
+	return true
+}
+
+// drain returns the buffered values oldest first and empties the buffer.
+func (b *transitionBuffer) drain() []interface{} {
+	out := make([]interface{}, 0, b.count)
+	for i := 0; i < b.count; i++ {
+		out = append(out, b.values[(b.start+i)%len(b.values)])
+		b.values[(b.start+i)%len(b.values)] = nil
+	}
+	b.start, b.count = 0, 0
+	return out
+}
+
+// recordTransition buffers a changed value of prop when buffering is enabled.
+// Caller must hold deviceMutex.
+func (c *CustomizedClient) recordTransition(prop string, v interface{}) {
+	if c.ProtocolConfig.TransitionBufferSize <= 0 {
+		return
+	}
+	buf, ok := c.transitions[prop]
+	if !ok {
+		buf = newTransitionBuffer(c.ProtocolConfig.TransitionBufferSize)
+		c.transitions[prop] = buf
+	}
+	if buf.push(v) {
+		klog.Warningf("Transition buffer for %s full, dropped oldest value (%d dropped so far)", prop, buf.dropped)
+	}
+}
+
+// DrainTransitions returns, oldest first, the values of prop buffered since the last call.
+func (c *CustomizedClient) DrainTransitions(prop string) []interface{} {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	buf, ok := c.transitions[prop]
+	if !ok {
+		return nil
+	}
+	return buf.drain()
+}
+
+// DroppedTransitions returns how many buffered values of prop were dropped on overflow.
+func (c *CustomizedClient) DroppedTransitions(prop string) uint64 {
+	c.deviceMutex.Lock()
+	defer c.deviceMutex.Unlock()
+	if buf, ok := c.transitions[prop]; ok {
+		return buf.dropped
+	}
+	return 0
+}
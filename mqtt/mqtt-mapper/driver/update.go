@@ -0,0 +1,195 @@
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kubeedge/mapper-common/pkg/state"
+)
+
+// ErrRestartRequired is returned by UpdateConfig when a changed setting only
+// takes effect on a new connection. The caller stops the device and runs
+// InitDevice with the new config instead.
+var ErrRestartRequired = errors.New("config change requires a restart")
+
+// hotFields are the ConfigData fields UpdateConfig applies to a running client:
+// the topics and their QoS, payload handling and logging. Everything else
+// (broker, credentials, session, proxy, presence, watchdog, delivery tuning,
+// buffers, simulation) is fixed for the life of the connection.
+var hotFields = map[string]bool{
+	"MotionTopic":        true,
+	"LastDetectionTopic": true,
+	"ClassTopic":         true,
+	"TopicTemplate":      true,
+	"BatchTopic":         true,
+	"QoS":                true,
+	"SubscribeQoS":       true,
+	"MirrorTopic":        true,
+	"MirrorFormat":       true,
+	"MirrorQoS":          true,
+	"MirrorRetained":     true,
+	"RepublishOnConnect": true,
+	"Normalize":          true,
+	"OnParseError":       true,
+	"BoolTokens":         true,
+	"DedupWindow":        true,
+	"MaxPayloadBytes":    true,
+	"LogLevels":          true,
+	"StateMapping":       true,
+}
+
+// UpdateConfig applies a changed protocol config to the running client without
+// reconnecting. Properties whose topic or subscription QoS changed are
+// unsubscribed from the old topic and subscribed to the new one; the other
+// subscriptions, the cached values and the connection stay. Only the hotFields
+// may change: any other difference returns ErrRestartRequired naming the
+// fields, and the client is left untouched, as it is on a validation error.
+func (c *CustomizedClient) UpdateConfig(cfg ProtocolConfig) error {
+	c.propertyMutex.Lock()
+	defer c.propertyMutex.Unlock()
+	if changed := restartFields(c.spec.ConfigData, cfg.ConfigData); len(changed) > 0 {
+		return fmt.Errorf("%w: %s changed", ErrRestartRequired, strings.Join(changed, ", "))
+	}
+	// validate on a scratch client so a bad config changes nothing
+	n := &CustomizedClient{ProtocolConfig: cloneConfig(cfg), deviceName: c.deviceName, deviceNamespace: c.deviceNamespace}
+	if err := n.parseHotConfig(); err != nil {
+		return err
+	}
+
+	c.deviceMutex.Lock()
+	oldSubs := c.propertySubs()
+	oldQoS := make([]byte, len(oldSubs))
+	for i, sub := range oldSubs {
+		oldQoS[i] = c.subscribeQoS(sub.prop)
+	}
+	// only the hotFields are written, so the others can be read without the lock
+	setHotFields(&c.ProtocolConfig.ConfigData, n.ProtocolConfig.ConfigData)
+	c.setLogLevels(c.ProtocolConfig.LogLevels)
+	c.normalizers = n.normalizers
+	c.boolTokens = n.boolTokens
+	c.dedupWindow = n.dedupWindow
+	c.maxPayload.Store(n.maxPayload.Load())
+	c.spec = cloneConfig(cfg)
+	newSubs := c.propertySubs()
+	var changed []int
+	for i, sub := range newSubs {
+		if sub.topic != oldSubs[i].topic || c.subscribeQoS(sub.prop) != oldQoS[i] {
+			changed = append(changed, i)
+			delete(c.subscriptions, oldSubs[i].topic)
+		}
+	}
+	inUse := append(newSubs, c.dynamicSubs()...)
+	client := c.mqttClient
+	c.deviceMutex.Unlock()
+
+	if client == nil || !client.IsConnected() {
+		// the next connect subscribes the new topics
+		return nil
+	}
+	var errs []error
+	for _, i := range changed {
+		old, sub := oldSubs[i], newSubs[i]
+		if old.topic != sub.topic && !subscribedTopic(inUse, old.topic) {
+			token := client.Unsubscribe(old.topic)
+			if !token.WaitTimeout(defaultSubscribeTimeout) {
+				errs = append(errs, fmt.Errorf("unsubscribe %s not confirmed within %v", old.topic, defaultSubscribeTimeout))
+			} else if err := token.Error(); err != nil {
+				errs = append(errs, fmt.Errorf("unsubscribe %s: %v", old.topic, err))
+			}
+		}
+		c.V(LogObserve, 0).Infof("Property %s moves from %s to %s", sub.prop, old.topic, sub.topic)
+		if err := c.subscribe(client, sub.prop, sub.topic, sub.handler); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseHotConfig runs the InitDevice checks of the hotFields.
+func (c *CustomizedClient) parseHotConfig() error {
+	if err := c.validateMirror(); err != nil {
+		return err
+	}
+	if err := c.validateParseErrorModes(); err != nil {
+		return err
+	}
+	if err := c.validateLogLevels(); err != nil {
+		return err
+	}
+	if err := state.ValidateMapping(c.ProtocolConfig.StateMapping); err != nil {
+		return err
+	}
+	if err := c.parseBoolTokens(); err != nil {
+		return err
+	}
+	if err := c.parseDedupWindow(); err != nil {
+		return err
+	}
+	if err := c.parseMaxPayload(); err != nil {
+		return err
+	}
+	if c.ProtocolConfig.Simulate {
+		return nil
+	}
+	if err := c.parseTopics(); err != nil {
+		return err
+	}
+	if err := c.parseNormalizers(); err != nil {
+		return err
+	}
+	return c.validateQoS()
+}
+
+// subscribedTopic reports whether one of subs uses topic.
+func subscribedTopic(subs []propertySub, topic string) bool {
+	for _, sub := range subs {
+		if sub.topic == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// restartFields returns the json names of the ConfigData fields outside
+// hotFields that differ between old and new.
+func restartFields(old, new ConfigData) []string {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	var changed []string
+	for i := 0; i < ov.NumField(); i++ {
+		f := ov.Type().Field(i)
+		if hotFields[f.Name] || reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		changed = append(changed, name)
+	}
+	return changed
+}
+
+// setHotFields copies the hotFields from src to dst. The other fields keep the
+// values InitDevice filled in, such as the generated ClientID.
+func setHotFields(dst *ConfigData, src ConfigData) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for i := 0; i < dv.NumField(); i++ {
+		if hotFields[dv.Type().Field(i).Name] {
+			dv.Field(i).Set(sv.Field(i))
+		}
+	}
+}
+
+// cloneConfig returns a deep copy of cfg through its JSON form, so the copy
+// shares no maps or pointers with cfg.
+func cloneConfig(cfg ProtocolConfig) ProtocolConfig {
+	var out ProtocolConfig
+	b, err := json.Marshal(cfg)
+	if err == nil {
+		err = json.Unmarshal(b, &out)
+	}
+	if err != nil {
+		return cfg
+	}
+	return out
+}
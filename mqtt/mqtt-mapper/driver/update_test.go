@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"sync"
+	"testing"
+)
+
+// testMessage is an mqtt.Message received on a property topic.
+type testMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m testMessage) Duplicate() bool   { return false }
+func (m testMessage) Qos() byte         { return 0 }
+func (m testMessage) Retained() bool    { return false }
+func (m testMessage) Topic() string     { return m.topic }
+func (m testMessage) MessageID() uint16 { return 0 }
+func (m testMessage) Payload() []byte   { return m.payload }
+func (m testMessage) Ack()              {}
+
+// TestUpdateConfigConcurrent changes the hot config while messages are handled
+// and values are written and mirrored. Run with -race.
+func TestUpdateConfigConcurrent(t *testing.T) {
+	configs := []ProtocolConfig{
+		{ConfigData: ConfigData{
+			Simulate: true, SimulateInterval: "1h", BatchTopic: "cmd/batch", MirrorTopic: "mirror/{property}",
+			LogLevels: map[string]int{LogObserve: 2},
+		}},
+		{ConfigData: ConfigData{
+			Simulate: true, SimulateInterval: "1h", BatchTopic: "cmd/batch2", MirrorTopic: "mirror2/{property}",
+			MirrorFormat: MirrorFormatJSON, MirrorRetained: true, QoS: 1, RepublishOnConnect: true,
+			LogLevels: map[string]int{LogObserve: -1},
+		}},
+	}
+	c, err := NewClient(configs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitDevice(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopDevice()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	worker := func(op func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				op()
+			}
+		}()
+	}
+	worker(func() { c.onMotionMessage(nil, testMessage{topic: "motion", payload: []byte("true")}) })
+	worker(func() { _ = c.WriteBatch(map[string]interface{}{"class": "person"}) })
+	worker(func() { _ = c.Mirror("class", "person") })
+	worker(func() { _ = c.GetDMIState() })
+
+	for i := 0; i < 50; i++ {
+		if err := c.UpdateConfig(configs[i%2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
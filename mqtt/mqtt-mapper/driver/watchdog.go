@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// defaultWatchdogTopic is published periodically by mosquitto, so silence on it
+// means nothing reaches the client at all.
+const defaultWatchdogTopic = "$SYS/broker/uptime"
+
+// parseWatchdog validates the watchdog settings. A zero timeout disables it.
+func (c *CustomizedClient) parseWatchdog() error {
+	if c.ProtocolConfig.WatchdogTimeout == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(c.ProtocolConfig.WatchdogTimeout)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid watchdogTimeout %q", c.ProtocolConfig.WatchdogTimeout)
+	}
+	c.watchdogTimeout = d
+	c.watchdogMonitorTimeout = d
+	if s := c.ProtocolConfig.WatchdogMonitorTimeout; s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid watchdogMonitorTimeout %q", s)
+		}
+		c.watchdogMonitorTimeout = d
+	}
+	if c.ProtocolConfig.WatchdogTopic == "" {
+		c.ProtocolConfig.WatchdogTopic = defaultWatchdogTopic
+	}
+	return nil
+}
+
+// subscribeWatchdog subscribes to the monitor topic and restarts both clocks,
+// so a fresh connection gets a full timeout before it can be declared wedged.
+func (c *CustomizedClient) subscribeWatchdog(client mqtt.Client) {
+	c.deviceMutex.Lock()
+	c.lastUpdate = time.Now()
+	c.lastMonitor = c.lastUpdate
+	c.deviceMutex.Unlock()
+
+	topic := c.ProtocolConfig.WatchdogTopic
+	token := client.Subscribe(topic, 0, c.onWatchdogMessage)
+	token.Wait()
+	err := subscribeError(token, topic)
+	c.setSubscription(topic, err)
+	if err != nil {
+		klog.Warningf("Failed to subscribe to watchdog topic %s: %v", topic, err)
+	}
+}
+
+// onWatchdogMessage records traffic on the monitor topic. paho keeps one handler
+// per topic, so $SYS values are passed on for MonitorSysTopics as well.
+func (c *CustomizedClient) onWatchdogMessage(client mqtt.Client, msg mqtt.Message) {
+	c.deviceMutex.Lock()
+	c.lastMonitor = time.Now()
+	c.deviceMutex.Unlock()
+	if c.ProtocolConfig.MonitorSysTopics && strings.HasPrefix(msg.Topic(), "$SYS/") {
+		c.onSysMessage(client, msg)
+	}
+}
+
+// runWatchdog reconnects the client when it claims to be connected but neither a
+// property message nor a monitor topic message arrived within their timeouts.
+// Real disconnects are left to paho's auto-reconnect.
+func (c *CustomizedClient) runWatchdog(ctx context.Context) {
+	check := c.watchdogTimeout / 2
+	if check < time.Second {
+		check = time.Second
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.deviceMutex.Lock()
+		client := c.mqttClient
+		lastUpdate, lastMonitor := c.lastUpdate, c.lastMonitor
+		c.deviceMutex.Unlock()
+		if client == nil || !client.IsConnected() {
+			continue
+		}
+		if time.Since(lastUpdate) < c.watchdogTimeout || time.Since(lastMonitor) < c.watchdogMonitorTimeout {
+			continue
+		}
+
+		c.deviceMutex.Lock()
+		c.watchdogRecoveries++
+		n := c.watchdogRecoveries
+		c.deviceMutex.Unlock()
+		c.V(LogConnection, 0).InfoS("MQTT watchdog: client connected but silent, forcing reconnect",
+			"broker", c.ProtocolConfig.BrokerURL, "recovery", n,
+			"lastUpdate", lastUpdate, "lastMonitor", lastMonitor, "monitorTopic", c.ProtocolConfig.WatchdogTopic)
+		if err := c.ForceReconnect(); err != nil {
+			klog.ErrorS(err, "MQTT watchdog reconnect failed", "broker", c.ProtocolConfig.BrokerURL, "recovery", n)
+		}
+	}
+}
@@ -39,6 +39,7 @@ echo "Building ${IMAGE_REPO}:${TAG} (platform: ${PLATFORM}) with ${DOCKERFILE}"
 sudo docker buildx build \
   --platform "${PLATFORM}" \
   -f "${DOCKERFILE}" \
+  --build-context mapper-common=../../mapper-common \
   -t "${IMAGE_REPO}:${TAG}" \
   --build-arg "GOPROXY=${GOPROXY_ARG}" \
   "${CACHE_FROM[@]}" \